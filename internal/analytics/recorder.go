@@ -0,0 +1,273 @@
+// internal/analytics/recorder.go
+package analytics
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// User agent classes
+const (
+	AgentDesktop = "desktop"
+	AgentMobile  = "mobile"
+	AgentTablet  = "tablet"
+	AgentBot     = "bot"
+	AgentOther   = "other"
+)
+
+// dayFormat is the layout of the day column of the rollups
+const dayFormat = "2006-01-02"
+
+// maxPending is the number of distinct rollup rows buffered before an early flush
+const maxPending = 5000
+
+// Event is a single view of a content item. It deliberately holds nothing that
+// identifies the visitor.
+type Event struct {
+	WorkspaceID   uint
+	ContentID     uint
+	ContentTypeID uint
+	Referrer      string
+	Agent         string
+	At            time.Time
+}
+
+// rollupKey identifies a row of the daily rollup table
+type rollupKey struct {
+	day       string
+	contentID uint
+	referrer  string
+	agent     string
+}
+
+// rollup holds the views buffered for a rollup row
+type rollup struct {
+	workspaceID   uint
+	contentTypeID uint
+	views         int64
+}
+
+// Recorder buffers view events in memory and periodically adds them to the
+// daily rollups, so recording a view never waits on the database
+type Recorder struct {
+	db       *db.DB
+	events   chan Event
+	interval time.Duration
+	dropped  atomic.Int64
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewRecorder creates a view recorder and starts its background writer
+func NewRecorder(database *db.DB, cfg config.AnalyticsConfig) *Recorder {
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = 1024
+	}
+	interval := time.Duration(cfg.FlushInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	rec := &Recorder{
+		db:       database,
+		events:   make(chan Event, size),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go rec.run()
+
+	return rec
+}
+
+// Record queues a view event. When the buffer is full the event is dropped
+// rather than slowing down the response. A nil recorder records nothing.
+func (rec *Recorder) Record(e Event) {
+	if rec == nil {
+		return
+	}
+
+	select {
+	case rec.events <- e:
+	default:
+		rec.dropped.Add(1)
+	}
+}
+
+// RecordRequest queues a view of content made by the given request
+func (rec *Recorder) RecordRequest(r *http.Request, content models.Content) {
+	if rec == nil {
+		return
+	}
+
+	rec.Record(Event{
+		WorkspaceID:   content.WorkspaceID,
+		ContentID:     content.ID,
+		ContentTypeID: content.ContentTypeID,
+		Referrer:      ReferrerHost(r.Referer(), r.Host),
+		Agent:         ClassifyAgent(r.UserAgent()),
+		At:            time.Now(),
+	})
+}
+
+// Close stops the background writer after flushing every buffered event
+func (rec *Recorder) Close() {
+	if rec == nil {
+		return
+	}
+
+	rec.once.Do(func() {
+		close(rec.stop)
+	})
+	<-rec.done
+}
+
+// run aggregates events and writes them out on every tick
+func (rec *Recorder) run() {
+	defer close(rec.done)
+
+	ticker := time.NewTicker(rec.interval)
+	defer ticker.Stop()
+
+	pending := map[rollupKey]*rollup{}
+	add := func(e Event) {
+		key := rollupKey{
+			day:       e.At.UTC().Format(dayFormat),
+			contentID: e.ContentID,
+			referrer:  e.Referrer,
+			agent:     e.Agent,
+		}
+		if row, ok := pending[key]; ok {
+			row.views++
+			return
+		}
+		pending[key] = &rollup{workspaceID: e.WorkspaceID, contentTypeID: e.ContentTypeID, views: 1}
+	}
+
+	for {
+		select {
+		case e := <-rec.events:
+			add(e)
+			if len(pending) >= maxPending {
+				rec.flush(pending)
+				pending = map[rollupKey]*rollup{}
+			}
+		case <-ticker.C:
+			rec.flush(pending)
+			pending = map[rollupKey]*rollup{}
+		case <-rec.stop:
+			for {
+				select {
+				case e := <-rec.events:
+					add(e)
+				default:
+					rec.flush(pending)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush adds the buffered views to the daily rollups
+func (rec *Recorder) flush(pending map[rollupKey]*rollup) {
+	if dropped := rec.dropped.Swap(0); dropped > 0 {
+		slog.Warn("Dropped content views because the analytics buffer was full", "views", dropped)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	err := db.ExecuteWithTransaction(rec.db, func(tx *gorm.DB) error {
+		for key, row := range pending {
+			view := models.ContentView{
+				Day:           key.day,
+				ContentID:     key.contentID,
+				Referrer:      key.referrer,
+				Agent:         key.agent,
+				WorkspaceID:   row.workspaceID,
+				ContentTypeID: row.contentTypeID,
+				Views:         row.views,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "day"}, {Name: "content_id"}, {Name: "referrer"}, {Name: "agent"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"views":      gorm.Expr("content_views.views + ?", row.views),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&view).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to write content views", "error", err)
+	}
+}
+
+// ClassifyAgent reduces a user agent string to a coarse class
+func ClassifyAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return AgentOther
+	case strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider") ||
+		strings.Contains(ua, "slurp") || strings.Contains(ua, "curl") || strings.Contains(ua, "wget") ||
+		strings.Contains(ua, "python-requests") || strings.Contains(ua, "headless"):
+		return AgentBot
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return AgentTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return AgentMobile
+	case strings.Contains(ua, "windows") || strings.Contains(ua, "macintosh") ||
+		strings.Contains(ua, "x11") || strings.Contains(ua, "linux") || strings.Contains(ua, "cros"):
+		return AgentDesktop
+	default:
+		return AgentOther
+	}
+}
+
+// ReferrerHost reduces a referrer URL to its host name. Direct visits and
+// visits from the CMS's own host are returned as an empty string.
+func ReferrerHost(referrer, ownHost string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(referrer)
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+
+	own := strings.ToLower(ownHost)
+	if hostname, _, err := net.SplitHostPort(own); err == nil {
+		own = hostname
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if host == strings.TrimPrefix(own, "www.") {
+		return ""
+	}
+	if len(host) > 255 {
+		host = host[:255]
+	}
+
+	return host
+}
@@ -0,0 +1,176 @@
+// internal/analytics/stats.go
+package analytics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Query restricts the rollups a report is built from
+type Query struct {
+	WorkspaceID   uint
+	From          time.Time
+	To            time.Time
+	ContentID     uint
+	ContentTypeID uint
+	// Agent limits the report to one agent class. Empty excludes bots, "all" includes them.
+	Agent string
+}
+
+// ContentViews holds the views of a content item
+type ContentViews struct {
+	ContentID     uint   `json:"content_id"`
+	Title         string `json:"title"`
+	Slug          string `json:"slug"`
+	ContentTypeID uint   `json:"content_type_id"`
+	Views         int64  `json:"views"`
+}
+
+// DayViews holds the views of a single day
+type DayViews struct {
+	Date  string `json:"date"`
+	Views int64  `json:"views"`
+}
+
+// ContentTypeViews holds the views of all content of a content type
+type ContentTypeViews struct {
+	ContentTypeID uint   `json:"content_type_id"`
+	Name          string `json:"name"`
+	Views         int64  `json:"views"`
+}
+
+// ReferrerViews holds the views coming from a referring host
+type ReferrerViews struct {
+	Referrer string `json:"referrer"`
+	Views    int64  `json:"views"`
+}
+
+// apply adds the query conditions to a rollup query
+func (q Query) apply(tx *gorm.DB) *gorm.DB {
+	tx = tx.Model(&models.ContentView{}).
+		Where("workspace_id = ? AND day >= ? AND day <= ?", q.WorkspaceID, q.From.Format(dayFormat), q.To.Format(dayFormat))
+
+	if q.ContentID != 0 {
+		tx = tx.Where("content_id = ?", q.ContentID)
+	}
+	if q.ContentTypeID != 0 {
+		tx = tx.Where("content_type_id = ?", q.ContentTypeID)
+	}
+
+	switch q.Agent {
+	case "":
+		tx = tx.Where("agent <> ?", AgentBot)
+	case "all":
+	default:
+		tx = tx.Where("agent = ?", q.Agent)
+	}
+
+	return tx
+}
+
+// TopContent returns the most viewed content items
+func TopContent(database *db.DB, q Query, limit int) ([]ContentViews, error) {
+	rows := []ContentViews{}
+	if err := q.apply(database.DB).
+		Select("content_id, content_type_id, SUM(views) AS views").
+		Group("content_id, content_type_id").
+		Order("views desc").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ContentID)
+	}
+
+	// Deleted content keeps its title in the report
+	var contents []models.Content
+	if err := database.Unscoped().Select("id", "title", "slug").Where("id IN ?", ids).Find(&contents).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Content, len(contents))
+	for _, content := range contents {
+		byID[content.ID] = content
+	}
+	for i := range rows {
+		rows[i].Title = byID[rows[i].ContentID].Title
+		rows[i].Slug = byID[rows[i].ContentID].Slug
+	}
+
+	return rows, nil
+}
+
+// Trend returns the views per day, including days without views
+func Trend(database *db.DB, q Query) ([]DayViews, error) {
+	var rows []DayViews
+	if err := q.apply(database.DB).
+		Select("day AS date, SUM(views) AS views").
+		Group("day").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		byDay[row.Date] = row.Views
+	}
+
+	days := []DayViews{}
+	for day := q.From; !day.After(q.To); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dayFormat)
+		days = append(days, DayViews{Date: date, Views: byDay[date]})
+	}
+
+	return days, nil
+}
+
+// ContentTypeTotals returns the views per content type
+func ContentTypeTotals(database *db.DB, q Query) ([]ContentTypeViews, error) {
+	rows := []ContentTypeViews{}
+	if err := q.apply(database.DB).
+		Select("content_type_id, SUM(views) AS views").
+		Group("content_type_id").
+		Order("views desc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var contentTypes []models.ContentType
+	if err := database.Unscoped().Select("id", "name").Where("workspace_id = ?", q.WorkspaceID).Find(&contentTypes).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(contentTypes))
+	for _, contentType := range contentTypes {
+		names[contentType.ID] = contentType.Name
+	}
+	for i := range rows {
+		rows[i].Name = names[rows[i].ContentTypeID]
+	}
+
+	return rows, nil
+}
+
+// TopReferrers returns the hosts that referred the most views. Direct visits
+// are reported with an empty referrer.
+func TopReferrers(database *db.DB, q Query, limit int) ([]ReferrerViews, error) {
+	rows := []ReferrerViews{}
+	if err := q.apply(database.DB).
+		Select("referrer, SUM(views) AS views").
+		Group("referrer").
+		Order("views desc").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
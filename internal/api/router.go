@@ -14,16 +14,26 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
 
+	"github.com/randilt/floe-cms/internal/analytics"
 	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/config"
 	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/export"
 	"github.com/randilt/floe-cms/internal/handlers"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/models"
 	mw "github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/openapi"
+	"github.com/randilt/floe-cms/internal/related"
 	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/uploads"
+	"github.com/randilt/floe-cms/internal/visibility"
 )
 
 // NewRouter creates a new router for the API
-func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, adminUI embed.FS, cfg *config.Config) *chi.Mux {
+func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, imageProcessor *images.Processor, partials *uploads.Partials, views *analytics.Recorder, relatedRefresher *related.Refresher, responseCache *cache.Cache, adminUI embed.FS, cfg *config.Config) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Basic middleware
@@ -31,13 +41,15 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
+	// HEAD requests are answered by the GET handlers of routes without their own
+	r.Use(middleware.GetHead)
 
 	// CORS middleware
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"},
+		ExposedHeaders:   []string{"Link", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Upload-Offset", "Upload-Length", "Upload-Expires", "Floe-Media-ID"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -53,10 +65,18 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 
 	// Create handlers
 	authHandler := handlers.NewAuthHandler(authManager, db)
-	contentHandler := handlers.NewContentHandler(db, storage)
-	mediaHandler := handlers.NewMediaHandler(db, storage)
-	workspaceHandler := handlers.NewWorkspaceHandler(db)
-	userHandler := handlers.NewUserHandler(db)
+	publicSerializer := delivery.NewSerializer(cfg.Delivery, storage)
+	contentHandler := handlers.NewContentHandler(db, storage, publicSerializer, views, relatedRefresher, responseCache)
+	mediaHandler := handlers.NewMediaHandler(db, storage, imageProcessor, uploads.NewChecker(cfg.Uploads), partials, responseCache)
+	quotaHandler := handlers.NewQuotaHandler(db, cfg.Uploads)
+	workspaceHandler := handlers.NewWorkspaceHandler(db, responseCache)
+	exportHandler := handlers.NewExportHandler(db, export.NewExporter(db, publicSerializer, cfg.Export))
+	userHandler := handlers.NewUserHandler(db, responseCache)
+	releaseHandler := handlers.NewReleaseHandler(db, publicSerializer, relatedRefresher, responseCache)
+	calendarHandler := handlers.NewCalendarHandler(db)
+	analyticsHandler := handlers.NewAnalyticsHandler(db)
+	seoHandler := handlers.NewSEOHandler(db, storage)
+	linkHandler := handlers.NewLinkHandler(db)
 
 	// Health check
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
@@ -64,17 +84,46 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 		w.Write([]byte("Floe CMS is running"))
 	})
 
+	// API specification
+	r.Get("/api/openapi.json", openapi.NewHandler(db, "1.0.0").ServeSpec)
+
 	// Authentication routes
 	r.Post("/api/auth/login", authHandler.Login)
 	r.Post("/api/auth/refresh", authHandler.RefreshToken)
 
-	// Public content routes
-	r.Get("/api/content/{workspace}", contentHandler.GetPublishedContent)
-	r.Get("/api/content/{workspace}/{slug}", contentHandler.GetContentBySlug)
+	// Public content routes, served from the response cache when possible and
+	// answered with validators, cache policies and compression
+	r.Group(func(r chi.Router) {
+		r.Use(cache.Conditional(cfg.Delivery))
+		r.Use(responseCache.Middleware(func(r *http.Request, entry cache.Entry) {
+			// Views of cached items still count
+			if entry.Viewed != nil {
+				views.RecordRequest(r, models.Content{
+					BaseModel:     models.BaseModel{ID: entry.Viewed.ContentID},
+					WorkspaceID:   entry.Viewed.WorkspaceID,
+					ContentTypeID: entry.Viewed.ContentTypeID,
+				})
+			}
+		}))
+		r.Get("/api/content/{workspace}", contentHandler.GetPublishedContent)
+		r.Get("/api/content/{workspace}/tree", contentHandler.GetPublishedContentTree)
+		r.Get("/api/content/{workspace}/path/*", contentHandler.GetContentByPath)
+		r.Get("/api/content/{workspace}/{slug}", contentHandler.GetContentBySlug)
+		r.Get("/api/content/{workspace}/{slug}/related", contentHandler.GetRelatedContent)
+		r.Get("/api/content/{workspace}/{slug}/head", seoHandler.GetContentHead)
+	})
+
+	// Release preview routes (scoped by the release preview token)
+	r.Get("/api/preview/releases/{token}", releaseHandler.GetReleasePreview)
+	r.Get("/api/preview/releases/{token}/{slug}", releaseHandler.GetReleasePreviewBySlug)
 
-	// Serve uploads
-	fileServer := http.FileServer(http.Dir(cfg.Storage.UploadsDir))
-	r.Handle("/uploads/*", http.StripPrefix("/uploads/", fileServer))
+	// Editorial calendar feed (scoped by the user's secret calendar token)
+	r.Get("/api/calendar/{token}.ics", calendarHandler.ServeCalendarFeed)
+
+	// Serve uploads, and signed transforms of uploaded images. Files of
+	// private media need a signed URL.
+	r.With(mw.UploadHeaders).Handle("/uploads/*", http.StripPrefix("/uploads/",
+		visibility.Guard(db, storage)(imageProcessor.Middleware(storage.Handler()))))
 
 	// Protected routes (require authentication)
 	r.Group(func(r chi.Router) {
@@ -84,11 +133,21 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 		r.Route("/api/workspaces/{workspaceId}/content", func(r chi.Router) {
 			r.Post("/", contentHandler.CreateContent)
 			r.Get("/", contentHandler.ListContent)
+			r.Get("/tree", contentHandler.GetContentTree)
+			r.Put("/reorder", contentHandler.ReorderContent)
 			r.Get("/{id}", contentHandler.GetContent)
 			r.Put("/{id}", contentHandler.UpdateContent)
 			r.Delete("/{id}", contentHandler.DeleteContent)
+			r.Post("/{id}/move", contentHandler.MoveContent)
+			r.Get("/{id}/draft", contentHandler.GetContentDraft)
+			r.Post("/{id}/draft/publish", contentHandler.PublishContentDraft)
+			r.Delete("/{id}/draft", contentHandler.DiscardContentDraft)
+			r.Get("/{id}/seo", seoHandler.GetContentSEO)
 		})
 
+		// Editorial calendar
+		r.Get("/api/workspaces/{workspaceId}/calendar", calendarHandler.GetCalendar)
+
 		// Auth routes
 		r.Post("/api/auth/logout", authHandler.Logout)
 
@@ -114,8 +173,49 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 		r.Route("/api/media", func(r chi.Router) {
 			r.Post("/", mediaHandler.UploadMedia)
 			r.Get("/", mediaHandler.ListMedia)
+			r.Get("/tags", mediaHandler.ListMediaTags)
+			r.Post("/move", mediaHandler.MoveMedia)
+
+			// Media folders
+			r.Get("/folders", mediaHandler.ListMediaFolders)
+			r.Post("/folders", mediaHandler.CreateMediaFolder)
+			r.Put("/folders/{id}", mediaHandler.UpdateMediaFolder)
+			r.Post("/folders/{id}/move", mediaHandler.MoveMediaFolder)
+			r.Delete("/folders/{id}", mediaHandler.DeleteMediaFolder)
+
+			// Resumable uploads (tus 1.0)
+			r.Options("/uploads", mediaHandler.UploadOptions)
+			r.Post("/uploads", mediaHandler.CreateUpload)
+			r.Head("/uploads/{id}", mediaHandler.GetUploadOffset)
+			r.Patch("/uploads/{id}", mediaHandler.PatchUpload)
+			r.Delete("/uploads/{id}", mediaHandler.DeleteUpload)
+
 			r.Get("/{id}", mediaHandler.GetMedia)
+			r.Put("/{id}", mediaHandler.UpdateMedia)
 			r.Delete("/{id}", mediaHandler.DeleteMedia)
+			r.Get("/{id}/usages", mediaHandler.GetMediaUsages)
+			r.Get("/{id}/transform", mediaHandler.GetTransformURL)
+			r.Post("/{id}/replace", mediaHandler.ReplaceMedia)
+			r.Put("/{id}/file", mediaHandler.ReplaceMediaFile)
+			r.Get("/{id}/versions", mediaHandler.ListMediaVersions)
+			r.Post("/{id}/versions/{versionId}/restore", mediaHandler.RestoreMediaVersion)
+			r.Delete("/{id}/versions/{versionId}", mediaHandler.DeleteMediaVersion)
+		})
+
+		// Release routes
+		r.Route("/api/releases", func(r chi.Router) {
+			r.Use(mw.EditorOrAbove)
+			r.Post("/", releaseHandler.CreateRelease)
+			r.Get("/", releaseHandler.ListReleases)
+			r.Get("/{id}", releaseHandler.GetRelease)
+			r.Put("/{id}", releaseHandler.UpdateRelease)
+			r.Delete("/{id}", releaseHandler.DeleteRelease)
+			r.Post("/{id}/items", releaseHandler.AddReleaseItem)
+			r.Delete("/{id}/items/{itemId}", releaseHandler.RemoveReleaseItem)
+			r.Post("/{id}/publish", releaseHandler.PublishRelease)
+			r.Post("/{id}/schedule", releaseHandler.ScheduleRelease)
+			r.Post("/{id}/rollback", releaseHandler.RollbackRelease)
+			r.Get("/{id}/preview", releaseHandler.PreviewRelease)
 		})
 
 		// Workspace routes
@@ -123,6 +223,7 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 			r.Use(mw.AdminOnly) // Only admins can manage workspaces
 			r.Post("/", workspaceHandler.CreateWorkspace)
 			r.Get("/", workspaceHandler.ListWorkspaces)
+			r.Get("/storage", quotaHandler.ListStorageUsage)
 			r.Get("/{id}", workspaceHandler.GetWorkspace)
 			r.Put("/{id}", workspaceHandler.UpdateWorkspace)
 			r.Delete("/{id}", workspaceHandler.DeleteWorkspace)
@@ -130,6 +231,26 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 			// User-workspace association routes
 			r.Post("/{id}/users", workspaceHandler.AddUserToWorkspace)
 			r.Delete("/{id}/users/{userId}", workspaceHandler.RemoveUserFromWorkspace)
+
+			// Static export routes
+			r.Post("/{id}/export", exportHandler.StartExport)
+			r.Get("/{id}/export", exportHandler.GetExport)
+
+			// Content view analytics routes
+			r.Get("/{id}/analytics/top", analyticsHandler.GetTopContent)
+			r.Get("/{id}/analytics/trends", analyticsHandler.GetViewTrend)
+			r.Get("/{id}/analytics/content-types", analyticsHandler.GetContentTypeViews)
+			r.Get("/{id}/analytics/referrers", analyticsHandler.GetTopReferrers)
+
+			// Storage usage route
+			r.Get("/{id}/storage", quotaHandler.GetStorageUsage)
+
+			// SEO audit route
+			r.Get("/{id}/seo/report", seoHandler.GetWorkspaceSEOReport)
+
+			// Broken link check routes
+			r.Get("/{id}/links", linkHandler.GetBrokenLinks)
+			r.Post("/{id}/links/check", linkHandler.CheckLinks)
 		})
 
 		// User routes
@@ -146,6 +267,8 @@ func NewRouter(authManager *auth.Manager, db *db.DB, storage storage.Manager, ad
 		r.Get("/api/me", userHandler.GetCurrentUser)
 		r.Put("/api/me", userHandler.UpdateCurrentUser)
 		r.Put("/api/me/password", userHandler.ChangePassword)
+		r.Get("/api/me/calendar", calendarHandler.GetCalendarFeed)
+		r.Post("/api/me/calendar/rotate", calendarHandler.RotateCalendarFeed)
 	})
 
 	// Set up admin UI
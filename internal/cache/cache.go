@@ -0,0 +1,150 @@
+// internal/cache/cache.go
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Store types selected by cache.type
+const (
+	TypeMemory = "memory"
+	TypeRedis  = "redis"
+	TypeNone   = "none"
+)
+
+// invalidateTimeout bounds how long an invalidation may block a request
+const invalidateTimeout = 5 * time.Second
+
+// Entry is a cached response
+type Entry struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	// Viewed identifies the content item a response shows, so views of cached
+	// responses are still counted
+	Viewed       *Viewed   `json:"viewed,omitempty"`
+	Tags         []string  `json:"tags"`
+	LastModified time.Time `json:"last_modified"`
+	CacheControl string    `json:"cache_control,omitempty"`
+	// ETag is the validator of the version the response shows, if its
+	// handler recorded one
+	ETag string `json:"etag,omitempty"`
+}
+
+// Viewed identifies a content item shown by a cached response
+type Viewed struct {
+	ContentID     uint `json:"content_id"`
+	WorkspaceID   uint `json:"workspace_id"`
+	ContentTypeID uint `json:"content_type_id"`
+}
+
+// Store keeps cached entries along with the tags they were stored under
+type Store interface {
+	// Get returns the entry stored under key, or nil if there is none
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Set stores an entry for ttl and indexes it under each tag
+	Set(ctx context.Context, key string, entry Entry, tags []string, ttl time.Duration) error
+	// Invalidate removes every entry stored under any of the tags
+	Invalidate(ctx context.Context, tags ...string) error
+	Close() error
+}
+
+// Cache caches public responses and drops them when the data they show changes.
+// A nil cache caches nothing.
+type Cache struct {
+	store Store
+	ttl   time.Duration
+}
+
+// New creates the cache selected by the configuration. It returns nil when
+// caching is turned off.
+func New(cfg config.CacheConfig) (*Cache, error) {
+	ttl := time.Duration(cfg.TTL) * time.Second
+
+	switch cfg.Type {
+	case TypeNone, "":
+		return nil, nil
+	case TypeMemory:
+		return NewWithStore(NewMemoryStore(), ttl), nil
+	case TypeRedis:
+		store, err := DialRedis(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		return NewWithStore(store, ttl), nil
+	default:
+		return nil, fmt.Errorf("unsupported cache type: %s", cfg.Type)
+	}
+}
+
+// NewWithStore creates a cache on top of a store
+func NewWithStore(store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// Invalidate drops every cached response tagged with any of the tags
+func (c *Cache) Invalidate(tags ...string) {
+	if c == nil || len(tags) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), invalidateTimeout)
+	defer cancel()
+
+	if err := c.store.Invalidate(ctx, tags...); err != nil {
+		slog.Error("Failed to invalidate cached responses", "tags", tags, "error", err)
+	}
+}
+
+// Close releases the store
+func (c *Cache) Close() {
+	if c == nil {
+		return
+	}
+
+	if err := c.store.Close(); err != nil {
+		slog.Error("Failed to close cache", "error", err)
+	}
+}
+
+// Tags of the data cached responses depend on
+
+// Workspace tags every response showing data of a workspace
+func Workspace(id uint) string { return fmt.Sprintf("workspace:%d", id) }
+
+// Listing tags responses that list or arrange the published content of a workspace
+func Listing(workspaceID uint) string { return fmt.Sprintf("listing:%d", workspaceID) }
+
+// Content tags responses showing a content item
+func Content(id uint) string { return fmt.Sprintf("content:%d", id) }
+
+// ContentType tags responses showing a content type
+func ContentType(id uint) string { return fmt.Sprintf("content-type:%d", id) }
+
+// User tags responses showing a user as a content author
+func User(id uint) string { return fmt.Sprintf("user:%d", id) }
+
+// Media tags responses showing a media item
+func Media(id uint) string { return fmt.Sprintf("media:%d", id) }
+
+// Related tags related content recommendations of a workspace
+func Related(workspaceID uint) string { return fmt.Sprintf("related:%d", workspaceID) }
+
+// ReleaseTags returns the tags of the data a release changes when it is
+// published or rolled back. The release items must be loaded.
+func ReleaseTags(release models.Release) []string {
+	tags := []string{Listing(release.WorkspaceID)}
+	for _, item := range release.Items {
+		tags = append(tags, Content(item.ContentID))
+	}
+	return tags
+}
@@ -0,0 +1,219 @@
+// internal/cache/conditional.go
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+)
+
+// bufferedResponse holds a response back so validators can be computed from it
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+// policyDirective matches a single Cache-Control directive such as max-age=60
+var policyDirective = regexp.MustCompile(`^[a-z][a-z-]*(=([0-9]+|"[^"]*"))?$`)
+
+// NormalizePolicy checks a Cache-Control policy and joins its directives with ", "
+func NormalizePolicy(policy string) (string, error) {
+	directives := []string{}
+	for _, directive := range strings.Split(policy, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+		if !policyDirective.MatchString(directive) {
+			return "", fmt.Errorf("invalid Cache-Control directive: %s", directive)
+		}
+		directives = append(directives, directive)
+	}
+	return strings.Join(directives, ", "), nil
+}
+
+// SurrogateKeys returns the Surrogate-Key header value of a set of tags
+func SurrogateKeys(tags []string) string {
+	return strings.Join(tags, " ")
+}
+
+// etagMatches reports whether an If-None-Match header lists the entity tag,
+// using the weak comparison RFC 9110 prescribes for it
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the client already holds the current representation
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, strings.TrimPrefix(etag, "W/"))
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// Version records what identifies the data a response shows, such as the IDs
+// and update times of its records. Handlers call it before rendering, with
+// everything the response depends on, so the ETag can be derived from it.
+func Version(r *http.Request, parts ...interface{}) {
+	c := collectorFrom(r)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, part := range parts {
+		if t, ok := part.(time.Time); ok {
+			part = t.UnixNano()
+		}
+		c.version = append(c.version, fmt.Sprint(part))
+	}
+}
+
+// NotModified reports whether the client already holds the response of the
+// version recorded so far. The handler can then return without rendering it,
+// and Conditional answers 304 Not Modified.
+func NotModified(r *http.Request) bool {
+	c := collectorFrom(r)
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seed == "" || len(c.version) == 0 {
+		return false
+	}
+	c.skipped = notModified(r, c.versionTag(), c.lastModified)
+	return c.skipped
+}
+
+// versionTag returns the opaque part of the ETag of the recorded version, or
+// "" without one
+func (c *collector) versionTag() string {
+	if len(c.version) == 0 {
+		return c.etag
+	}
+	sum := sha256.Sum256([]byte(c.seed + "\n" + strings.Join(c.version, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Conditional adds validators, the Cache-Control policy and surrogate keys to
+// successful public responses, answers conditional requests with 304 Not
+// Modified, and compresses JSON bodies for clients that accept it.
+//
+// Handlers that record a Version get a weak ETag derived from it, along with
+// the request path and query and the delivery settings, and can skip
+// rendering responses the client holds. Every encoding of a version shows the
+// same data, so they share the validator. Other responses get a strong ETag
+// hashed from the uncompressed body, with a suffix for each compressed
+// variant, since those are different byte sequences.
+func Conditional(cfg config.DeliveryConfig) func(http.Handler) http.Handler {
+	settings := fmt.Sprintf("%+v", cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, col := withCollector(r)
+			col.mu.Lock()
+			col.seed = settings + "\n" + r.URL.Path + "?" + r.URL.Query().Encode()
+			col.mu.Unlock()
+
+			buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+
+			header := w.Header()
+			body := buf.body.Bytes()
+			if buf.status != http.StatusOK {
+				w.WriteHeader(buf.status)
+				w.Write(body)
+				return
+			}
+
+			col.mu.Lock()
+			tags, lastModified, policy := col.tags, col.lastModified, col.cacheControl
+			version, skipped := col.versionTag(), col.skipped
+			col.mu.Unlock()
+
+			encoding := ""
+			if cfg.Compression && (skipped || isJSON(header.Get("Content-Type"))) {
+				header.Add("Vary", "Accept-Encoding")
+				if len(body) >= cfg.CompressionMinSize {
+					encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+				}
+			}
+
+			sum := sha256.Sum256(body)
+			etag := `"` + hex.EncodeToString(sum[:16]) + encodingSuffix[encoding] + `"`
+			if version != "" {
+				etag = "W/" + version
+			}
+			header.Set("ETag", etag)
+			if !lastModified.IsZero() {
+				header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+			}
+			if policy == "" {
+				policy = cfg.CacheControl
+			}
+			if policy != "" {
+				header.Set("Cache-Control", policy)
+			}
+			if len(tags) > 0 {
+				header.Set("Surrogate-Key", SurrogateKeys(tags))
+			}
+
+			if skipped || notModified(r, etag, lastModified) {
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			if encoding != "" {
+				compressed, err := compress(encoding, body)
+				if err == nil {
+					body = compressed
+					header.Set("Content-Encoding", encoding)
+				} else if version == "" {
+					// Serve the identity variant under its own validator
+					header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+				}
+			}
+			header.Set("Content-Length", fmt.Sprint(len(body)))
+
+			w.WriteHeader(http.StatusOK)
+			if r.Method != http.MethodHead {
+				w.Write(body)
+			}
+		})
+	}
+}
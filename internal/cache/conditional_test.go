@@ -0,0 +1,132 @@
+// internal/cache/conditional_test.go
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+)
+
+// item is a handler showing one record of a version, which counts how often
+// it rendered a response
+type item struct {
+	version  string
+	rendered int
+}
+
+func (h *item) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Tag(r, "content:1")
+	if h.version != "" {
+		Version(r, 1, h.version)
+		if NotModified(r) {
+			return
+		}
+	}
+	h.rendered++
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"data":{"title":"` + strings.Repeat("Hello ", 100) + `"}}`))
+}
+
+// request makes a request through the conditional middleware, and the
+// response cache when store is not nil
+func request(handler http.Handler, store *Cache, method, target string, header http.Header) *httptest.ResponseRecorder {
+	handler = store.Middleware(nil)(handler)
+	handler = Conditional(config.DeliveryConfig{Compression: true, CompressionMinSize: 100})(handler)
+
+	req := httptest.NewRequest(method, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestConditional(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		cached  bool
+		// method and target are those of the revalidating request, and
+		// encoding its Accept-Encoding
+		method   string
+		target   string
+		encoding string
+		// edited changes the version before revalidating
+		edited bool
+		code   int
+		// rendered is how often the handler rendered the response in all
+		rendered int
+	}{
+		{name: "version", version: "v1", method: http.MethodGet, target: "/item", code: http.StatusNotModified, rendered: 1},
+		{name: "version head", version: "v1", method: http.MethodHead, target: "/item", code: http.StatusNotModified, rendered: 1},
+		// Every encoding of a version shares its validator
+		{name: "version other encoding", version: "v1", method: http.MethodGet, target: "/item", encoding: "gzip", code: http.StatusNotModified, rendered: 1},
+		{name: "version edited", version: "v1", edited: true, method: http.MethodGet, target: "/item", code: http.StatusOK, rendered: 2},
+		{name: "version other query", version: "v1", method: http.MethodGet, target: "/item?fields=title", code: http.StatusOK, rendered: 2},
+		{name: "version cached", version: "v1", cached: true, method: http.MethodGet, target: "/item", code: http.StatusNotModified, rendered: 1},
+		{name: "body", method: http.MethodGet, target: "/item", code: http.StatusNotModified, rendered: 2},
+		{name: "body head", method: http.MethodHead, target: "/item", code: http.StatusNotModified, rendered: 2},
+		{name: "body other encoding", method: http.MethodGet, target: "/item", encoding: "gzip", code: http.StatusOK, rendered: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &item{version: tt.version}
+			var store *Cache
+			if tt.cached {
+				store = NewWithStore(NewMemoryStore(), time.Minute)
+			}
+
+			first := request(handler, store, http.MethodGet, "/item", nil)
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("GET = %d with ETag %q, want 200 with one", first.Code, etag)
+			}
+			if weak := strings.HasPrefix(etag, "W/"); weak != (tt.version != "") {
+				t.Errorf("ETag = %s, want weak %v", etag, tt.version != "")
+			}
+
+			if tt.edited {
+				handler.version = "v2"
+			}
+			header := http.Header{"If-None-Match": {etag}}
+			if tt.encoding != "" {
+				header.Set("Accept-Encoding", tt.encoding)
+			}
+			rec := request(handler, store, tt.method, tt.target, header)
+			if rec.Code != tt.code {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.code)
+			}
+			if rec.Code == http.StatusNotModified && rec.Header().Get("ETag") != etag {
+				t.Errorf("ETag of 304 = %s, want %s", rec.Header().Get("ETag"), etag)
+			}
+			if rec.Body.Len() != 0 && (tt.method == http.MethodHead || rec.Code == http.StatusNotModified) {
+				t.Errorf("%s answered with a body of %d bytes", tt.method, rec.Body.Len())
+			}
+			if handler.rendered != tt.rendered {
+				t.Errorf("rendered %d times, want %d", handler.rendered, tt.rendered)
+			}
+		})
+	}
+}
+
+// A HEAD request carries the headers of the GET response without its body
+func TestConditionalHead(t *testing.T) {
+	handler := &item{version: "v1"}
+	get := request(handler, nil, http.MethodGet, "/item", nil)
+	head := request(handler, nil, http.MethodHead, "/item", nil)
+
+	if head.Code != http.StatusOK || head.Body.Len() != 0 {
+		t.Fatalf("HEAD = %d with %d bytes, want 200 without a body", head.Code, head.Body.Len())
+	}
+	for _, key := range []string{"ETag", "Content-Type", "Content-Length", "Vary"} {
+		if got, want := head.Header().Get(key), get.Header().Get(key); got != want {
+			t.Errorf("HEAD %s = %q, want %q", key, got, want)
+		}
+	}
+}
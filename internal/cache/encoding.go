@@ -0,0 +1,86 @@
+// internal/cache/encoding.go
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings offered for JSON responses, in order of preference
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// encodingSuffix distinguishes the entity tags of compressed variants
+var encodingSuffix = map[string]string{
+	"":             "",
+	EncodingBrotli: "-br",
+	EncodingGzip:   "-gzip",
+}
+
+// isJSON reports whether a Content-Type header names JSON
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// negotiateEncoding picks the content coding the client prefers among those
+// offered, or "" for an uncompressed response
+func negotiateEncoding(acceptEncoding string) string {
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{EncodingBrotli, EncodingGzip} {
+		q, ok := weights[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compress encodes a body with a content coding
+func compress(encoding string, body []byte) ([]byte, error) {
+	var out bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&out, brotli.DefaultCompression)
+	default:
+		w = gzip.NewWriter(&out)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
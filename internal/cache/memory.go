@@ -0,0 +1,128 @@
+// internal/cache/memory.go
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// purgeEvery is how many writes pass between sweeps of expired entries
+const purgeEvery = 256
+
+type memoryEntry struct {
+	entry   Entry
+	tags    []string
+	expires time.Time
+}
+
+// MemoryStore keeps cached entries in the memory of the process
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	tags    map[string]map[string]struct{}
+	writes  int
+	// now is the clock entries expire by
+	now func() time.Time
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		tags:    make(map[string]map[string]struct{}),
+		now:     time.Now,
+	}
+}
+
+// Get returns the entry stored under key, or nil if there is none
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if s.now().After(stored.expires) {
+		s.remove(key)
+		return nil, nil
+	}
+
+	entry := stored.entry
+	return &entry, nil
+}
+
+// Set stores an entry for ttl and indexes it under each tag
+func (s *MemoryStore) Set(ctx context.Context, key string, entry Entry, tags []string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	s.entries[key] = memoryEntry{entry: entry, tags: tags, expires: s.now().Add(ttl)}
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	s.writes++
+	if s.writes%purgeEvery == 0 {
+		s.purge()
+	}
+	return nil
+}
+
+// Invalidate removes every entry stored under any of the tags
+func (s *MemoryStore) Invalidate(ctx context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			s.remove(key)
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// Close drops every entry
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = make(map[string]memoryEntry)
+	s.tags = make(map[string]map[string]struct{})
+	return nil
+}
+
+// remove drops an entry and its tag index entries. The caller holds the lock.
+func (s *MemoryStore) remove(key string) {
+	stored, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	delete(s.entries, key)
+	for _, tag := range stored.tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
+
+// purge drops every expired entry. The caller holds the lock.
+func (s *MemoryStore) purge() {
+	now := s.now()
+	for key, stored := range s.entries {
+		if now.After(stored.expires) {
+			s.remove(key)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// internal/cache/middleware.go
+package cache
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+type contextKey struct{}
+
+// collector gathers what a handler reports about the response it is building
+type collector struct {
+	mu           sync.Mutex
+	tags         []string
+	viewed       *Viewed
+	lastModified time.Time
+	cacheControl string
+	// seed is set by Conditional to what else a response depends on besides
+	// its version, which identifies the records it shows
+	seed    string
+	version []string
+	// etag is the version validator of a response served from the cache, and
+	// skipped whether the handler left the response unrendered since the
+	// client holds it already
+	etag    string
+	skipped bool
+}
+
+func collectorFrom(r *http.Request) *collector {
+	c, _ := r.Context().Value(contextKey{}).(*collector)
+	return c
+}
+
+// withCollector returns the request with a collector attached, reusing one an
+// outer middleware attached already
+func withCollector(r *http.Request) (*http.Request, *collector) {
+	if c := collectorFrom(r); c != nil {
+		return r, c
+	}
+	c := &collector{}
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, c)), c
+}
+
+// fill restores what a handler reported from a cached response
+func (c *collector) fill(entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tags = entry.Tags
+	c.viewed = entry.Viewed
+	c.lastModified = entry.LastModified
+	c.cacheControl = entry.CacheControl
+	c.etag = entry.ETag
+}
+
+// Tag records the data a response depends on. Only tagged responses are cached.
+func Tag(r *http.Request, tags ...string) {
+	c := collectorFrom(r)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tag := range tags {
+		seen := false
+		for _, existing := range c.tags {
+			if existing == tag {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			c.tags = append(c.tags, tag)
+		}
+	}
+}
+
+// TagContent tags a response with the content items it shows and the
+// workspaces, content types and authors they belong to
+func TagContent(r *http.Request, contents ...models.Content) {
+	tags := make([]string, 0, len(contents)*4)
+	for _, content := range contents {
+		tags = append(tags, Content(content.ID), Workspace(content.WorkspaceID))
+		if content.ContentTypeID != 0 {
+			tags = append(tags, ContentType(content.ContentTypeID))
+		}
+		if content.AuthorID != 0 {
+			tags = append(tags, User(content.AuthorID))
+		}
+	}
+	Tag(r, tags...)
+}
+
+// TagWorkspace tags a response with the workspace it was served from and
+// applies the workspace's Cache-Control policy
+func TagWorkspace(r *http.Request, workspace models.Workspace) {
+	Tag(r, Workspace(workspace.ID))
+
+	c := collectorFrom(r)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cacheControl = workspace.CacheControl
+}
+
+// Modified records when the data a response shows last changed. Only handlers
+// that know every record their response depends on should call it; zero times
+// are ignored.
+func Modified(r *http.Request, times ...time.Time) {
+	c := collectorFrom(r)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range times {
+		if t.After(c.lastModified) {
+			c.lastModified = t
+		}
+	}
+}
+
+// MarkViewed records that a response shows a single content item, so views
+// served from the cache are still counted
+func MarkViewed(r *http.Request, content models.Content) {
+	c := collectorFrom(r)
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.viewed = &Viewed{
+		ContentID:     content.ID,
+		WorkspaceID:   content.WorkspaceID,
+		ContentTypeID: content.ContentTypeID,
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// requestKey identifies the response to an anonymous GET or HEAD request
+func requestKey(r *http.Request) string {
+	return r.Host + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// Middleware serves anonymous GET and HEAD requests from the cache and stores the
+// successful responses handlers tagged. onHit is called for every response
+// served from the cache.
+func (c *Cache) Middleware(onHit func(*http.Request, Entry)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if c == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, col := withCollector(r)
+
+			key := requestKey(r)
+			entry, err := c.store.Get(r.Context(), key)
+			if err != nil {
+				slog.Warn("Failed to read cached response", "key", key, "error", err)
+			}
+			if entry != nil {
+				col.fill(*entry)
+				if onHit != nil {
+					onHit(r, *entry)
+				}
+				w.Header().Set("Content-Type", entry.ContentType)
+				w.Header().Set("X-Cache", "HIT")
+				w.WriteHeader(entry.Status)
+				w.Write(entry.Body)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			w.Header().Set("X-Cache", "MISS")
+			next.ServeHTTP(rec, r)
+
+			col.mu.Lock()
+			defer col.mu.Unlock()
+			if rec.status != http.StatusOK || len(col.tags) == 0 || col.skipped {
+				return
+			}
+
+			entry = &Entry{
+				Status:       rec.status,
+				ContentType:  rec.Header().Get("Content-Type"),
+				Body:         rec.body.Bytes(),
+				Viewed:       col.viewed,
+				Tags:         col.tags,
+				LastModified: col.lastModified,
+				CacheControl: col.cacheControl,
+				ETag:         col.versionTag(),
+			}
+			if err := c.store.Set(r.Context(), key, *entry, col.tags, c.ttl); err != nil {
+				slog.Warn("Failed to cache response", "key", key, "error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// internal/cache/redis.go
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key prefixes of the Redis store
+const (
+	redisEntryPrefix = "floe:cache:"
+	redisTagPrefix   = "floe:cache-tag:"
+)
+
+// RedisStore keeps cached entries in Redis, so they are shared between instances
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// DialRedis connects to the Redis server at a redis:// URL
+func DialRedis(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return NewRedisStore(client), nil
+}
+
+// NewRedisStore creates a store on top of a Redis client
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{
+		client: client,
+	}
+}
+
+// Get returns the entry stored under key, or nil if there is none
+func (s *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := s.client.Get(ctx, redisEntryPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Set stores an entry for ttl and indexes it under each tag. Tag sets live as
+// long as the newest entry indexed in them.
+func (s *RedisStore) Set(ctx context.Context, key string, entry Entry, tags []string, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisEntryPrefix+key, data, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, redisTagPrefix+tag, key)
+			pipe.Expire(ctx, redisTagPrefix+tag, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Invalidate removes every entry stored under any of the tags
+func (s *RedisStore) Invalidate(ctx context.Context, tags ...string) error {
+	keys := []string{}
+	for _, tag := range tags {
+		members, err := s.client.SMembers(ctx, redisTagPrefix+tag).Result()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			keys = append(keys, redisEntryPrefix+member)
+		}
+		keys = append(keys, redisTagPrefix+tag)
+	}
+
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// Close closes the Redis connection
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
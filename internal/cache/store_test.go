@@ -0,0 +1,191 @@
+// internal/cache/store_test.go
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// backend is a store under test along with a way to move its clock forward
+type backend struct {
+	name string
+	open func(t *testing.T) (Store, func(time.Duration))
+}
+
+var backends = []backend{
+	{
+		name: "memory",
+		open: func(t *testing.T) (Store, func(time.Duration)) {
+			store := NewMemoryStore()
+			now := time.Now()
+			store.now = func() time.Time { return now }
+			return store, func(d time.Duration) { now = now.Add(d) }
+		},
+	},
+	{
+		name: "redis",
+		open: func(t *testing.T) (Store, func(time.Duration)) {
+			server := miniredis.RunT(t)
+			store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+			t.Cleanup(func() { store.Close() })
+			return store, server.FastForward
+		},
+	},
+}
+
+func entry(body string, tags ...string) Entry {
+	return Entry{
+		Status:       200,
+		ContentType:  "application/json",
+		Body:         []byte(body),
+		Tags:         tags,
+		LastModified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// get fetches a key and returns its body, or "" if it is not stored
+func get(t *testing.T, store Store, key string) string {
+	t.Helper()
+	got, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	if got == nil {
+		return ""
+	}
+	return string(got.Body)
+}
+
+func set(t *testing.T, store Store, key string, e Entry, ttl time.Duration) {
+	t.Helper()
+	if err := store.Set(context.Background(), key, e, e.Tags, ttl); err != nil {
+		t.Fatalf("Set(%q): %v", key, err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, store Store, advance func(time.Duration))
+	}{
+		{
+			name: "get and set",
+			run: func(t *testing.T, store Store, advance func(time.Duration)) {
+				if got := get(t, store, "missing"); got != "" {
+					t.Fatalf("missing key = %q, want nothing", got)
+				}
+
+				want := entry(`{"id":1}`, "content:1")
+				want.Viewed = &Viewed{ContentID: 1, WorkspaceID: 2, ContentTypeID: 3}
+				want.CacheControl = "public, max-age=60"
+				set(t, store, "a", want, time.Minute)
+
+				got, err := store.Get(context.Background(), "a")
+				if err != nil || got == nil {
+					t.Fatalf("Get(a) = %v, %v", got, err)
+				}
+				if got.Status != want.Status || got.ContentType != want.ContentType ||
+					string(got.Body) != string(want.Body) || got.CacheControl != want.CacheControl ||
+					!got.LastModified.Equal(want.LastModified) {
+					t.Errorf("Get(a) = %+v, want %+v", got, want)
+				}
+				if got.Viewed == nil || *got.Viewed != *want.Viewed {
+					t.Errorf("Get(a).Viewed = %v, want %v", got.Viewed, want.Viewed)
+				}
+
+				set(t, store, "a", entry("second"), time.Minute)
+				if got := get(t, store, "a"); got != "second" {
+					t.Errorf("overwritten key = %q, want second", got)
+				}
+			},
+		},
+		{
+			name: "ttl expiry",
+			run: func(t *testing.T, store Store, advance func(time.Duration)) {
+				set(t, store, "short", entry("short"), time.Second)
+				set(t, store, "long", entry("long"), time.Minute)
+
+				advance(500 * time.Millisecond)
+				if got := get(t, store, "short"); got != "short" {
+					t.Errorf("before expiry = %q, want short", got)
+				}
+
+				advance(time.Second)
+				if got := get(t, store, "short"); got != "" {
+					t.Errorf("after expiry = %q, want nothing", got)
+				}
+				if got := get(t, store, "long"); got != "long" {
+					t.Errorf("longer ttl = %q, want long", got)
+				}
+			},
+		},
+		{
+			name: "tag invalidation",
+			run: func(t *testing.T, store Store, advance func(time.Duration)) {
+				set(t, store, "item", entry("item", "content:1", "listing:1"), time.Minute)
+				set(t, store, "list", entry("list", "listing:1"), time.Minute)
+				set(t, store, "other", entry("other", "content:2", "listing:2"), time.Minute)
+
+				if err := store.Invalidate(context.Background(), "content:1"); err != nil {
+					t.Fatalf("Invalidate: %v", err)
+				}
+				if got := get(t, store, "item"); got != "" {
+					t.Errorf("invalidated entry = %q, want nothing", got)
+				}
+				if got := get(t, store, "list"); got != "list" {
+					t.Errorf("untouched entry = %q, want list", got)
+				}
+
+				if err := store.Invalidate(context.Background(), "listing:1", "listing:2", "unknown"); err != nil {
+					t.Fatalf("Invalidate: %v", err)
+				}
+				for _, key := range []string{"list", "other"} {
+					if got := get(t, store, key); got != "" {
+						t.Errorf("%s after invalidating its tags = %q, want nothing", key, got)
+					}
+				}
+
+				// A key stored again after invalidation is cached under its new tags
+				set(t, store, "item", entry("again", "content:3"), time.Minute)
+				if err := store.Invalidate(context.Background(), "content:1"); err != nil {
+					t.Fatalf("Invalidate: %v", err)
+				}
+				if got := get(t, store, "item"); got != "again" {
+					t.Errorf("re-stored entry = %q, want again", got)
+				}
+			},
+		},
+	}
+
+	for _, b := range backends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				store, advance := b.open(t)
+				tt.run(t, store, advance)
+			})
+		}
+	}
+}
+
+func TestMemoryStorePurge(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	set(t, store, "expired", entry("expired", "content:1"), time.Second)
+	now = now.Add(time.Minute)
+	for i := 1; i < purgeEvery; i++ {
+		set(t, store, "fresh", entry("fresh", "content:2"), time.Minute)
+	}
+
+	if _, ok := store.entries["expired"]; ok {
+		t.Error("expired entry survived a purge")
+	}
+	if _, ok := store.tags["content:1"]; ok {
+		t.Error("tag of an expired entry survived a purge")
+	}
+}
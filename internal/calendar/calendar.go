@@ -0,0 +1,171 @@
+// internal/calendar/calendar.go
+package calendar
+
+import (
+	"sort"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/releases"
+)
+
+// Entry types
+const (
+	TypePublished          = "published"
+	TypeUnpublished        = "unpublished"
+	TypeScheduledPublish   = "scheduled_publish"
+	TypeScheduledUnpublish = "scheduled_unpublish"
+	TypeScheduledUpdate    = "scheduled_update"
+)
+
+// Entry is a single dated event of a content item
+type Entry struct {
+	Type        string    `json:"type"`
+	At          time.Time `json:"at"`
+	ContentID   uint      `json:"content_id"`
+	WorkspaceID uint      `json:"workspace_id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Status      string    `json:"status"`
+	HasDraft    bool      `json:"has_draft"`
+	ReleaseID   *uint     `json:"release_id,omitempty"`
+	ReleaseName string    `json:"release_name,omitempty"`
+}
+
+// Day groups the entries that fall on the same calendar date
+type Day struct {
+	Date    string  `json:"date"`
+	Entries []Entry `json:"entries"`
+}
+
+// scheduledTypes maps release item actions to the entry type of a scheduled release
+var scheduledTypes = map[string]string{
+	releases.ActionPublish:   TypeScheduledPublish,
+	releases.ActionUnpublish: TypeScheduledUnpublish,
+	releases.ActionUpdate:    TypeScheduledUpdate,
+}
+
+// Entries collects the calendar entries of the given workspaces between from and to.
+// Publish dates come from the content itself, scheduled changes from releases.
+func Entries(database *db.DB, workspaceIDs []uint, from, to time.Time) ([]Entry, error) {
+	entries := []Entry{}
+	if len(workspaceIDs) == 0 {
+		return entries, nil
+	}
+
+	var published []models.Content
+	if err := database.Where("workspace_id IN ? AND status = ? AND published_at >= ? AND published_at < ?",
+		workspaceIDs, "published", from, to).Find(&published).Error; err != nil {
+		return nil, err
+	}
+
+	var bundles []models.Release
+	if err := database.Preload("Items").
+		Where("workspace_id IN ?", workspaceIDs).
+		Where("(status = ? AND scheduled_at >= ? AND scheduled_at < ?) OR (status = ? AND published_at >= ? AND published_at < ?)",
+			releases.StatusScheduled, from, to, releases.StatusPublished, from, to).
+		Find(&bundles).Error; err != nil {
+		return nil, err
+	}
+
+	contents := map[uint]models.Content{}
+	for _, content := range published {
+		contents[content.ID] = content
+	}
+
+	missing := []uint{}
+	for _, release := range bundles {
+		for _, item := range release.Items {
+			if _, ok := contents[item.ContentID]; !ok {
+				missing = append(missing, item.ContentID)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		var extra []models.Content
+		if err := database.Where("id IN ?", missing).Find(&extra).Error; err != nil {
+			return nil, err
+		}
+		for _, content := range extra {
+			contents[content.ID] = content
+		}
+	}
+
+	drafts := map[uint]bool{}
+	if len(contents) > 0 {
+		ids := make([]uint, 0, len(contents))
+		for id := range contents {
+			ids = append(ids, id)
+		}
+		var withDraft []uint
+		if err := database.Model(&models.ContentDraft{}).Where("content_id IN ?", ids).Pluck("content_id", &withDraft).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range withDraft {
+			drafts[id] = true
+		}
+	}
+
+	entry := func(kind string, at time.Time, content models.Content) Entry {
+		return Entry{
+			Type:        kind,
+			At:          at,
+			ContentID:   content.ID,
+			WorkspaceID: content.WorkspaceID,
+			Title:       content.Title,
+			Slug:        content.Slug,
+			Status:      content.Status,
+			HasDraft:    drafts[content.ID],
+		}
+	}
+
+	for _, content := range published {
+		entries = append(entries, entry(TypePublished, *content.PublishedAt, content))
+	}
+
+	for _, release := range bundles {
+		releaseID := release.ID
+		for _, item := range release.Items {
+			content, ok := contents[item.ContentID]
+			if !ok {
+				continue
+			}
+
+			var e Entry
+			switch {
+			case release.Status == releases.StatusScheduled:
+				e = entry(scheduledTypes[item.Action], *release.ScheduledAt, content)
+			case item.Action == releases.ActionUnpublish:
+				// Publishing is already covered by the content's own publish date
+				e = entry(TypeUnpublished, *release.PublishedAt, content)
+			default:
+				continue
+			}
+
+			e.ReleaseID = &releaseID
+			e.ReleaseName = release.Name
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+
+	return entries, nil
+}
+
+// GroupByDay groups sorted entries by their date in the given location
+func GroupByDay(entries []Entry, loc *time.Location) []Day {
+	days := []Day{}
+	for _, e := range entries {
+		date := e.At.In(loc).Format("2006-01-02")
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, Day{Date: date, Entries: []Entry{}})
+		}
+		last := &days[len(days)-1]
+		last.Entries = append(last.Entries, e)
+	}
+	return days
+}
@@ -0,0 +1,93 @@
+// internal/calendar/ics.go
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// icsTime is the UTC date-time format used by iCalendar
+const icsTime = "20060102T150405Z"
+
+// summaries holds the event title prefix of each entry type
+var summaries = map[string]string{
+	TypePublished:          "Published",
+	TypeUnpublished:        "Unpublished",
+	TypeScheduledPublish:   "Publish",
+	TypeScheduledUnpublish: "Unpublish",
+	TypeScheduledUpdate:    "Update",
+}
+
+// icsEscape escapes text values as required by RFC 5545
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteICS writes the entries as an iCalendar document
+func WriteICS(w io.Writer, name string, entries []Entry) error {
+	ics := &icsWriter{w: w}
+	now := time.Now().UTC().Format(icsTime)
+
+	ics.line("BEGIN:VCALENDAR")
+	ics.line("VERSION:2.0")
+	ics.line("PRODID:-//Floe CMS//Editorial Calendar//EN")
+	ics.line("CALSCALE:GREGORIAN")
+	ics.line("METHOD:PUBLISH")
+	ics.line("X-WR-CALNAME:" + icsEscape.Replace(name))
+
+	for _, e := range entries {
+		uid := fmt.Sprintf("%s-%d", e.Type, e.ContentID)
+		if e.ReleaseID != nil {
+			uid = fmt.Sprintf("%s-r%d", uid, *e.ReleaseID)
+		}
+
+		description := "Status: " + e.Status
+		if e.HasDraft {
+			description += "\nHas unpublished changes"
+		}
+		if e.ReleaseName != "" {
+			description += "\nRelease: " + e.ReleaseName
+		}
+
+		ics.line("BEGIN:VEVENT")
+		ics.line("UID:" + uid + "@floe-cms")
+		ics.line("DTSTAMP:" + now)
+		ics.line("DTSTART:" + e.At.UTC().Format(icsTime))
+		ics.line("DTEND:" + e.At.Add(30*time.Minute).UTC().Format(icsTime))
+		ics.line("SUMMARY:" + icsEscape.Replace(summaries[e.Type]+": "+e.Title))
+		ics.line("DESCRIPTION:" + icsEscape.Replace(description))
+		ics.line("CATEGORIES:" + strings.ToUpper(e.Type))
+		ics.line("END:VEVENT")
+	}
+
+	ics.line("END:VCALENDAR")
+	return ics.err
+}
+
+// icsWriter writes CRLF terminated content lines folded at 75 octets
+type icsWriter struct {
+	w   io.Writer
+	err error
+}
+
+// line writes a single content line, folding it where needed
+func (ics *icsWriter) line(s string) {
+	if ics.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+
+	_, ics.err = io.WriteString(ics.w, b.String())
+}
@@ -4,6 +4,7 @@ package config
 import (
 	"math/rand"
 	"os"
+	"reflect"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -11,11 +12,17 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Storage  StorageConfig  `mapstructure:"storage"`
-	Cache    CacheConfig    `mapstructure:"cache"`
+	Server    ServerConfig    `mapstructure:"server"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Images    ImagesConfig    `mapstructure:"images"`
+	Uploads   UploadsConfig   `mapstructure:"uploads"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	Delivery  DeliveryConfig  `mapstructure:"delivery"`
+	Export    ExportConfig    `mapstructure:"export"`
+	Analytics AnalyticsConfig `mapstructure:"analytics"`
+	Links     LinksConfig     `mapstructure:"links"`
 }
 
 // ServerConfig holds server related configuration
@@ -59,8 +66,33 @@ type AuthConfig struct {
 
 // StorageConfig holds storage related configuration
 type StorageConfig struct {
-	Type       string `mapstructure:"type"`
-	UploadsDir string `mapstructure:"uploads_dir"`
+	Type       string   `mapstructure:"type"`
+	UploadsDir string   `mapstructure:"uploads_dir"`
+	S3         S3Config `mapstructure:"s3"`
+	// URLSecret signs the URLs of private files; derived from the JWT secret if empty
+	URLSecret string `mapstructure:"url_secret"`
+	// PrivateURLExpiry is how many seconds the URLs of private files stay valid
+	PrivateURLExpiry int `mapstructure:"private_url_expiry"`
+}
+
+// S3Config holds the configuration of S3-compatible object storage
+type S3Config struct {
+	// Endpoint is the URL of the S3 API, such as http://localhost:9000
+	Endpoint        string `mapstructure:"endpoint"`
+	Region          string `mapstructure:"region"`
+	Bucket          string `mapstructure:"bucket"`
+	Prefix          string `mapstructure:"prefix"`
+	PathStyle       bool   `mapstructure:"path_style"`
+	AccessKeyID     string `mapstructure:"access_key_id"`
+	SecretAccessKey string `mapstructure:"secret_access_key"`
+	// PublicURL is the base URL objects are publicly readable at; without it
+	// files get presigned URLs. Private media cannot be stored with it unless
+	// files are proxied.
+	PublicURL     string `mapstructure:"public_url"`
+	PresignExpiry int    `mapstructure:"presign_expiry"`
+	// Proxy serves files through /uploads/ and points their URLs there
+	// instead of at the bucket
+	Proxy bool `mapstructure:"proxy"`
 }
 
 // CacheConfig holds cache related configuration
@@ -70,6 +102,88 @@ type CacheConfig struct {
 	TTL      int    `mapstructure:"ttl"`
 }
 
+// DeliveryConfig holds configuration for the public delivery API
+type DeliveryConfig struct {
+	AuthorFields     []string `mapstructure:"author_fields"`
+	StripInternalIDs bool     `mapstructure:"strip_internal_ids"`
+	// CacheControl is the Cache-Control policy of workspaces that set none
+	CacheControl       string `mapstructure:"cache_control"`
+	Compression        bool   `mapstructure:"compression"`
+	CompressionMinSize int    `mapstructure:"compression_min_size"`
+}
+
+// ExportConfig holds static export related configuration
+type ExportConfig struct {
+	OutputDir string `mapstructure:"output_dir"`
+	PageSize  int    `mapstructure:"page_size"`
+}
+
+// AnalyticsConfig holds content view analytics configuration
+type AnalyticsConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	BufferSize    int  `mapstructure:"buffer_size"`
+	FlushInterval int  `mapstructure:"flush_interval"`
+}
+
+// ImagesConfig holds image processing configuration
+type ImagesConfig struct {
+	// Variants are generated for every uploaded JPEG, PNG and WebP image
+	Variants []VariantConfig `mapstructure:"variants"`
+	// WebP adds a WebP copy of each variant when it is smaller than the original format
+	WebP    bool `mapstructure:"webp"`
+	Quality int  `mapstructure:"quality"`
+	// MaxPixels is the largest image, in pixels, that is decoded
+	MaxPixels int `mapstructure:"max_pixels"`
+	// MaxTransformSize is the largest width or height of on-the-fly transforms
+	MaxTransformSize int `mapstructure:"max_transform_size"`
+	// TransformSecret signs transform URLs; derived from the JWT secret if empty
+	TransformSecret string `mapstructure:"transform_secret"`
+}
+
+// VariantConfig describes a generated image variant
+type VariantConfig struct {
+	Name   string `mapstructure:"name"`
+	Width  int    `mapstructure:"width"`
+	Height int    `mapstructure:"height"`
+	Fit    string `mapstructure:"fit"`
+}
+
+// UploadsConfig holds the checks media uploads must pass
+type UploadsConfig struct {
+	// AllowedTypes lists the MIME types, wildcards such as image/* and file
+	// extensions such as .pdf accepted by workspaces without an allowlist
+	AllowedTypes []string `mapstructure:"allowed_types"`
+	// MaxSize is the size limit in MiB of types without a limit of their own
+	MaxSize int                 `mapstructure:"max_size"`
+	Limits  []UploadLimitConfig `mapstructure:"limits"`
+	// SanitizeSVG strips scripts from SVGs instead of rejecting them
+	SanitizeSVG bool `mapstructure:"sanitize_svg"`
+	// StripMetadata removes EXIF, XMP and similar metadata, such as GPS
+	// coordinates and camera details, from uploaded images of workspaces
+	// that do not set their own preference
+	StripMetadata bool `mapstructure:"strip_metadata"`
+	// QuotaSize is the total size in MiB and QuotaFiles the number of media
+	// files of workspaces without quotas of their own; 0 means unlimited
+	QuotaSize  int `mapstructure:"quota_size"`
+	QuotaFiles int `mapstructure:"quota_files"`
+	// PartialExpiry is how many hours a resumable upload is kept after it was
+	// last written to
+	PartialExpiry int `mapstructure:"partial_expiry"`
+}
+
+// UploadLimitConfig is the size limit of a MIME type or wildcard
+type UploadLimitConfig struct {
+	Type    string `mapstructure:"type"`
+	MaxSize int    `mapstructure:"max_size"`
+}
+
+// LinksConfig holds broken link checker configuration
+type LinksConfig struct {
+	// CheckInterval is the number of minutes between link checks; 0 turns the
+	// background check off
+	CheckInterval int `mapstructure:"check_interval"`
+}
+
 // Load loads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
 	// Set defaults
@@ -82,6 +196,10 @@ func Load(configPath string) (*Config, error) {
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 
+	// AutomaticEnv only applies to keys viper knows of, so bind every key
+	// for settings missing from the configuration file
+	bindEnv(v, "", reflect.TypeOf(*config))
+
 	// Try to read config file, don't error if not found
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -100,6 +218,15 @@ func Load(configPath string) (*Config, error) {
 		config.Auth.JWTSecret = jwtSecret
 	}
 
+	// S3 credentials are read from the environment like the JWT secret, so
+	// they need not be written to the configuration file
+	if accessKey := os.Getenv("FLOE_STORAGE_S3_ACCESS_KEY_ID"); accessKey != "" {
+		config.Storage.S3.AccessKeyID = accessKey
+	}
+	if secretKey := os.Getenv("FLOE_STORAGE_S3_SECRET_ACCESS_KEY"); secretKey != "" {
+		config.Storage.S3.SecretAccessKey = secretKey
+	}
+
 	// Generate a JWT secret if not set
 	if config.Auth.JWTSecret == "" {
 		config.Auth.JWTSecret = generateRandomString(32)
@@ -108,6 +235,26 @@ func Load(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// bindEnv binds the environment variable of every key of a configuration struct
+func bindEnv(v *viper.Viper, prefix string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			bindEnv(v, key, field.Type)
+			continue
+		}
+		v.BindEnv(key)
+	}
+}
+
 // defaultConfig returns a configuration with default values
 func defaultConfig() *Config {
 	return &Config{
@@ -142,14 +289,67 @@ func defaultConfig() *Config {
 			RateLimitExpiry:    60,  // per minute
 		},
 		Storage: StorageConfig{
-			Type:       "local",
-			UploadsDir: "./uploads",
+			Type:             "local",
+			UploadsDir:       "./uploads",
+			PrivateURLExpiry: 3600, // 1 hour
+			S3: S3Config{
+				Endpoint:      "https://s3.amazonaws.com",
+				Region:        "us-east-1",
+				PresignExpiry: 3600, // 1 hour
+			},
+		},
+		Images: ImagesConfig{
+			Variants: []VariantConfig{
+				{Name: "thumbnail", Width: 200, Height: 200, Fit: "cover"},
+				{Name: "medium", Width: 800, Height: 800, Fit: "contain"},
+				{Name: "large", Width: 1600, Height: 1600, Fit: "contain"},
+			},
+			WebP:             true,
+			Quality:          85,
+			MaxPixels:        50000000,
+			MaxTransformSize: 2400,
+		},
+		Uploads: UploadsConfig{
+			AllowedTypes: []string{
+				"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/svg+xml",
+				"application/pdf", "text/plain", "text/csv",
+				"video/mp4", "video/webm", "audio/mpeg", "audio/ogg", "audio/wav",
+			},
+			MaxSize: 32,
+			Limits: []UploadLimitConfig{
+				{Type: "image/*", MaxSize: 20},
+				{Type: "video/*", MaxSize: 256},
+			},
+			SanitizeSVG:   true,
+			StripMetadata: true,
+			QuotaSize:     0, // unlimited
+			QuotaFiles:    0, // unlimited
+			PartialExpiry: 24, // 1 day
 		},
 		Cache: CacheConfig{
 			Type:     "memory",
 			RedisURL: "redis://localhost:6379/0",
 			TTL:      300, // 5 minutes
 		},
+		Delivery: DeliveryConfig{
+			AuthorFields:       []string{"display_name", "avatar", "bio"},
+			StripInternalIDs:   false,
+			CacheControl:       "public, max-age=60",
+			Compression:        true,
+			CompressionMinSize: 1024,
+		},
+		Export: ExportConfig{
+			OutputDir: "./export",
+			PageSize:  10,
+		},
+		Analytics: AnalyticsConfig{
+			Enabled:       true,
+			BufferSize:    1024,
+			FlushInterval: 10, // 10 seconds
+		},
+		Links: LinksConfig{
+			CheckInterval: 60, // 1 hour
+		},
 	}
 }
 
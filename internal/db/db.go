@@ -69,6 +69,20 @@ func MigrateDatabase(db *DB) error {
 		&models.ContentType{},
 		&models.UserWorkspace{},
 		&models.RefreshToken{},
+		&models.ContentDraft{},
+		&models.ContentView{},
+		&models.RelatedContent{},
+		&models.MediaVariant{},
+		&models.MediaFolder{},
+		&models.MediaVersion{},
+		&models.StorageUsage{},
+		&models.Upload{},
+		&models.UploadChunk{},
+		&models.MediaUsage{},
+		&models.BrokenLink{},
+		&models.LinkCheck{},
+		&models.Release{},
+		&models.ReleaseItem{},
 	)
 }
 
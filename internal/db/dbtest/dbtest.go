@@ -0,0 +1,35 @@
+// internal/db/dbtest/dbtest.go
+
+// Package dbtest opens migrated databases for tests
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/randilt/floe-cms/internal/db"
+)
+
+// New returns a migrated SQLite database of its own for a test, which is
+// closed when the test ends
+func New(t testing.TB) *db.DB {
+	t.Helper()
+
+	// A file rather than memory, so that every connection of the pool sees the same database
+	dsn := filepath.Join(t.TempDir(), "floe.db") + "?_busy_timeout=5000"
+	gormDB, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	database := &db.DB{DB: gormDB}
+	t.Cleanup(func() { database.Close() })
+
+	if err := db.MigrateDatabase(database); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	return database
+}
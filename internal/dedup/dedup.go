@@ -0,0 +1,125 @@
+// internal/dedup/dedup.go
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// Find returns the earliest media item of a workspace whose file has a hash
+// and is still stored, or a zero media item if there is none
+func Find(database *db.DB, store storage.Manager, workspaceID uint, hash string) (models.Media, error) {
+	var media models.Media
+	if hash == "" {
+		return media, nil
+	}
+	if err := database.Preload("Variants").
+		Where("workspace_id = ? AND hash = ?", workspaceID, hash).
+		Order("id asc").Limit(1).Find(&media).Error; err != nil {
+		return models.Media{}, err
+	}
+	if media.ID == 0 {
+		return media, nil
+	}
+
+	// A file lost from storage is uploaded again rather than shared
+	file, err := store.Open(media.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.Media{}, nil
+	}
+	if err != nil {
+		return models.Media{}, err
+	}
+	file.Close()
+	return media, nil
+}
+
+// Shared returns the number of other media items, and versions of other
+// media items, using the stored file of a media item. The file may only be
+// deleted with the last of them. It takes a transaction, so that the count
+// can be taken along with deleting the media item.
+func Shared(database *gorm.DB, media models.Media) (int64, error) {
+	var count, versions int64
+	if err := database.Model(&models.Media{}).Where("file_path = ? AND id <> ?", media.FilePath, media.ID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	err := database.Model(&models.MediaVersion{}).Where("file_path = ? AND media_id <> ?", media.FilePath, media.ID).Count(&versions).Error
+	return count + versions, err
+}
+
+// VersionShared returns the number of media items and other media versions
+// using the stored file of a media version
+func VersionShared(database *db.DB, version models.MediaVersion) (int64, error) {
+	var count, versions int64
+	if err := database.Model(&models.Media{}).Where("file_path = ?", version.FilePath).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	err := database.Model(&models.MediaVersion{}).Where("file_path = ? AND id <> ?", version.FilePath, version.ID).Count(&versions).Error
+	return count + versions, err
+}
+
+// CopyVariants records the image variants of a media item for another media
+// item sharing its file
+func CopyVariants(database *db.DB, from models.Media, to *models.Media) error {
+	if len(from.Variants) == 0 {
+		return nil
+	}
+
+	variants := make([]models.MediaVariant, 0, len(from.Variants))
+	for _, v := range from.Variants {
+		v.ID = 0
+		v.CreatedAt, v.UpdatedAt = to.CreatedAt, to.UpdatedAt
+		v.MediaID = to.ID
+		variants = append(variants, v)
+	}
+	if err := database.Create(&variants).Error; err != nil {
+		return err
+	}
+	to.Variants = variants
+	return nil
+}
+
+// Hash returns the hex SHA-256 of a stored file
+func Hash(store storage.Manager, filePath string) (string, error) {
+	file, err := store.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// BackfillHashes hashes the files of media uploaded before hashes were
+// recorded. Such media keeps its own file even if it duplicates another.
+func BackfillHashes(database *db.DB, store storage.Manager) {
+	var media []models.Media
+	if err := database.Select("id", "file_path").Where("hash = ? OR hash IS NULL", "").Find(&media).Error; err != nil {
+		slog.Error("Failed to fetch media for hashing", "error", err)
+		return
+	}
+
+	for _, m := range media {
+		hash, err := Hash(store, m.FilePath)
+		if err != nil {
+			slog.Error("Failed to hash media file", "media_id", m.ID, "error", err)
+			continue
+		}
+		if err := database.Model(&models.Media{}).Where("id = ?", m.ID).UpdateColumn("hash", hash).Error; err != nil {
+			slog.Error("Failed to record media hash", "media_id", m.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// internal/delivery/delivery.go
+package delivery
+
+import (
+	"strings"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// Object is the public representation of a record
+type Object map[string]interface{}
+
+// authorFields lists the user attributes that may ever be exposed publicly
+var authorFields = map[string]bool{
+	"display_name": true,
+	"first_name":   true,
+	"last_name":    true,
+	"avatar":       true,
+	"bio":          true,
+}
+
+// Serializer builds the representations returned by unauthenticated routes.
+// Every attribute is copied explicitly so new model fields are never leaked by accident.
+type Serializer struct {
+	storage          storage.Manager
+	authorFields     []string
+	stripInternalIDs bool
+}
+
+// NewSerializer creates a new public serializer
+func NewSerializer(cfg config.DeliveryConfig, storage storage.Manager) *Serializer {
+	fields := []string{}
+	for _, name := range cfg.AuthorFields {
+		name = strings.TrimSpace(name)
+		if authorFields[name] {
+			fields = append(fields, name)
+		}
+	}
+
+	return &Serializer{
+		storage:          storage,
+		authorFields:     fields,
+		stripInternalIDs: cfg.StripInternalIDs,
+	}
+}
+
+// StripInternalIDs reports whether database IDs are hidden from public responses
+func (s *Serializer) StripInternalIDs() bool {
+	return s.stripInternalIDs
+}
+
+// id sets an ID attribute unless internal IDs are stripped
+func (s *Serializer) id(obj Object, key string, value interface{}) {
+	if s.stripInternalIDs {
+		return
+	}
+	obj[key] = value
+}
+
+// Author returns the public representation of a content author
+func (s *Serializer) Author(user models.User) Object {
+	obj := Object{}
+	s.id(obj, "id", user.ID)
+
+	for _, name := range s.authorFields {
+		switch name {
+		case "display_name":
+			obj["display_name"] = DisplayName(user)
+		case "first_name":
+			obj["first_name"] = user.FirstName
+		case "last_name":
+			obj["last_name"] = user.LastName
+		case "avatar":
+			obj["avatar"] = user.Avatar
+		case "bio":
+			obj["bio"] = user.Bio
+		}
+	}
+
+	return obj
+}
+
+// DisplayName returns the public name of a user, falling back to their full name
+func DisplayName(user models.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// ContentType returns the public representation of a content type
+func (s *Serializer) ContentType(contentType models.ContentType) Object {
+	obj := Object{
+		"name":        contentType.Name,
+		"slug":        contentType.Slug,
+		"description": contentType.Description,
+		"fields":      contentType.Fields,
+	}
+	s.id(obj, "id", contentType.ID)
+
+	return obj
+}
+
+// Content returns the public representation of a content item.
+// Relations are only included when they were loaded.
+func (s *Serializer) Content(content models.Content) Object {
+	obj := Object{
+		"title":        content.Title,
+		"slug":         content.Slug,
+		"body":         content.Body,
+		"status":       content.Status,
+		"published_at": content.PublishedAt,
+		"meta_data":    content.MetaData,
+		"fields":       content.Fields,
+		"position":     content.Position,
+		"created_at":   content.CreatedAt,
+		"updated_at":   content.UpdatedAt,
+	}
+	s.id(obj, "id", content.ID)
+	s.id(obj, "workspace_id", content.WorkspaceID)
+	s.id(obj, "content_type_id", content.ContentTypeID)
+	s.id(obj, "author_id", content.AuthorID)
+	s.id(obj, "parent_id", content.ParentID)
+
+	if content.Author.ID != 0 {
+		obj["author"] = s.Author(content.Author)
+	}
+	if content.ContentType.ID != 0 {
+		obj["content_type"] = s.ContentType(content.ContentType)
+	}
+
+	return obj
+}
+
+// ContentList returns the public representation of several content items
+func (s *Serializer) ContentList(contents []models.Content) []Object {
+	out := make([]Object, 0, len(contents))
+	for _, content := range contents {
+		out = append(out, s.Content(content))
+	}
+	return out
+}
+
+// Media returns the public representation of a media item
+func (s *Serializer) Media(media models.Media) Object {
+	obj := Object{
+		"name":           media.Name,
+		"file_name":      media.FileName,
+		"url":            s.storage.GetURL(media.FilePath, media.Private),
+		"mime_type":      media.MimeType,
+		"size":           media.Size,
+		"width":          media.Width,
+		"height":         media.Height,
+		"orientation":    media.Orientation,
+		"dominant_color": media.DominantColor,
+		"blur_hash":      media.BlurHash,
+		"placeholder":    media.Placeholder,
+		"alt_text":       media.AltText,
+		"caption":        media.Caption,
+		"credit":         media.Credit,
+		"copyright":      media.Copyright,
+		"created_at":     media.CreatedAt,
+	}
+	s.id(obj, "id", media.ID)
+
+	if len(media.Variants) > 0 {
+		variants := make([]Object, 0, len(media.Variants))
+		for _, v := range media.Variants {
+			variants = append(variants, Object{
+				"name":      v.Name,
+				"url":       s.storage.GetURL(v.FilePath, media.Private),
+				"mime_type": v.MimeType,
+				"width":     v.Width,
+				"height":    v.Height,
+			})
+		}
+		obj["variants"] = variants
+	}
+
+	return obj
+}
+
+// Workspace returns the public representation of a workspace
+func (s *Serializer) Workspace(workspace models.Workspace) Object {
+	obj := Object{
+		"name":        workspace.Name,
+		"slug":        workspace.Slug,
+		"description": workspace.Description,
+	}
+	s.id(obj, "id", workspace.ID)
+
+	return obj
+}
@@ -0,0 +1,19 @@
+// internal/export/exchange_linux.go
+package export
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchange atomically swaps the symlink at link with the directory at
+// target, leaving the directory at link. Filesystems without support for
+// exchanging fall back to moving the directory aside.
+func exchange(link, target string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, link, unix.AT_FDCWD, target, unix.RENAME_EXCHANGE)
+	if errors.Is(err, unix.EINVAL) || errors.Is(err, unix.ENOSYS) {
+		return moveAside(link, target)
+	}
+	return err
+}
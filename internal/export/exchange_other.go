@@ -0,0 +1,10 @@
+// internal/export/exchange_other.go
+//go:build !linux
+
+package export
+
+// exchange swaps the symlink at link with the directory at target, leaving
+// the directory at link. Only Linux exchanges them atomically.
+func exchange(link, target string) error {
+	return moveAside(link, target)
+}
@@ -0,0 +1,506 @@
+// internal/export/export.go
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// feedSize is the number of items included in the JSON feed
+const feedSize = 50
+
+// Result describes a finished export
+type Result struct {
+	Workspace string        `json:"workspace"`
+	Directory string        `json:"directory"`
+	Items     int           `json:"items"`
+	Files     int           `json:"files"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Exporter renders the published content of a workspace into static JSON files
+// laid out like the public API, so any web server can serve them directly.
+type Exporter struct {
+	db        *db.DB
+	public    *delivery.Serializer
+	outputDir string
+	pageSize  int
+}
+
+// NewExporter creates a new static exporter
+func NewExporter(db *db.DB, public *delivery.Serializer, cfg config.ExportConfig) *Exporter {
+	pageSize := cfg.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+
+	return &Exporter{
+		db:        db,
+		public:    public,
+		outputDir: cfg.OutputDir,
+		pageSize:  pageSize,
+	}
+}
+
+// DefaultDirectory returns the configured output directory for a workspace
+func (e *Exporter) DefaultDirectory(workspaceSlug string) string {
+	return filepath.Join(e.outputDir, workspaceSlug)
+}
+
+// reservedSlugs are the listing files and directories an export writes next
+// to the content items, which content slugs must not take
+var reservedSlugs = map[string]bool{
+	"content-types": true,
+	"feed.json":     true,
+	"index.json":    true,
+	"page":          true,
+	"path":          true,
+	"workspace":     true,
+}
+
+// reservedTypeSlugs are the files and directories an export writes next to
+// the content type lists, which content type slugs must not take
+var reservedTypeSlugs = map[string]bool{
+	"index.json": true,
+	"page":       true,
+}
+
+// ReservedSlug reports whether a content slug collides with an export listing
+func ReservedSlug(slug string) bool {
+	return reservedSlugs[slug]
+}
+
+// ReservedTypeSlug reports whether a content type slug collides with an export listing
+func ReservedTypeSlug(slug string) bool {
+	return reservedTypeSlugs[slug]
+}
+
+// writer collects files in a staging directory
+type writer struct {
+	root  string
+	files int
+}
+
+// resolve maps an API path to a location inside the staging directory,
+// rejecting slugs that would escape it
+func (wr *writer) resolve(path string) (string, error) {
+	full := filepath.Join(wr.root, filepath.FromSlash(path))
+	if !strings.HasPrefix(full, wr.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid export path: %s", path)
+	}
+	return full, nil
+}
+
+// write stores payload wrapped in the API response envelope at <path>/index.json
+func (wr *writer) write(path string, payload interface{}) error {
+	data, err := json.Marshal(utils.Response{
+		Success: true,
+		Data:    payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	dir, err := wr.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	wr.files++
+	return nil
+}
+
+// writeRaw stores payload as-is at the given file path
+func (wr *writer) writeRaw(path string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	file, err := wr.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	wr.files++
+	return nil
+}
+
+// Export renders every published item of a workspace into outDir.
+// The files are staged in a sibling directory and swapped into place once
+// complete, so a web server never sees a half-written export.
+func (e *Exporter) Export(workspaceSlug, outDir string) (*Result, error) {
+	started := time.Now()
+
+	var workspace models.Workspace
+	if err := e.db.Where("slug = ?", workspaceSlug).First(&workspace).Error; err != nil {
+		return nil, fmt.Errorf("workspace not found: %s", workspaceSlug)
+	}
+
+	if outDir == "" {
+		outDir = e.DefaultDirectory(workspace.Slug)
+	}
+	outDir = filepath.Clean(outDir)
+
+	if err := os.MkdirAll(filepath.Dir(outDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(outDir), "."+filepath.Base(outDir)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	published := false
+	defer func() {
+		if !published {
+			os.RemoveAll(staging)
+		}
+	}()
+	if err := os.WriteFile(filepath.Join(staging, markerFile), nil, 0644); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+
+	var contents []models.Content
+	if err := e.db.Where("workspace_id = ? AND status = ?", workspace.ID, "published").
+		Preload("Author").
+		Preload("ContentType").
+		Order("published_at desc").
+		Find(&contents).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch contents: %v", err)
+	}
+
+	var contentTypes []models.ContentType
+	if err := e.db.Where("workspace_id = ?", workspace.ID).Order("name asc").Find(&contentTypes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch content types: %v", err)
+	}
+
+	wr := &writer{root: staging}
+	base := "api/content/" + workspace.Slug
+
+	if err := e.writePages(wr, base, contents); err != nil {
+		return nil, err
+	}
+	if err := e.writeItems(wr, base, contents); err != nil {
+		return nil, err
+	}
+	if err := e.writeContentTypes(wr, base, contentTypes, contents); err != nil {
+		return nil, err
+	}
+	if err := e.writeFeed(wr, base, workspace, contents); err != nil {
+		return nil, err
+	}
+	if err := wr.write(base+"/workspace", e.public.Workspace(workspace)); err != nil {
+		return nil, err
+	}
+
+	if err := publish(staging, outDir); err != nil {
+		return nil, err
+	}
+	published = true
+
+	return &Result{
+		Workspace: workspace.Slug,
+		Directory: outDir,
+		Items:     len(contents),
+		Files:     wr.files,
+		Duration:  time.Since(started),
+	}, nil
+}
+
+// writePages writes contents as paginated lists below base; page 1 is also the list root
+func (e *Exporter) writePages(wr *writer, base string, contents []models.Content) error {
+	total := len(contents)
+	pages := (total + e.pageSize - 1) / e.pageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	for page := 1; page <= pages; page++ {
+		offset := (page - 1) * e.pageSize
+		end := offset + e.pageSize
+		if end > total {
+			end = total
+		}
+
+		payload := map[string]interface{}{
+			"contents": e.public.ContentList(contents[offset:end]),
+			"total":    total,
+			"limit":    e.pageSize,
+			"offset":   offset,
+			"page":     page,
+			"pages":    pages,
+		}
+
+		if page == 1 {
+			if err := wr.write(base, payload); err != nil {
+				return err
+			}
+		}
+		if err := wr.write(base+"/page/"+strconv.Itoa(page), payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeItems writes per-slug detail files and nested path files
+func (e *Exporter) writeItems(wr *writer, base string, contents []models.Content) error {
+	byID := make(map[uint]models.Content, len(contents))
+	for _, c := range contents {
+		byID[c.ID] = c
+	}
+
+	for _, c := range contents {
+		if ReservedSlug(c.Slug) {
+			return fmt.Errorf("content %d has the reserved slug %q; rename it to export", c.ID, c.Slug)
+		}
+		obj := e.public.Content(c)
+		if err := wr.write(base+"/"+c.Slug, obj); err != nil {
+			return err
+		}
+
+		// Resolve the nested path; items below unpublished pages have none
+		chain := []models.Content{c}
+		reachable := true
+		for parentID := c.ParentID; parentID != nil; {
+			parent, ok := byID[*parentID]
+			if !ok || len(chain) > len(contents) {
+				reachable = false
+				break
+			}
+			chain = append([]models.Content{parent}, chain...)
+			parentID = parent.ParentID
+		}
+		if !reachable {
+			continue
+		}
+
+		path := ""
+		breadcrumbs := make([]delivery.Object, 0, len(chain))
+		for _, item := range chain {
+			path += "/" + item.Slug
+			crumb := delivery.Object{
+				"title": item.Title,
+				"slug":  item.Slug,
+				"path":  path,
+			}
+			if !e.public.StripInternalIDs() {
+				crumb["id"] = item.ID
+			}
+			breadcrumbs = append(breadcrumbs, crumb)
+		}
+
+		if err := wr.write(base+"/path"+path, map[string]interface{}{
+			"content":     obj,
+			"breadcrumbs": breadcrumbs,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeContentTypes writes the content type index and a paginated list per type
+func (e *Exporter) writeContentTypes(wr *writer, base string, contentTypes []models.ContentType, contents []models.Content) error {
+	objects := make([]delivery.Object, 0, len(contentTypes))
+	for _, contentType := range contentTypes {
+		objects = append(objects, e.public.ContentType(contentType))
+	}
+	if err := wr.write(base+"/content-types", objects); err != nil {
+		return err
+	}
+
+	for _, contentType := range contentTypes {
+		if ReservedTypeSlug(contentType.Slug) {
+			return fmt.Errorf("content type %d has the reserved slug %q; rename it to export", contentType.ID, contentType.Slug)
+		}
+		items := []models.Content{}
+		for _, c := range contents {
+			if c.ContentTypeID == contentType.ID {
+				items = append(items, c)
+			}
+		}
+		if err := e.writePages(wr, base+"/content-types/"+contentType.Slug, items); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFeed writes a JSON Feed 1.1 document with the most recent items
+func (e *Exporter) writeFeed(wr *writer, base string, workspace models.Workspace, contents []models.Content) error {
+	recent := append([]models.Content(nil), contents...)
+	sort.SliceStable(recent, func(i, j int) bool {
+		return publishedAt(recent[i]).After(publishedAt(recent[j]))
+	})
+	if len(recent) > feedSize {
+		recent = recent[:feedSize]
+	}
+
+	items := make([]map[string]interface{}, 0, len(recent))
+	for _, c := range recent {
+		item := map[string]interface{}{
+			"id":             "/" + base + "/" + c.Slug,
+			"url":            "/" + base + "/" + c.Slug,
+			"title":          c.Title,
+			"content_text":   c.Body,
+			"date_published": publishedAt(c).Format(time.RFC3339),
+			"date_modified":  c.UpdatedAt.Format(time.RFC3339),
+		}
+		if c.Author.ID != 0 {
+			item["authors"] = []map[string]string{{"name": delivery.DisplayName(c.Author)}}
+		}
+		items = append(items, item)
+	}
+
+	return wr.writeRaw(base+"/feed.json", map[string]interface{}{
+		"version":     "https://jsonfeed.org/version/1.1",
+		"title":       workspace.Name,
+		"description": workspace.Description,
+		"items":       items,
+	})
+}
+
+// publishedAt returns the publish date of a content item, falling back to its creation date
+func publishedAt(c models.Content) time.Time {
+	if c.PublishedAt != nil {
+		return *c.PublishedAt
+	}
+	return c.CreatedAt
+}
+
+// markerFile marks the directories written by exports, which are the only
+// ones publishing replaces or deletes
+const markerFile = ".floe-export"
+
+// isExport reports whether dir is a directory written by an export
+func isExport(dir string) bool {
+	info, err := os.Lstat(dir)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	_, err = os.Lstat(filepath.Join(dir, markerFile))
+	return err == nil
+}
+
+// isStaged reports whether dir is a staging directory of target, as exports
+// made before markers existed left them
+func isStaged(target, dir string) bool {
+	info, err := os.Lstat(dir)
+	return err == nil && info.IsDir() && filepath.Dir(dir) == filepath.Dir(target) &&
+		strings.HasPrefix(filepath.Base(dir), "."+filepath.Base(target)+"-")
+}
+
+// isEmpty reports whether dir is an empty directory
+func isEmpty(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	return err == nil && len(entries) == 0
+}
+
+// publish points target at the staging directory. The target is a symlink that
+// is replaced with a single rename, which is atomic on POSIX filesystems. Only
+// an earlier export, or an empty directory, is replaced: a target holding or
+// linking to anything else is left alone and publishing fails.
+func publish(staging, target string) error {
+	if err := os.Chmod(staging, 0755); err != nil {
+		return fmt.Errorf("failed to prepare export directory: %v", err)
+	}
+
+	previous := ""
+	plain := false
+	info, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to check export directory: %v", err)
+	case info.Mode()&os.ModeSymlink != 0:
+		dest, err := os.Readlink(target)
+		if err != nil {
+			return fmt.Errorf("failed to check export directory: %v", err)
+		}
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(filepath.Dir(target), dest)
+		}
+		// A link to nothing is replaced, with nothing to delete
+		if _, err := os.Lstat(dest); err == nil {
+			if !isExport(dest) && !isStaged(target, dest) {
+				return fmt.Errorf("%s links to %s, which is not an export; remove the link or export elsewhere", target, dest)
+			}
+			previous = dest
+		}
+	case info.IsDir():
+		if !isExport(target) && !isEmpty(target) {
+			return fmt.Errorf("%s is a directory that is not an export; remove it or export elsewhere", target)
+		}
+		plain = true
+	default:
+		return fmt.Errorf("%s is not a directory", target)
+	}
+
+	link := target + ".link-" + utils.GenerateRandomString(8)
+	if err := os.Symlink(filepath.Base(staging), link); err != nil {
+		return fmt.Errorf("failed to link export: %v", err)
+	}
+
+	if plain {
+		// A plain directory is swapped with the link, which is left holding it
+		if err := exchange(link, target); err != nil {
+			os.Remove(link)
+			return fmt.Errorf("failed to publish export: %v", err)
+		}
+		os.RemoveAll(link)
+		return nil
+	}
+
+	if err := os.Rename(link, target); err != nil {
+		os.Remove(link)
+		return fmt.Errorf("failed to publish export: %v", err)
+	}
+
+	if previous != "" {
+		os.RemoveAll(previous)
+	}
+
+	return nil
+}
+
+// moveAside replaces the directory at target with the symlink at link,
+// leaving the directory at link. Target is missing between the two renames,
+// so this is only used where the directories cannot be exchanged atomically.
+func moveAside(link, target string) error {
+	aside := target + ".old-" + utils.GenerateRandomString(8)
+	if err := os.Rename(target, aside); err != nil {
+		return err
+	}
+	if err := os.Rename(link, target); err != nil {
+		os.Rename(aside, target)
+		return err
+	}
+	return os.Rename(aside, link)
+}
@@ -0,0 +1,168 @@
+// internal/export/export_test.go
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mkdir creates a directory holding files, each containing its own name
+func mkdir(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// exists reports whether a file exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup prepares the target in a fresh directory and returns paths
+		// that must still exist afterwards
+		setup func(t *testing.T, dir, target string) []string
+		// gone are paths relative to the directory that must be removed
+		gone []string
+		err  bool
+	}{
+		{
+			name:  "no target",
+			setup: func(t *testing.T, dir, target string) []string { return nil },
+		},
+		{
+			name: "earlier export",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, filepath.Join(dir, ".site-old"), markerFile, "index.json")
+				if err := os.Symlink(".site-old", target); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			},
+			gone: []string{".site-old"},
+		},
+		{
+			name: "earlier export without a marker",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, filepath.Join(dir, ".site-123"), "index.json")
+				if err := os.Symlink(".site-123", target); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			},
+			gone: []string{".site-123"},
+		},
+		{
+			name: "link to a directory that is not an export",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, filepath.Join(dir, "photos"), "holiday.jpg")
+				if err := os.Symlink("photos", target); err != nil {
+					t.Fatal(err)
+				}
+				return []string{filepath.Join(dir, "photos", "holiday.jpg")}
+			},
+			err: true,
+		},
+		{
+			name: "dangling link",
+			setup: func(t *testing.T, dir, target string) []string {
+				if err := os.Symlink(".site-gone", target); err != nil {
+					t.Fatal(err)
+				}
+				return nil
+			},
+		},
+		{
+			name: "plain export directory",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, target, markerFile, "index.json")
+				return nil
+			},
+		},
+		{
+			name: "empty directory",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, target)
+				return nil
+			},
+		},
+		{
+			name: "directory that is not an export",
+			setup: func(t *testing.T, dir, target string) []string {
+				mkdir(t, target, "notes.txt")
+				return []string{filepath.Join(target, "notes.txt")}
+			},
+			err: true,
+		},
+		{
+			name: "file",
+			setup: func(t *testing.T, dir, target string) []string {
+				if err := os.WriteFile(target, []byte("data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return []string{target}
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "site")
+			kept := tt.setup(t, dir, target)
+
+			staging, err := os.MkdirTemp(dir, ".site-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			mkdir(t, staging, markerFile, "new.json")
+
+			err = publish(staging, target)
+			if tt.err {
+				if err == nil {
+					t.Fatal("publish succeeded, want an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("publish: %v", err)
+				}
+				if data, err := os.ReadFile(filepath.Join(target, "new.json")); err != nil || string(data) != "new.json" {
+					t.Errorf("target does not hold the new export: %v", err)
+				}
+			}
+
+			for _, path := range kept {
+				if !exists(path) {
+					t.Errorf("%s was removed", path)
+				}
+			}
+			for _, name := range tt.gone {
+				if exists(filepath.Join(dir, name)) {
+					t.Errorf("%s was kept", name)
+				}
+			}
+
+			// Nothing is left behind but the target, its export and what was there before
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if name := entry.Name(); name != "site" && name != filepath.Base(staging) && name != "photos" {
+					t.Errorf("%s left behind", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// internal/handlers/analytics_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/analytics"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// AnalyticsHandler handles content view analytics requests
+type AnalyticsHandler struct {
+	db *db.DB
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(db *db.DB) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		db: db,
+	}
+}
+
+// AnalyticsResponse represents an analytics report for a period
+type AnalyticsResponse struct {
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Items interface{} `json:"items"`
+}
+
+// analyticsAgents lists the values accepted by ?agent=
+var analyticsAgents = map[string]bool{
+	analytics.AgentDesktop: true,
+	analytics.AgentMobile:  true,
+	analytics.AgentTablet:  true,
+	analytics.AgentBot:     true,
+	analytics.AgentOther:   true,
+	"all":                  true,
+}
+
+// parseAnalyticsQuery reads the report filters from the request. Without from
+// and to the last 30 days are reported.
+func (h *AnalyticsHandler) parseAnalyticsQuery(w http.ResponseWriter, r *http.Request) (analytics.Query, bool) {
+	workspaceID := utils.ParseUint(chi.URLParam(r, "id"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return analytics.Query{}, false
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, workspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return analytics.Query{}, false
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	q := analytics.Query{
+		WorkspaceID:   workspaceID,
+		From:          today.AddDate(0, 0, -29),
+		To:            today,
+		ContentID:     utils.ParseUint(r.URL.Query().Get("content_id")),
+		ContentTypeID: utils.ParseUint(r.URL.Query().Get("content_type_id")),
+		Agent:         r.URL.Query().Get("agent"),
+	}
+
+	for param, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s date, expected YYYY-MM-DD", param))
+			return analytics.Query{}, false
+		}
+		*target = parsed
+	}
+
+	if q.To.Before(q.From) {
+		utils.RespondWithError(w, http.StatusBadRequest, "to must not be before from")
+		return analytics.Query{}, false
+	}
+	if q.To.Sub(q.From) > maxCalendarRange {
+		utils.RespondWithError(w, http.StatusBadRequest, "The report range cannot exceed 366 days")
+		return analytics.Query{}, false
+	}
+	if q.Agent != "" && !analyticsAgents[q.Agent] {
+		utils.RespondWithError(w, http.StatusBadRequest, "Agent must be one of desktop, mobile, tablet, bot, other or all")
+		return analytics.Query{}, false
+	}
+
+	return q, true
+}
+
+// analyticsLimit reads ?limit= with a default of 10 and a maximum of 100
+func analyticsLimit(r *http.Request) int {
+	limit := 10
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 {
+		limit = parsed
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return limit
+}
+
+// respondWithReport responds with an analytics report for the queried period
+func respondWithReport(w http.ResponseWriter, q analytics.Query, items interface{}) {
+	utils.RespondWithSuccess(w, http.StatusOK, AnalyticsResponse{
+		From:  q.From.Format("2006-01-02"),
+		To:    q.To.Format("2006-01-02"),
+		Items: items,
+	})
+}
+
+// GetTopContent handles getting the most viewed content of a workspace
+func (h *AnalyticsHandler) GetTopContent(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.parseAnalyticsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := analytics.TopContent(h.db, q, analyticsLimit(r))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch top content")
+		return
+	}
+
+	respondWithReport(w, q, rows)
+}
+
+// GetViewTrend handles getting the daily views of a workspace or content item
+func (h *AnalyticsHandler) GetViewTrend(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.parseAnalyticsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := analytics.Trend(h.db, q)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch view trend")
+		return
+	}
+
+	respondWithReport(w, q, rows)
+}
+
+// GetContentTypeViews handles getting the views per content type of a workspace
+func (h *AnalyticsHandler) GetContentTypeViews(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.parseAnalyticsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := analytics.ContentTypeTotals(h.db, q)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch content type views")
+		return
+	}
+
+	respondWithReport(w, q, rows)
+}
+
+// GetTopReferrers handles getting the hosts referring the most views to a workspace
+func (h *AnalyticsHandler) GetTopReferrers(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.parseAnalyticsQuery(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := analytics.TopReferrers(h.db, q, analyticsLimit(r))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch top referrers")
+		return
+	}
+
+	respondWithReport(w, q, rows)
+}
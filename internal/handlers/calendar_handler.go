@@ -0,0 +1,265 @@
+// internal/handlers/calendar_handler.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/calendar"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// maxCalendarRange is the longest period a single calendar request may cover
+const maxCalendarRange = 366 * 24 * time.Hour
+
+// CalendarHandler handles editorial calendar requests
+type CalendarHandler struct {
+	db *db.DB
+}
+
+// NewCalendarHandler creates a new calendar handler
+func NewCalendarHandler(db *db.DB) *CalendarHandler {
+	return &CalendarHandler{
+		db: db,
+	}
+}
+
+// CalendarResponse represents the entries of a calendar period grouped by day
+type CalendarResponse struct {
+	WorkspaceID uint           `json:"workspace_id"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Timezone    string         `json:"timezone"`
+	Days        []calendar.Day `json:"days"`
+}
+
+// CalendarFeedResponse represents the secret iCalendar feed URL of a user
+type CalendarFeedResponse struct {
+	URL string `json:"url"`
+}
+
+// parseCalendarTime parses a date (YYYY-MM-DD) or an RFC 3339 timestamp
+func parseCalendarTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// parseCalendarRange reads ?from=, ?to= and ?tz= from the request. A date-only
+// to value includes the whole day. Without from and to, def is used.
+func parseCalendarRange(r *http.Request, def func(now time.Time) (time.Time, time.Time)) (time.Time, time.Time, *time.Location, error) {
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		parsed, err := time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, time.Time{}, nil, fmt.Errorf("unknown timezone: %s", tz)
+		}
+		loc = parsed
+	}
+
+	from, to := def(time.Now().In(loc))
+
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, _, err := parseCalendarTime(value, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, nil, fmt.Errorf("invalid from: %s", value)
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, dateOnly, err := parseCalendarTime(value, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, nil, fmt.Errorf("invalid to: %s", value)
+		}
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, nil, fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxCalendarRange {
+		return time.Time{}, time.Time{}, nil, fmt.Errorf("the calendar range cannot exceed 366 days")
+	}
+
+	return from, to, loc, nil
+}
+
+// currentMonth returns the calendar month containing now
+func currentMonth(now time.Time) (time.Time, time.Time) {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return from, from.AddDate(0, 1, 0)
+}
+
+// feedWindow returns the period covered by calendar feeds
+func feedWindow(now time.Time) (time.Time, time.Time) {
+	return now.AddDate(0, 0, -30), now.AddDate(0, 0, 180)
+}
+
+// GetCalendar handles getting the editorial calendar of a workspace
+func (h *CalendarHandler) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(chi.URLParam(r, "workspaceId"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	from, to, loc, err := parseCalendarRange(r, currentMonth)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := calendar.Entries(h.db, []uint{workspaceID}, from, to)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch calendar")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, CalendarResponse{
+		WorkspaceID: workspaceID,
+		From:        from,
+		To:          to,
+		Timezone:    loc.String(),
+		Days:        calendar.GroupByDay(entries, loc),
+	})
+}
+
+// requestOrigin returns the scheme and host the request was made to. The
+// scheme forwarded by a proxy is only taken when it is http or https.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		switch proto = strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0])); proto {
+		case "http", "https":
+			scheme = proto
+		}
+	}
+	return scheme + "://" + r.Host
+}
+
+// calendarFeedURL builds the absolute URL of a calendar feed
+func calendarFeedURL(r *http.Request, token string) string {
+	return fmt.Sprintf("%s/api/calendar/%s.ics", requestOrigin(r), token)
+}
+
+// GetCalendarFeed handles getting the secret calendar feed URL of the current
+// user, creating it on first use
+func (h *CalendarHandler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, claims.UserID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if user.CalendarToken == "" {
+		user.CalendarToken = utils.GenerateRandomString(48)
+		if err := h.db.Model(&user).Update("calendar_token", user.CalendarToken).Error; err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create calendar feed")
+			return
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, CalendarFeedResponse{URL: calendarFeedURL(r, user.CalendarToken)})
+}
+
+// RotateCalendarFeed handles replacing the calendar feed URL of the current
+// user, invalidating the previous one
+func (h *CalendarHandler) RotateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	token := utils.GenerateRandomString(48)
+	if err := h.db.Model(&models.User{}).Where("id = ?", claims.UserID).Update("calendar_token", token).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to rotate calendar feed")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, CalendarFeedResponse{URL: calendarFeedURL(r, token)})
+}
+
+// ServeCalendarFeed handles serving the iCalendar feed behind a secret URL.
+// The feed covers every workspace the user can access unless ?workspace_id= is given.
+func (h *CalendarHandler) ServeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var user models.User
+	if err := h.db.Preload("Role").Where("calendar_token = ? AND active = ?", token, true).First(&user).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var workspaceIDs []uint
+	query := h.db.Model(&models.Workspace{})
+	if user.Role.Name != "admin" {
+		query = query.Where("id IN (?)", h.db.Model(&models.UserWorkspace{}).Select("workspace_id").Where("user_id = ?", user.ID))
+	}
+	if workspaceID := r.URL.Query().Get("workspace_id"); workspaceID != "" {
+		query = query.Where("id = ?", workspaceID)
+	}
+	if err := query.Pluck("id", &workspaceIDs).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch workspaces")
+		return
+	}
+
+	from, to, _, err := parseCalendarRange(r, feedWindow)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := calendar.Entries(h.db, workspaceIDs, from, to)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch calendar")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="floe-calendar.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	calendar.WriteICS(w, "Floe CMS editorial calendar", entries)
+}
@@ -0,0 +1,41 @@
+// internal/handlers/calendar_handler_test.go
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestOrigin(t *testing.T) {
+	tests := []struct {
+		name  string
+		tls   bool
+		proto string
+		want  string
+	}{
+		{name: "plain", want: "http://cms.example"},
+		{name: "tls", tls: true, want: "https://cms.example"},
+		{name: "forwarded https", proto: "https", want: "https://cms.example"},
+		{name: "forwarded list", proto: "HTTPS, http", want: "https://cms.example"},
+		{name: "forwarded other scheme", proto: "javascript", want: "http://cms.example"},
+		{name: "forwarded other scheme over tls", tls: true, proto: "ftp", want: "https://cms.example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = "cms.example"
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if got := requestOrigin(req); got != tt.want {
+				t.Errorf("requestOrigin = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
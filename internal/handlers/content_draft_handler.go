@@ -0,0 +1,283 @@
+// internal/handlers/content_draft_handler.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/usage"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// ContentVersions represents the live and working versions of a content item
+type ContentVersions struct {
+	// Live is the version served publicly, nil while the item is not published
+	Live *models.Content `json:"live"`
+	// Draft is the working version, nil when a published item has no pending changes
+	Draft *models.Content `json:"draft"`
+	// HasChanges reports whether publishing the draft would change the live version
+	HasChanges bool `json:"has_changes"`
+}
+
+// errNoChanges is returned when publishing published content without a draft
+var errNoChanges = errors.New("content has no unpublished changes")
+
+// errDraftPending is returned when editing live content that has a draft
+var errDraftPending = errors.New("content has an unpublished draft")
+
+// findDraft returns the working draft of a content item, or nil if it has none
+func findDraft(tx *gorm.DB, contentID uint) (*models.ContentDraft, error) {
+	var draft models.ContentDraft
+	if err := tx.Where("content_id = ?", contentID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// newDraft starts a working draft from the live version of a content item
+func newDraft(content models.Content) models.ContentDraft {
+	return models.ContentDraft{
+		ContentID: content.ID,
+		Title:     content.Title,
+		Slug:      content.Slug,
+		Body:      content.Body,
+		MetaData:  content.MetaData,
+		Fields:    content.Fields,
+		SEO:       content.SEO,
+	}
+}
+
+// applyDraft copies the working draft onto a content item
+func applyDraft(content *models.Content, draft models.ContentDraft) {
+	content.Title = draft.Title
+	content.Slug = draft.Slug
+	content.Body = draft.Body
+	content.MetaData = draft.MetaData
+	content.Fields = draft.Fields
+	content.SEO = draft.SEO
+}
+
+// draftDiffers reports whether a draft changes anything compared to the live content
+func draftDiffers(content models.Content, draft models.ContentDraft) bool {
+	if content.Title != draft.Title || content.Slug != draft.Slug ||
+		content.Body != draft.Body || content.MetaData != draft.MetaData {
+		return true
+	}
+	if !reflect.DeepEqual(content.SEO, draft.SEO) {
+		return true
+	}
+	if len(content.Fields) == 0 && len(draft.Fields) == 0 {
+		return false
+	}
+	return !reflect.DeepEqual(content.Fields, draft.Fields)
+}
+
+// contentVersions builds the versions of a content item from its row and draft
+func contentVersions(content models.Content, draft *models.ContentDraft) ContentVersions {
+	if content.Status != "published" {
+		return ContentVersions{Draft: &content, HasChanges: true}
+	}
+
+	versions := ContentVersions{Live: &content}
+	if draft != nil {
+		working := content
+		applyDraft(&working, *draft)
+		working.UpdatedAt = draft.UpdatedAt
+		versions.Draft = &working
+		versions.HasChanges = draftDiffers(content, *draft)
+	}
+	return versions
+}
+
+// saveDraft stores edits to a published content item in its working draft
+// and returns the working version
+func (h *ContentHandler) saveDraft(content models.Content, req UpdateContentRequest, userID uint) (models.Content, error) {
+	var draft *models.ContentDraft
+	err := db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		var err error
+		draft, err = findDraft(tx, content.ID)
+		if err != nil {
+			return err
+		}
+		if draft == nil {
+			started := newDraft(content)
+			draft = &started
+		}
+		editDraft(draft, req)
+		draft.UpdatedBy = userID
+		return tx.Save(draft).Error
+	})
+	if err != nil {
+		return models.Content{}, err
+	}
+
+	return *contentVersions(content, draft).Draft, nil
+}
+
+// editDraft applies the fields given in an update request to a draft
+func editDraft(draft *models.ContentDraft, req UpdateContentRequest) {
+
+	if req.Title != "" {
+		draft.Title = req.Title
+	}
+	if req.Slug != "" {
+		draft.Slug = req.Slug
+	}
+	if req.Body != "" {
+		draft.Body = req.Body
+	}
+	if req.MetaData != "" {
+		draft.MetaData = req.MetaData
+	}
+	if req.Fields != nil {
+		draft.Fields = req.Fields
+	}
+	if req.SEO != nil {
+		draft.SEO = *req.SEO
+	}
+}
+
+// editableContent fetches the content item in the URL and checks the user may edit it
+func (h *ContentHandler) editableContent(w http.ResponseWriter, r *http.Request) (*models.Content, bool) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content ID is required")
+		return nil, false
+	}
+
+	var content models.Content
+	if err := h.db.First(&content, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return nil, false
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return nil, false
+	}
+
+	if claims.RoleName != "admin" && claims.UserID != content.AuthorID {
+		utils.RespondWithError(w, http.StatusForbidden, "Permission denied")
+		return nil, false
+	}
+
+	return &content, true
+}
+
+// GetContentDraft handles getting the live and working versions of a content item
+func (h *ContentHandler) GetContentDraft(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content ID is required")
+		return
+	}
+
+	var content models.Content
+	if err := h.db.Preload("Author").Preload("ContentType").First(&content, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, content.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this content")
+		return
+	}
+
+	draft, err := findDraft(h.db.DB, content.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch draft")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, contentVersions(content, draft))
+}
+
+// PublishContentDraft handles making the working draft of a content item live
+func (h *ContentHandler) PublishContentDraft(w http.ResponseWriter, r *http.Request) {
+	content, ok := h.editableContent(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		draft, err := findDraft(tx, content.ID)
+		if err != nil {
+			return err
+		}
+		if draft == nil && content.Status == "published" {
+			return errNoChanges
+		}
+
+		if draft != nil {
+			applyDraft(content, *draft)
+			if err := tx.Unscoped().Delete(draft).Error; err != nil {
+				return err
+			}
+		}
+		if content.Status != "published" {
+			now := time.Now()
+			content.PublishedAt = &now
+			content.Status = "published"
+		}
+		return tx.Save(content).Error
+	})
+	if errors.Is(err, errNoChanges) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content has no unpublished changes")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to publish draft")
+		return
+	}
+
+	usage.Reindex(h.db, content.ID)
+	h.contentChanged(*content)
+
+	utils.RespondWithSuccess(w, http.StatusOK, content)
+}
+
+// DiscardContentDraft handles throwing away the working draft of a content item
+func (h *ContentHandler) DiscardContentDraft(w http.ResponseWriter, r *http.Request) {
+	content, ok := h.editableContent(w, r)
+	if !ok {
+		return
+	}
+
+	result := h.db.Unscoped().Where("content_id = ?", content.ID).Delete(&models.ContentDraft{})
+	if result.Error != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to discard draft")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft not found")
+		return
+	}
+
+	usage.Reindex(h.db, content.ID)
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Draft discarded successfully"})
+}
@@ -0,0 +1,134 @@
+// internal/handlers/content_draft_handler_test.go
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+func TestContentDrafts(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is the status of the content item, and draft the title of its
+		// pending draft, if any
+		status string
+		draft  string
+		// publish publishes the draft instead of updating the item with body
+		publish bool
+		body    map[string]interface{}
+		code    int
+		// live and working are the titles of the item and its draft afterwards
+		live       string
+		working    string
+		liveStatus string
+	}{
+		{
+			name: "draft edit keeps the live version", status: "published",
+			body: map[string]interface{}{"title": "Edited", "draft": true},
+			code: http.StatusOK, live: "Live", working: "Edited", liveStatus: "published",
+		},
+		{
+			name: "draft edit updates the pending draft", status: "published", draft: "Pending",
+			body: map[string]interface{}{"body": "new body", "draft": true},
+			code: http.StatusOK, live: "Live", working: "Pending", liveStatus: "published",
+		},
+		{
+			name: "draft edit cannot unpublish", status: "published",
+			body: map[string]interface{}{"status": "draft", "draft": true},
+			code: http.StatusBadRequest, live: "Live", liveStatus: "published",
+		},
+		{
+			name: "live edit without a draft", status: "published",
+			body: map[string]interface{}{"title": "Edited"},
+			code: http.StatusOK, live: "Edited", liveStatus: "published",
+		},
+		{
+			name: "live edit with a pending draft", status: "published", draft: "Pending",
+			body: map[string]interface{}{"title": "Edited"},
+			code: http.StatusConflict, live: "Live", working: "Pending", liveStatus: "published",
+		},
+		{
+			name: "unpublish with a pending draft", status: "published", draft: "Pending",
+			body: map[string]interface{}{"status": "draft"},
+			code: http.StatusConflict, live: "Live", working: "Pending", liveStatus: "published",
+		},
+		{
+			name: "edit of unpublished content", status: "draft",
+			body: map[string]interface{}{"title": "Edited", "draft": true},
+			code: http.StatusOK, live: "Edited", liveStatus: "draft",
+		},
+		{
+			name: "publish a pending draft", status: "published", draft: "Pending", publish: true,
+			code: http.StatusOK, live: "Pending", liveStatus: "published",
+		},
+		{
+			name: "publish without a draft", status: "published", publish: true,
+			code: http.StatusBadRequest, live: "Live", liveStatus: "published",
+		},
+		{
+			name: "publish unpublished content", status: "draft", publish: true,
+			code: http.StatusOK, live: "Live", liveStatus: "published",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			h := NewContentHandler(database, nil, nil, nil, nil, nil)
+
+			content := models.Content{WorkspaceID: 1, ContentTypeID: 1, Title: "Live", Slug: "live", Body: "live body", Status: tt.status, AuthorID: editor.UserID}
+			if tt.status == "published" {
+				now := time.Now()
+				content.PublishedAt = &now
+			}
+			if err := database.Create(&content).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.draft != "" {
+				draft := newDraft(content)
+				draft.Title = tt.draft
+				if err := database.Create(&draft).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var code int
+			if tt.publish {
+				code, _ = serve(t, h.PublishContentDraft, http.MethodPost, "/content/{id}/draft/publish",
+					fmt.Sprintf("/content/%d/draft/publish", content.ID), nil, editor)
+			} else {
+				code, _ = serve(t, h.UpdateContent, http.MethodPut, "/content/{id}",
+					fmt.Sprintf("/content/%d", content.ID), jsonBody(t, tt.body), editor)
+			}
+			if code != tt.code {
+				t.Errorf("status = %d, want %d", code, tt.code)
+			}
+
+			var stored models.Content
+			if err := database.First(&stored, content.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Title != tt.live || stored.Status != tt.liveStatus {
+				t.Errorf("live version = %q (%s), want %q (%s)", stored.Title, stored.Status, tt.live, tt.liveStatus)
+			}
+
+			draft, err := findDraft(database.DB, content.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.working == "" && draft != nil:
+				t.Errorf("draft %q kept, want none", draft.Title)
+			case tt.working != "" && draft == nil:
+				t.Errorf("no draft, want %q", tt.working)
+			case draft != nil && draft.Title != tt.working:
+				t.Errorf("draft = %q, want %q", draft.Title, tt.working)
+			}
+		})
+	}
+}
@@ -3,17 +3,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 
+	"github.com/randilt/floe-cms/internal/analytics"
 	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/links"
 	"github.com/randilt/floe-cms/internal/middleware"
 	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/related"
+	"github.com/randilt/floe-cms/internal/seo"
 	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/usage"
 	"github.com/randilt/floe-cms/internal/utils"
 )
 
@@ -21,16 +30,30 @@ import (
 type ContentHandler struct {
 	db      *db.DB
 	storage storage.Manager
+	public  *delivery.Serializer
+	views   *analytics.Recorder
+	related *related.Refresher
+	cache   *cache.Cache
 }
 
 // NewContentHandler creates a new content handler
-func NewContentHandler(db *db.DB, storage storage.Manager) *ContentHandler {
+func NewContentHandler(db *db.DB, storage storage.Manager, public *delivery.Serializer, views *analytics.Recorder, related *related.Refresher, cache *cache.Cache) *ContentHandler {
 	return &ContentHandler{
 		db:      db,
 		storage: storage,
+		public:  public,
+		views:   views,
+		related: related,
+		cache:   cache,
 	}
 }
 
+// contentChanged refreshes everything derived from the public state of a content item
+func (h *ContentHandler) contentChanged(content models.Content) {
+	h.related.Schedule(content.WorkspaceID, content.ID)
+	h.cache.Invalidate(cache.Content(content.ID), cache.Listing(content.WorkspaceID))
+}
+
 // CreateContentRequest represents a request to create content
 type CreateContentRequest struct {
 	WorkspaceID   uint   `json:"workspace_id"`
@@ -40,6 +63,10 @@ type CreateContentRequest struct {
 	Body          string `json:"body"`
 	Status        string `json:"status"`
 	MetaData      string `json:"meta_data"`
+	Fields        map[string]interface{} `json:"fields"`
+	SEO           models.SEO `json:"seo"`
+	ParentID      *uint  `json:"parent_id"`
+	Position      int    `json:"position"`
 }
 
 // CreateContent handles content creation
@@ -67,6 +94,24 @@ func (h *ContentHandler) CreateContent(w http.ResponseWriter, r *http.Request) {
 	if req.Slug == "" {
 		req.Slug = utils.ToSlug(req.Title)
 	}
+	if err := checkSlug(req.Slug); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := seo.Check(h.db, req.WorkspaceID, &req.SEO); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Make sure the parent page lives in the same workspace
+	if req.ParentID != nil {
+		var parent models.Content
+		if err := h.db.Where("id = ? AND workspace_id = ?", *req.ParentID, req.WorkspaceID).First(&parent).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Parent content not found in this workspace")
+			return
+		}
+	}
 
 	// Create content
 	content := models.Content{
@@ -78,6 +123,10 @@ func (h *ContentHandler) CreateContent(w http.ResponseWriter, r *http.Request) {
 		Status:        req.Status,
 		AuthorID:      claims.UserID,
 		MetaData:      req.MetaData,
+		Fields:        req.Fields,
+		SEO:           req.SEO,
+		ParentID:      req.ParentID,
+		Position:      req.Position,
 	}
 
 	// Set publish date if status is published
@@ -91,6 +140,11 @@ func (h *ContentHandler) CreateContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	usage.Reindex(h.db, content.ID)
+	if content.Status == "published" {
+		h.contentChanged(content)
+	}
+
 	utils.RespondWithSuccess(w, http.StatusCreated, content)
 }
 
@@ -101,6 +155,12 @@ type UpdateContentRequest struct {
 	Body     string `json:"body"`
 	Status   string `json:"status"`
 	MetaData string `json:"meta_data"`
+	Fields   map[string]interface{} `json:"fields"`
+	// SEO replaces the SEO settings when given
+	SEO      *models.SEO `json:"seo"`
+	// Draft saves edits to published content in its working draft instead of
+	// making them live
+	Draft bool `json:"draft"`
 }
 
 // UpdateContent handles content updates
@@ -137,33 +197,88 @@ func (h *ContentHandler) UpdateContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update fields
-	if req.Title != "" {
-		content.Title = req.Title
-	}
-	if req.Slug != "" {
-		content.Slug = req.Slug
-	}
-	if req.Body != "" {
-		content.Body = req.Body
+	if err := checkSlug(req.Slug); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	if req.Status != "" {
-		// Update publish date if status changed to published
-		if content.Status != "published" && req.Status == "published" {
-			now := time.Now()
-			content.PublishedAt = &now
+	if req.SEO != nil {
+		if err := seo.Check(h.db, content.WorkspaceID, req.SEO); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
-		content.Status = req.Status
 	}
-	if req.MetaData != "" {
-		content.MetaData = req.MetaData
+
+	// Draft edits of published content leave the live version serving
+	if content.Status == "published" && req.Draft {
+		if req.Status != "" && req.Status != "published" {
+			utils.RespondWithError(w, http.StatusBadRequest, "A draft cannot change the status of published content")
+			return
+		}
+		working, err := h.saveDraft(content, req, claims.UserID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save draft")
+			return
+		}
+		usage.Reindex(h.db, content.ID)
+		utils.RespondWithSuccess(w, http.StatusOK, working)
+		return
 	}
 
-	if err := h.db.Save(&content).Error; err != nil {
+	// Other edits of published content go live or unpublish it. A pending
+	// draft is never published by them, so they wait until it is published
+	// or discarded.
+	err := db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		if content.Status == "published" {
+			draft, err := findDraft(tx, content.ID)
+			if err != nil {
+				return err
+			}
+			if draft != nil {
+				return errDraftPending
+			}
+		}
+
+		// Update fields
+		if req.Title != "" {
+			content.Title = req.Title
+		}
+		if req.Slug != "" {
+			content.Slug = req.Slug
+		}
+		if req.Body != "" {
+			content.Body = req.Body
+		}
+		if req.Status != "" {
+			// Update publish date if status changed to published
+			if content.Status != "published" && req.Status == "published" {
+				now := time.Now()
+				content.PublishedAt = &now
+			}
+			content.Status = req.Status
+		}
+		if req.MetaData != "" {
+			content.MetaData = req.MetaData
+		}
+		if req.Fields != nil {
+			content.Fields = req.Fields
+		}
+		if req.SEO != nil {
+			content.SEO = *req.SEO
+		}
+		return tx.Save(&content).Error
+	})
+	if errors.Is(err, errDraftPending) {
+		utils.RespondWithError(w, http.StatusConflict, "Content has an unpublished draft; save with draft: true, or publish or discard the draft first")
+		return
+	}
+	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update content")
 		return
 	}
 
+	usage.Reindex(h.db, content.ID)
+	h.contentChanged(content)
+
 	utils.RespondWithSuccess(w, http.StatusOK, content)
 }
 
@@ -175,8 +290,14 @@ func (h *ContentHandler) GetContent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    projection, err := parseContentProjection(r, true)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
     var content models.Content
-    if err := h.db.Preload("Author").Preload("ContentType").Preload("Workspace").First(&content, id).Error; err != nil {
+    if err := projection.Apply(h.db.Model(&models.Content{})).First(&content, id).Error; err != nil {
         utils.RespondWithError(w, http.StatusNotFound, "Content not found")
         return
     }
@@ -203,7 +324,13 @@ func (h *ContentHandler) GetContent(w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    utils.RespondWithSuccess(w, http.StatusOK, content)
+    rendered, err := projection.Render(content)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render content")
+        return
+    }
+
+    utils.RespondWithSuccess(w, http.StatusOK, rendered)
 }
 
 // ListContent handles listing content items
@@ -229,7 +356,13 @@ func (h *ContentHandler) ListContent(w http.ResponseWriter, r *http.Request) {
         }
     }
 
-    query := h.db.Model(&models.Content{}).Preload("Author").Preload("ContentType")
+    projection, err := parseContentProjection(r, true)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    query := h.db.Model(&models.Content{})
 
     if workspaceID != "" {
         query = query.Where("workspace_id = ?", workspaceID)
@@ -251,13 +384,19 @@ func (h *ContentHandler) ListContent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    if err := query.Limit(limit).Offset(offset).Order("created_at desc").Find(&contents).Error; err != nil {
+    if err := projection.Apply(query).Limit(limit).Offset(offset).Order("created_at desc").Find(&contents).Error; err != nil {
         utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch contents")
         return
     }
 
+    rendered, err := projection.RenderList(contents)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render contents")
+        return
+    }
+
     utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
-        "contents": contents,
+        "contents": rendered,
         "total":    total,
         "limit":    limit,
         "offset":   offset,
@@ -292,13 +431,43 @@ func (h *ContentHandler) DeleteContent(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // Check if content has child pages
+    var childCount int64
+    if err := h.db.Model(&models.Content{}).Where("parent_id = ?", content.ID).Count(&childCount).Error; err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check child content")
+        return
+    }
+
+    if childCount > 0 {
+        utils.RespondWithError(w, http.StatusBadRequest, "Cannot delete content that has child pages")
+        return
+    }
+
+    // Links left pointing at the content are reported, not blocked
+    references, err := links.ContentReferences(h.db, content)
+    if err != nil {
+        logReferenceError("content", content.ID, err)
+    }
+
     // Delete content
     if err := h.db.Delete(&content).Error; err != nil {
         utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete content")
         return
     }
 
-    utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Content deleted successfully"})
+    // Drop any pending draft along with it
+    if err := h.db.Unscoped().Where("content_id = ?", content.ID).Delete(&models.ContentDraft{}).Error; err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete content draft")
+        return
+    }
+
+    usage.Reindex(h.db, content.ID)
+
+    if content.Status == "published" {
+        h.contentChanged(content)
+    }
+
+    utils.RespondWithSuccess(w, http.StatusOK, deleteResponse("Content deleted successfully", "content", references))
 }
 
 // GetContentBySlug handles getting content by slug
@@ -317,16 +486,38 @@ func (h *ContentHandler) GetContentBySlug(w http.ResponseWriter, r *http.Request
         return
     }
 
+    projection, err := parseContentProjection(r, false)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
     var content models.Content
-    if err := h.db.Where("workspace_id = ? AND slug = ? AND status = ?", workspaceObj.ID, slug, "published").
-        Preload("Author").
-        Preload("ContentType").
+    if err := projection.Apply(h.db.Model(&models.Content{})).
+        Where("workspace_id = ? AND slug = ? AND status = ?", workspaceObj.ID, slug, "published").
         First(&content).Error; err != nil {
         utils.RespondWithError(w, http.StatusNotFound, "Content not found")
         return
     }
 
-    utils.RespondWithSuccess(w, http.StatusOK, content)
+    h.views.RecordRequest(r, content)
+
+    cache.TagWorkspace(r, workspaceObj)
+    cache.TagContent(r, content)
+    cache.MarkViewed(r, content)
+    cache.Modified(r, workspaceObj.UpdatedAt, content.UpdatedAt, content.Author.UpdatedAt, content.ContentType.UpdatedAt)
+    cache.Version(r, workspaceObj.ID, workspaceObj.UpdatedAt, content.ID, content.UpdatedAt, content.Author.UpdatedAt, content.ContentType.UpdatedAt)
+    if cache.NotModified(r) {
+        return
+    }
+
+    rendered, err := projection.Render(h.public.Content(content))
+    if err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render content")
+        return
+    }
+
+    utils.RespondWithSuccess(w, http.StatusOK, rendered)
 }
 
 // GetPublishedContent handles getting published content for a workspace
@@ -362,10 +553,14 @@ func (h *ContentHandler) GetPublishedContent(w http.ResponseWriter, r *http.Requ
         }
     }
 
+    projection, err := parseContentProjection(r, false)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
     query := h.db.Model(&models.Content{}).
-        Where("workspace_id = ? AND status = ?", workspaceObj.ID, "published").
-        Preload("Author").
-        Preload("ContentType")
+        Where("workspace_id = ? AND status = ?", workspaceObj.ID, "published")
 
     if contentTypeID != "" {
         query = query.Where("content_type_id = ?", contentTypeID)
@@ -379,13 +574,30 @@ func (h *ContentHandler) GetPublishedContent(w http.ResponseWriter, r *http.Requ
         return
     }
 
-    if err := query.Limit(limit).Offset(offset).Order("published_at desc").Find(&contents).Error; err != nil {
+    if err := projection.Apply(query).Limit(limit).Offset(offset).Order("published_at desc").Find(&contents).Error; err != nil {
         utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch contents")
         return
     }
 
+    cache.TagWorkspace(r, workspaceObj)
+    cache.Tag(r, cache.Listing(workspaceObj.ID))
+    cache.TagContent(r, contents...)
+    cache.Version(r, workspaceObj.ID, workspaceObj.UpdatedAt, total)
+    for _, content := range contents {
+        cache.Version(r, content.ID, content.UpdatedAt, content.Author.UpdatedAt, content.ContentType.UpdatedAt)
+    }
+    if cache.NotModified(r) {
+        return
+    }
+
+    rendered, err := projection.RenderPublic(h.public, contents)
+    if err != nil {
+        utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render contents")
+        return
+    }
+
     utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
-        "contents": contents,
+        "contents": rendered,
         "total":    total,
         "limit":    limit,
         "offset":   offset,
@@ -419,6 +631,10 @@ func (h *ContentHandler) CreateContentType(w http.ResponseWriter, r *http.Reques
     if req.Slug == "" {
         req.Slug = utils.ToSlug(req.Name)
     }
+    if err := checkTypeSlug(req.Slug); err != nil {
+        utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
 
     // Create content type
     contentType := models.ContentType{
@@ -471,6 +687,10 @@ func (h *ContentHandler) UpdateContentType(w http.ResponseWriter, r *http.Reques
         contentType.Name = req.Name
     }
     if req.Slug != "" {
+        if err := checkTypeSlug(req.Slug); err != nil {
+            utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
         contentType.Slug = req.Slug
     }
     if req.Description != "" {
@@ -485,6 +705,8 @@ func (h *ContentHandler) UpdateContentType(w http.ResponseWriter, r *http.Reques
         return
     }
 
+    h.cache.Invalidate(cache.ContentType(contentType.ID))
+
     utils.RespondWithSuccess(w, http.StatusOK, contentType)
 }
 
@@ -548,6 +770,8 @@ func (h *ContentHandler) DeleteContentType(w http.ResponseWriter, r *http.Reques
         utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete content type")
         return
     }
+
+    h.cache.Invalidate(cache.ContentType(utils.ParseUint(id)))
     
     utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Content type deleted successfully"})
 }
\ No newline at end of file
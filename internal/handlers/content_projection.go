@@ -0,0 +1,224 @@
+// internal/handlers/content_projection.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// contentColumns maps the JSON names of content attributes to their database columns
+var contentColumns = map[string]string{
+	"id":              "id",
+	"created_at":      "created_at",
+	"updated_at":      "updated_at",
+	"workspace_id":    "workspace_id",
+	"content_type_id": "content_type_id",
+	"title":           "title",
+	"slug":            "slug",
+	"body":            "body",
+	"status":          "status",
+	"author_id":       "author_id",
+	"published_at":    "published_at",
+	"meta_data":       "meta_data",
+	"fields":          "fields",
+	"seo":             "seo",
+	"parent_id":       "parent_id",
+	"position":        "position",
+}
+
+// ContentProjection describes which parts of a content item a request asked for.
+// It is driven by the ?fields= and ?include= query parameters.
+type ContentProjection struct {
+	// Fields holds the requested top-level attributes, nil means all of them
+	Fields map[string]bool
+	// FieldKeys holds the custom field values requested as fields.<key>
+	FieldKeys          []string
+	IncludeAuthor      bool
+	IncludeContentType bool
+	active             bool
+}
+
+// parseContentProjection reads ?fields= and ?include= from the request.
+// Without either parameter every column is loaded, and expand keeps the legacy
+// behaviour of the admin endpoints of preloading both the author and the
+// content type. Public endpoints pass false so relations are only loaded when
+// ?include= names them.
+func parseContentProjection(r *http.Request, expand bool) (*ContentProjection, error) {
+	fieldsParam := r.URL.Query().Get("fields")
+	includeParam := r.URL.Query().Get("include")
+
+	if fieldsParam == "" && includeParam == "" {
+		return &ContentProjection{IncludeAuthor: expand, IncludeContentType: expand}, nil
+	}
+
+	p := &ContentProjection{active: true}
+
+	if fieldsParam != "" {
+		p.Fields = map[string]bool{}
+		for _, name := range strings.Split(fieldsParam, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if key, ok := strings.CutPrefix(name, "fields."); ok {
+				if key == "" {
+					return nil, fmt.Errorf("invalid field: %s", name)
+				}
+				p.FieldKeys = append(p.FieldKeys, key)
+				continue
+			}
+			if _, ok := contentColumns[name]; !ok {
+				return nil, fmt.Errorf("unknown field: %s", name)
+			}
+			p.Fields[name] = true
+		}
+	}
+
+	for _, name := range strings.Split(includeParam, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "author":
+			p.IncludeAuthor = true
+		case "content_type":
+			p.IncludeContentType = true
+		default:
+			return nil, fmt.Errorf("unknown include: %s", name)
+		}
+	}
+
+	return p, nil
+}
+
+// Apply restricts the columns loaded by query and preloads the requested relations
+func (p *ContentProjection) Apply(query *gorm.DB) *gorm.DB {
+	if p.Fields != nil {
+		// The workspace and content type are always loaded so access checks
+		// and view analytics keep working, and the update time so public
+		// responses can carry Last-Modified
+		columns := []string{"id", "workspace_id", "content_type_id", "updated_at"}
+		add := func(column string) {
+			for _, c := range columns {
+				if c == column {
+					return
+				}
+			}
+			columns = append(columns, column)
+		}
+
+		for name := range p.Fields {
+			add(contentColumns[name])
+		}
+		if len(p.FieldKeys) > 0 {
+			add("fields")
+		}
+		// The foreign key is needed for the preload to resolve
+		if p.IncludeAuthor {
+			add("author_id")
+		}
+
+		query = query.Select(columns)
+	}
+
+	if p.IncludeAuthor {
+		query = query.Preload("Author")
+	}
+	if p.IncludeContentType {
+		query = query.Preload("ContentType")
+	}
+
+	return query
+}
+
+// Render returns the sparse representation of a content item, given either as a
+// models.Content or as its public representation
+func (p *ContentProjection) Render(content interface{}) (interface{}, error) {
+	if !p.active {
+		return content, nil
+	}
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var full map[string]interface{}
+	if err := json.Unmarshal(encoded, &full); err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	for name := range contentColumns {
+		value, ok := full[name]
+		if !ok {
+			continue
+		}
+		if name == "id" || p.Fields == nil || p.Fields[name] {
+			out[name] = value
+		}
+	}
+
+	// Pick individual custom field values unless the whole set was requested
+	if len(p.FieldKeys) > 0 && (p.Fields == nil || !p.Fields["fields"]) {
+		values, _ := full["fields"].(map[string]interface{})
+		picked := map[string]interface{}{}
+		for _, key := range p.FieldKeys {
+			if value, ok := values[key]; ok {
+				picked[key] = value
+			}
+		}
+		out["fields"] = picked
+	}
+
+	if author, ok := full["author"]; ok && p.IncludeAuthor {
+		out["author"] = author
+	}
+	if contentType, ok := full["content_type"]; ok && p.IncludeContentType {
+		out["content_type"] = contentType
+	}
+
+	return out, nil
+}
+
+// RenderList returns the sparse representation of several content items
+func (p *ContentProjection) RenderList(contents []models.Content) (interface{}, error) {
+	if !p.active {
+		return contents, nil
+	}
+
+	out := make([]interface{}, 0, len(contents))
+	for _, content := range contents {
+		rendered, err := p.Render(content)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rendered)
+	}
+
+	return out, nil
+}
+
+// RenderPublic returns the sparse public representation of several content items
+func (p *ContentProjection) RenderPublic(public *delivery.Serializer, contents []models.Content) (interface{}, error) {
+	objects := public.ContentList(contents)
+	if !p.active {
+		return objects, nil
+	}
+
+	out := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		rendered, err := p.Render(obj)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rendered)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,81 @@
+// internal/handlers/content_projection_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+func TestContentListIncludes(t *testing.T) {
+	tests := []struct {
+		name   string
+		public bool
+		query  string
+		// author and contentType are whether the relations are in the response
+		author      bool
+		contentType bool
+	}{
+		{name: "public", public: true},
+		{name: "public with author", public: true, query: "?include=author", author: true},
+		{name: "public with both", public: true, query: "?include=author,content_type", author: true, contentType: true},
+		{name: "public sparse", public: true, query: "?fields=title"},
+		// Admin endpoints keep expanding both relations by default
+		{name: "admin", author: true, contentType: true},
+		{name: "admin with content type", query: "?include=content_type", contentType: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			h := NewContentHandler(database, nil, delivery.NewSerializer(config.DeliveryConfig{}, nil), nil, nil, nil)
+
+			workspace := models.Workspace{Name: "Site", Slug: "site"}
+			user := models.User{Email: "editor@example.com", PasswordHash: "secret", DisplayName: "Ed"}
+			contentType := models.ContentType{WorkspaceID: 1, Name: "Post", Slug: "post"}
+			for _, record := range []interface{}{&workspace, &user, &contentType} {
+				if err := database.Create(record).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := time.Now()
+			content := models.Content{WorkspaceID: workspace.ID, ContentTypeID: contentType.ID, Title: "Hello", Slug: "hello",
+				Status: "published", AuthorID: user.ID, PublishedAt: &now}
+			if err := database.Create(&content).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			var code int
+			var data json.RawMessage
+			if tt.public {
+				code, data = serve(t, h.GetPublishedContent, http.MethodGet, "/content/{workspace}", "/content/site"+tt.query, nil, nil)
+			} else {
+				code, data = serve(t, h.ListContent, http.MethodGet, "/content", "/content"+tt.query, nil, admin)
+			}
+			if code != http.StatusOK {
+				t.Fatalf("GET = %d %s, want 200", code, data)
+			}
+
+			var list struct {
+				Contents []map[string]json.RawMessage `json:"contents"`
+			}
+			if err := json.Unmarshal(data, &list); err != nil {
+				t.Fatal(err)
+			}
+			if len(list.Contents) != 1 {
+				t.Fatalf("%d contents, want 1", len(list.Contents))
+			}
+			_, author := list.Contents[0]["author"]
+			_, included := list.Contents[0]["content_type"]
+			if author != tt.author || included != tt.contentType {
+				t.Errorf("author included %v and content type %v, want %v and %v", author, included, tt.author, tt.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+// internal/handlers/content_related_handler.go
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/cache"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/related"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// relatedLimit reads ?limit= with a default of 5 and a maximum of related.MaxStored
+func relatedLimit(r *http.Request) int {
+	limit := 5
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 {
+		limit = parsed
+	}
+	if limit > related.MaxStored {
+		limit = related.MaxStored
+	}
+	return limit
+}
+
+// GetRelatedContent handles getting the published content most related to a published item
+func (h *ContentHandler) GetRelatedContent(w http.ResponseWriter, r *http.Request) {
+	workspace := chi.URLParam(r, "workspace")
+	slug := chi.URLParam(r, "slug")
+
+	if workspace == "" || slug == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace and slug are required")
+		return
+	}
+
+	var workspaceObj models.Workspace
+	if err := h.db.Where("slug = ?", workspace).First(&workspaceObj).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	var content models.Content
+	if err := h.db.Where("workspace_id = ? AND slug = ? AND status = ?", workspaceObj.ID, slug, "published").
+		First(&content).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	matches, err := related.Lookup(h.db, content, relatedLimit(r))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch related content")
+		return
+	}
+
+	ids := make([]uint, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.ContentID)
+	}
+
+	var found []models.Content
+	if len(ids) > 0 {
+		if err := h.db.Preload("Author").Preload("ContentType").
+			Where("id IN ? AND workspace_id = ? AND status = ?", ids, workspaceObj.ID, "published").
+			Find(&found).Error; err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch related content")
+			return
+		}
+	}
+
+	// Keep the order of the scores, skipping items unpublished since the last refresh
+	byID := make(map[uint]models.Content, len(found))
+	for _, c := range found {
+		byID[c.ID] = c
+	}
+	contents := make([]models.Content, 0, len(found))
+	for _, id := range ids {
+		if c, ok := byID[id]; ok {
+			contents = append(contents, c)
+		}
+	}
+
+	cache.TagWorkspace(r, workspaceObj)
+	cache.Tag(r, cache.Related(workspaceObj.ID), cache.Listing(workspaceObj.ID))
+	cache.TagContent(r, content)
+	cache.TagContent(r, contents...)
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
+		"contents": h.public.ContentList(contents),
+	})
+}
@@ -0,0 +1,536 @@
+// internal/handlers/content_tree_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/export"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// ContentTreeNode represents a content item and its children in a page hierarchy
+type ContentTreeNode struct {
+	ID            uint               `json:"id"`
+	ParentID      *uint              `json:"parent_id"`
+	ContentTypeID uint               `json:"content_type_id"`
+	Title         string             `json:"title"`
+	Slug          string             `json:"slug"`
+	Path          string             `json:"path"`
+	Status        string             `json:"status"`
+	Position      int                `json:"position"`
+	Children      []*ContentTreeNode `json:"children"`
+}
+
+// Breadcrumb represents a single ancestor of a content item
+type Breadcrumb struct {
+	ID    uint   `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Path  string `json:"path"`
+}
+
+// MoveContentRequest represents a request to move content within the hierarchy
+type MoveContentRequest struct {
+	ParentID *uint `json:"parent_id"`
+	Position int   `json:"position"`
+}
+
+// ReorderContentRequest represents a request to reorder sibling content items
+type ReorderContentRequest struct {
+	ParentID *uint  `json:"parent_id"`
+	IDs      []uint `json:"ids"`
+}
+
+// reservedSlugs are the path segments routed next to /{slug} in the public
+// content API, which content slugs must not take
+var reservedSlugs = map[string]bool{
+	"tree": true,
+	"path": true,
+}
+
+// checkSlug returns an error if a content slug is reserved by the public
+// content API or the static export
+func checkSlug(slug string) error {
+	if reservedSlugs[slug] || export.ReservedSlug(slug) {
+		return fmt.Errorf("slug %q is reserved", slug)
+	}
+	return nil
+}
+
+// checkTypeSlug returns an error if a content type slug is reserved by the
+// static export
+func checkTypeSlug(slug string) error {
+	if export.ReservedTypeSlug(slug) {
+		return fmt.Errorf("slug %q is reserved", slug)
+	}
+	return nil
+}
+
+// hasWorkspaceAccess reports whether the user in claims may access the given workspace
+func hasWorkspaceAccess(database *db.DB, claims *auth.Claims, workspaceID uint) (bool, error) {
+	if claims.RoleName == "admin" {
+		return true, nil
+	}
+
+	var count int64
+	if err := database.Model(&models.UserWorkspace{}).Where("user_id = ? AND workspace_id = ?", claims.UserID, workspaceID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// buildContentTree arranges a flat list of content into a tree ordered by position.
+// Items whose parent is not part of the list are treated as roots.
+func buildContentTree(contents []models.Content) []*ContentTreeNode {
+	nodes := make(map[uint]*ContentTreeNode, len(contents))
+	for _, c := range contents {
+		nodes[c.ID] = &ContentTreeNode{
+			ID:            c.ID,
+			ParentID:      c.ParentID,
+			ContentTypeID: c.ContentTypeID,
+			Title:         c.Title,
+			Slug:          c.Slug,
+			Status:        c.Status,
+			Position:      c.Position,
+			Children:      []*ContentTreeNode{},
+		}
+	}
+
+	roots := []*ContentTreeNode{}
+	for _, c := range contents {
+		node := nodes[c.ID]
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var finalize func(list []*ContentTreeNode, prefix string)
+	finalize = func(list []*ContentTreeNode, prefix string) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Position != list[j].Position {
+				return list[i].Position < list[j].Position
+			}
+			return list[i].ID < list[j].ID
+		})
+		for _, node := range list {
+			node.Path = prefix + "/" + node.Slug
+			finalize(node.Children, node.Path)
+		}
+	}
+	finalize(roots, "")
+
+	return roots
+}
+
+// contentAncestors returns the ancestors of a content item ordered from the root down
+func (h *ContentHandler) contentAncestors(content models.Content) ([]models.Content, error) {
+	ancestors := []models.Content{}
+	seen := map[uint]bool{content.ID: true}
+
+	parentID := content.ParentID
+	for parentID != nil {
+		if seen[*parentID] {
+			break
+		}
+		seen[*parentID] = true
+
+		var parent models.Content
+		if err := h.db.Select("id", "parent_id", "title", "slug").First(&parent, *parentID).Error; err != nil {
+			return nil, err
+		}
+		ancestors = append([]models.Content{parent}, ancestors...)
+		parentID = parent.ParentID
+	}
+
+	return ancestors, nil
+}
+
+// breadcrumbsFor builds breadcrumb data for a content item, including the item itself
+func (h *ContentHandler) breadcrumbsFor(content models.Content) ([]Breadcrumb, error) {
+	ancestors, err := h.contentAncestors(content)
+	if err != nil {
+		return nil, err
+	}
+
+	breadcrumbs := make([]Breadcrumb, 0, len(ancestors)+1)
+	path := ""
+	for _, c := range append(ancestors, content) {
+		path += "/" + c.Slug
+		breadcrumbs = append(breadcrumbs, Breadcrumb{
+			ID:    c.ID,
+			Title: c.Title,
+			Slug:  c.Slug,
+			Path:  path,
+		})
+	}
+
+	return breadcrumbs, nil
+}
+
+// publicTree returns the public representation of a page tree
+func (h *ContentHandler) publicTree(nodes []*ContentTreeNode) []delivery.Object {
+	out := make([]delivery.Object, 0, len(nodes))
+	for _, node := range nodes {
+		obj := delivery.Object{
+			"title":    node.Title,
+			"slug":     node.Slug,
+			"path":     node.Path,
+			"position": node.Position,
+			"children": h.publicTree(node.Children),
+		}
+		if !h.public.StripInternalIDs() {
+			obj["id"] = node.ID
+			obj["parent_id"] = node.ParentID
+			obj["content_type_id"] = node.ContentTypeID
+		}
+		out = append(out, obj)
+	}
+	return out
+}
+
+// publicBreadcrumbs returns the public representation of breadcrumb data
+func (h *ContentHandler) publicBreadcrumbs(breadcrumbs []Breadcrumb) []delivery.Object {
+	out := make([]delivery.Object, 0, len(breadcrumbs))
+	for _, b := range breadcrumbs {
+		obj := delivery.Object{
+			"title": b.Title,
+			"slug":  b.Slug,
+			"path":  b.Path,
+		}
+		if !h.public.StripInternalIDs() {
+			obj["id"] = b.ID
+		}
+		out = append(out, obj)
+	}
+	return out
+}
+
+// GetContentTree handles getting the full page hierarchy of a workspace
+func (h *ContentHandler) GetContentTree(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(chi.URLParam(r, "workspaceId"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	query := h.db.Model(&models.Content{}).
+		Select("id", "parent_id", "content_type_id", "title", "slug", "status", "position").
+		Where("workspace_id = ?", workspaceID)
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var contents []models.Content
+	if err := query.Find(&contents).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch contents")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, buildContentTree(contents))
+}
+
+// GetPublishedContentTree handles getting the published page hierarchy of a workspace
+func (h *ContentHandler) GetPublishedContentTree(w http.ResponseWriter, r *http.Request) {
+	workspace := chi.URLParam(r, "workspace")
+	if workspace == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace is required")
+		return
+	}
+
+	var workspaceObj models.Workspace
+	if err := h.db.Where("slug = ?", workspace).First(&workspaceObj).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	var contents []models.Content
+	if err := h.db.Model(&models.Content{}).
+		Select("id", "parent_id", "content_type_id", "title", "slug", "status", "position").
+		Where("workspace_id = ? AND status = ?", workspaceObj.ID, "published").
+		Find(&contents).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch contents")
+		return
+	}
+
+	// Pages below an unpublished parent end up as detached roots; drop them
+	// so an unpublished page hides its whole subtree from the public tree
+	tree := buildContentTree(contents)
+	roots := tree[:0]
+	for _, node := range tree {
+		if node.ParentID == nil {
+			roots = append(roots, node)
+		}
+	}
+
+	cache.TagWorkspace(r, workspaceObj)
+	cache.Tag(r, cache.Listing(workspaceObj.ID))
+
+	utils.RespondWithSuccess(w, http.StatusOK, h.publicTree(roots))
+}
+
+// GetContentByPath handles resolving a nested page path such as docs/install/linux
+func (h *ContentHandler) GetContentByPath(w http.ResponseWriter, r *http.Request) {
+	workspace := chi.URLParam(r, "workspace")
+	path := strings.Trim(chi.URLParam(r, "*"), "/")
+
+	if workspace == "" || path == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace and path are required")
+		return
+	}
+
+	projection, err := parseContentProjection(r, false)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var workspaceObj models.Workspace
+	if err := h.db.Where("slug = ?", workspace).First(&workspaceObj).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	// Walk the hierarchy one segment at a time, starting from the root pages
+	var content models.Content
+	var parentID *uint
+	for _, segment := range strings.Split(path, "/") {
+		query := h.db.Where("workspace_id = ? AND slug = ? AND status = ?", workspaceObj.ID, segment, "published")
+		if parentID == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", *parentID)
+		}
+
+		content = models.Content{}
+		if err := query.Select("id", "parent_id", "title", "slug").Order("position asc").First(&content).Error; err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+			return
+		}
+		id := content.ID
+		parentID = &id
+	}
+
+	breadcrumbs, err := h.breadcrumbsFor(content)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build breadcrumbs")
+		return
+	}
+
+	var item models.Content
+	if err := projection.Apply(h.db.Model(&models.Content{})).First(&item, content.ID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch content")
+		return
+	}
+
+	rendered, err := projection.Render(h.public.Content(item))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to render content")
+		return
+	}
+
+	h.views.RecordRequest(r, item)
+
+	// The path depends on the slugs of every ancestor
+	cache.TagWorkspace(r, workspaceObj)
+	cache.Tag(r, cache.Listing(workspaceObj.ID))
+	cache.TagContent(r, item)
+	cache.MarkViewed(r, item)
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
+		"content":     rendered,
+		"breadcrumbs": h.publicBreadcrumbs(breadcrumbs),
+	})
+}
+
+// MoveContent handles moving content to a new parent and position
+func (h *ContentHandler) MoveContent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content ID is required")
+		return
+	}
+
+	var req MoveContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Get content by ID
+	var content models.Content
+	if err := h.db.First(&content, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	// Check if user has permission to move this content
+	if claims.RoleName != "admin" && claims.UserID != content.AuthorID {
+		utils.RespondWithError(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.Content
+		if err := h.db.Where("id = ? AND workspace_id = ?", *req.ParentID, content.WorkspaceID).First(&parent).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Parent content not found in this workspace")
+			return
+		}
+
+		// Refuse to move content underneath itself or one of its descendants
+		if parent.ID == content.ID {
+			utils.RespondWithError(w, http.StatusBadRequest, "Content cannot be its own parent")
+			return
+		}
+		ancestors, err := h.contentAncestors(parent)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check content hierarchy")
+			return
+		}
+		for _, ancestor := range ancestors {
+			if ancestor.ID == content.ID {
+				utils.RespondWithError(w, http.StatusBadRequest, "Cannot move content underneath one of its descendants")
+				return
+			}
+		}
+	}
+
+	if err := h.db.Model(&content).Updates(map[string]interface{}{
+		"parent_id": req.ParentID,
+		"position":  req.Position,
+	}).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to move content")
+		return
+	}
+
+	content.ParentID = req.ParentID
+	content.Position = req.Position
+
+	if content.Status == "published" {
+		h.contentChanged(content)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, content)
+}
+
+// ReorderContent handles setting the order of sibling content items
+func (h *ContentHandler) ReorderContent(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(chi.URLParam(r, "workspaceId"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	var req ReorderContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content IDs are required")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	// All items must be siblings under the given parent
+	query := h.db.Model(&models.Content{}).Where("workspace_id = ? AND id IN ?", workspaceID, req.IDs)
+	if req.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *req.ParentID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check content")
+		return
+	}
+	if int(count) != len(req.IDs) {
+		utils.RespondWithError(w, http.StatusBadRequest, "All content items must exist and share the given parent")
+		return
+	}
+
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+
+	for i, contentID := range req.IDs {
+		if err := tx.Model(&models.Content{}).Where("id = ?", contentID).Update("position", i).Error; err != nil {
+			tx.Rollback()
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reorder content")
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	tags := []string{cache.Listing(workspaceID)}
+	for _, contentID := range req.IDs {
+		tags = append(tags, cache.Content(contentID))
+	}
+	h.cache.Invalidate(tags...)
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Content reordered successfully"})
+}
@@ -0,0 +1,122 @@
+// internal/handlers/export_handler.go
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/export"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// ExportJob represents the state of a static export run
+type ExportJob struct {
+	WorkspaceID uint           `json:"workspace_id"`
+	Status      string         `json:"status"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+	Result      *export.Result `json:"result,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// ExportHandler handles static export requests
+type ExportHandler struct {
+	db       *db.DB
+	exporter *export.Exporter
+	mu       sync.Mutex
+	jobs     map[uint]*ExportJob
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(db *db.DB, exporter *export.Exporter) *ExportHandler {
+	return &ExportHandler{
+		db:       db,
+		exporter: exporter,
+		jobs:     make(map[uint]*ExportJob),
+	}
+}
+
+// StartExport handles triggering a static export of a workspace
+func (h *ExportHandler) StartExport(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	h.mu.Lock()
+	if job, ok := h.jobs[workspace.ID]; ok && job.Status == "running" {
+		h.mu.Unlock()
+		utils.RespondWithError(w, http.StatusConflict, "An export is already running for this workspace")
+		return
+	}
+	job := &ExportJob{
+		WorkspaceID: workspace.ID,
+		Status:      "running",
+		StartedAt:   time.Now(),
+	}
+	h.jobs[workspace.ID] = job
+	snapshot := *job
+	h.mu.Unlock()
+
+	go h.run(job, workspace.Slug)
+
+	utils.RespondWithSuccess(w, http.StatusAccepted, snapshot)
+}
+
+// run performs the export in the background and records its outcome
+func (h *ExportHandler) run(job *ExportJob, workspaceSlug string) {
+	result, err := h.exporter.Export(workspaceSlug, "")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+		slog.Error("Static export failed", "workspace", workspaceSlug, "error", err)
+		return
+	}
+
+	job.Status = "completed"
+	job.Result = result
+	slog.Info("Static export completed", "workspace", workspaceSlug, "files", result.Files)
+}
+
+// GetExport handles getting the status of the latest export of a workspace
+func (h *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(chi.URLParam(r, "id"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	h.mu.Lock()
+	job, ok := h.jobs[workspaceID]
+	var snapshot ExportJob
+	if ok {
+		snapshot = *job
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		utils.RespondWithError(w, http.StatusNotFound, "No export has been run for this workspace")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, snapshot)
+}
@@ -0,0 +1,92 @@
+// internal/handlers/handlers_test.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/uploads"
+)
+
+// admin and editor are the users requests of the tests are made as
+var (
+	admin  = &auth.Claims{UserID: 1, RoleName: "admin"}
+	editor = &auth.Claims{UserID: 2, RoleName: "editor"}
+)
+
+// serve makes a request to a handler mounted at pattern as the user in
+// claims, and returns the response status and its decoded data
+func serve(t *testing.T, handler http.HandlerFunc, method, pattern, target string, body io.Reader, claims *auth.Claims) (int, json.RawMessage) {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.MethodFunc(method, pattern, handler)
+
+	req := httptest.NewRequest(method, target, body)
+	if claims != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response struct {
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
+	}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, target, rec.Body.String(), err)
+		}
+	}
+	if response.Error != "" {
+		response.Data, _ = json.Marshal(response.Error)
+	}
+	return rec.Code, response.Data
+}
+
+// jsonBody encodes a request body
+func jsonBody(t *testing.T, v interface{}) io.Reader {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.NewReader(string(data))
+}
+
+// testUploads allows the text files the media tests upload
+var testUploads = config.UploadsConfig{
+	AllowedTypes:  []string{"text/plain"},
+	MaxSize:       1,
+	PartialExpiry: 24,
+}
+
+// newMediaHandler returns a media handler with the upload settings of cfg on
+// a fresh database and local storage, holding a workspace of ID 1
+func newMediaHandler(t *testing.T, cfg config.UploadsConfig) (*MediaHandler, *db.DB, storage.Manager) {
+	t.Helper()
+	database := dbtest.New(t)
+	store := storage.NewLocalStorage(t.TempDir(), storage.NewSigner(config.StorageConfig{URLSecret: "secret"}, ""))
+	processor := images.NewProcessor(database, store, config.ImagesConfig{}, "secret")
+	h := NewMediaHandler(database, store, processor, uploads.NewChecker(cfg), uploads.NewPartials(store, cfg.PartialExpiry), nil)
+
+	if err := database.Create(&models.Workspace{Name: "Site", Slug: "site"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return h, database, store
+}
@@ -0,0 +1,94 @@
+// internal/handlers/link_handler.go
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/links"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// LinkHandler handles broken link checks
+type LinkHandler struct {
+	db *db.DB
+}
+
+// NewLinkHandler creates a new link handler
+func NewLinkHandler(db *db.DB) *LinkHandler {
+	return &LinkHandler{
+		db: db,
+	}
+}
+
+// DeleteResponse is returned when deleting content or media other content may still link to
+type DeleteResponse struct {
+	Message string `json:"message"`
+	// Warning explains References when there are any
+	Warning    string            `json:"warning,omitempty"`
+	References []links.Reference `json:"references,omitempty"`
+}
+
+// deleteResponse builds the response to deleting a target still linked from references
+func deleteResponse(message, target string, references []links.Reference) DeleteResponse {
+	resp := DeleteResponse{Message: message}
+	if len(references) == 0 {
+		return resp
+	}
+
+	items := map[uint]bool{}
+	for _, reference := range references {
+		items[reference.ContentID] = true
+	}
+	subject := fmt.Sprintf("%d content items still link", len(items))
+	if len(items) == 1 {
+		subject = "1 content item still links"
+	}
+	resp.Warning = fmt.Sprintf("%s to this %s", subject, target)
+	resp.References = references
+	return resp
+}
+
+// logReferenceError notes a failed reference lookup; deleting goes ahead regardless
+func logReferenceError(kind string, id uint, err error) {
+	slog.Warn("Failed to look up links to deleted "+kind, "id", id, "error", err)
+}
+
+// GetBrokenLinks handles getting the broken links found by the last check of a workspace
+func (h *LinkHandler) GetBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	result, err := links.Stored(h.db, workspace.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch broken links")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, result)
+}
+
+// CheckLinks handles checking the links of a workspace right away
+func (h *LinkHandler) CheckLinks(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	result, err := links.Run(h.db, workspace)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check links")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, result)
+}
@@ -0,0 +1,383 @@
+// internal/handlers/media_folder_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// MediaFolderNode represents a media folder and its subfolders
+type MediaFolderNode struct {
+	ID         uint               `json:"id"`
+	ParentID   *uint              `json:"parent_id"`
+	Name       string             `json:"name"`
+	MediaCount int64              `json:"media_count"`
+	Children   []*MediaFolderNode `json:"children"`
+}
+
+// CreateMediaFolderRequest represents a request to create a media folder
+type CreateMediaFolderRequest struct {
+	WorkspaceID uint   `json:"workspace_id"`
+	ParentID    *uint  `json:"parent_id"`
+	Name        string `json:"name"`
+}
+
+// UpdateMediaFolderRequest represents a request to rename a media folder
+type UpdateMediaFolderRequest struct {
+	Name string `json:"name"`
+}
+
+// MoveMediaFolderRequest represents a request to move a media folder under
+// another one, or to the root when parent_id is null
+type MoveMediaFolderRequest struct {
+	ParentID *uint `json:"parent_id"`
+}
+
+// buildMediaFolderTree arranges the folders of a workspace into a tree ordered by name
+func buildMediaFolderTree(folders []models.MediaFolder, counts map[uint]int64) []*MediaFolderNode {
+	nodes := make(map[uint]*MediaFolderNode, len(folders))
+	for _, f := range folders {
+		nodes[f.ID] = &MediaFolderNode{
+			ID:         f.ID,
+			ParentID:   f.ParentID,
+			Name:       f.Name,
+			MediaCount: counts[f.ID],
+			Children:   []*MediaFolderNode{},
+		}
+	}
+
+	roots := []*MediaFolderNode{}
+	for _, f := range folders {
+		node := nodes[f.ID]
+		if f.ParentID != nil {
+			if parent, ok := nodes[*f.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var order func(list []*MediaFolderNode)
+	order = func(list []*MediaFolderNode) {
+		sort.SliceStable(list, func(i, j int) bool {
+			return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+		})
+		for _, node := range list {
+			order(node.Children)
+		}
+	}
+	order(roots)
+
+	return roots
+}
+
+// folderDescendants returns the IDs of a folder and every folder below it
+func (h *MediaHandler) folderDescendants(folder models.MediaFolder) ([]uint, error) {
+	var folders []models.MediaFolder
+	if err := h.db.Select("id", "parent_id").Where("workspace_id = ?", folder.WorkspaceID).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]uint{}
+	for _, f := range folders {
+		if f.ParentID != nil {
+			children[*f.ParentID] = append(children[*f.ParentID], f.ID)
+		}
+	}
+
+	ids := []uint{folder.ID}
+	seen := map[uint]bool{folder.ID: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// folderNameTaken reports whether a folder other than the given one has a
+// name among the children of a parent
+func (h *MediaHandler) folderNameTaken(workspaceID uint, parentID *uint, name string, except uint) (bool, error) {
+	query := h.db.Model(&models.MediaFolder{}).
+		Where("workspace_id = ? AND LOWER(name) = ? AND id <> ?", workspaceID, strings.ToLower(name), except)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// libraryEditor returns the user in the request if they are an editor or
+// admin with access to a workspace, and responds with an error otherwise
+func (h *MediaHandler) libraryEditor(w http.ResponseWriter, r *http.Request, workspaceID uint) (*auth.Claims, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return nil, false
+	}
+
+	if claims.RoleName != "admin" && claims.RoleName != "editor" {
+		utils.RespondWithError(w, http.StatusForbidden, "Editor or admin access required")
+		return nil, false
+	}
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return nil, false
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return nil, false
+	}
+	return claims, true
+}
+
+// editableFolder returns the folder of the URL if the user may change it
+func (h *MediaHandler) editableFolder(w http.ResponseWriter, r *http.Request) (*models.MediaFolder, bool) {
+	var folder models.MediaFolder
+	if err := h.db.First(&folder, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Folder not found")
+		return nil, false
+	}
+	if _, ok := h.libraryEditor(w, r, folder.WorkspaceID); !ok {
+		return nil, false
+	}
+	return &folder, true
+}
+
+// ListMediaFolders handles getting the folder tree of a workspace, with the
+// number of media items directly in each folder
+func (h *MediaHandler) ListMediaFolders(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(r.URL.Query().Get("workspace_id"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	var folders []models.MediaFolder
+	if err := h.db.Where("workspace_id = ?", workspaceID).Find(&folders).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch folders")
+		return
+	}
+
+	var rows []struct {
+		FolderID uint
+		Count    int64
+	}
+	if err := h.db.Model(&models.Media{}).Select("folder_id, COUNT(*) AS count").
+		Where("workspace_id = ? AND folder_id IS NOT NULL", workspaceID).
+		Group("folder_id").Scan(&rows).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to count media")
+		return
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.FolderID] = row.Count
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, buildMediaFolderTree(folders, counts))
+}
+
+// CreateMediaFolder handles creating a media folder
+func (h *MediaHandler) CreateMediaFolder(w http.ResponseWriter, r *http.Request) {
+	var req CreateMediaFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.WorkspaceID == 0 || req.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID and name are required")
+		return
+	}
+
+	if _, ok := h.libraryEditor(w, r, req.WorkspaceID); !ok {
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.MediaFolder
+		if err := h.db.Where("id = ? AND workspace_id = ?", *req.ParentID, req.WorkspaceID).First(&parent).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Parent folder not found in this workspace")
+			return
+		}
+	}
+
+	taken, err := h.folderNameTaken(req.WorkspaceID, req.ParentID, req.Name, 0)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder name")
+		return
+	}
+	if taken {
+		utils.RespondWithError(w, http.StatusConflict, "A folder with this name already exists here")
+		return
+	}
+
+	folder := models.MediaFolder{
+		WorkspaceID: req.WorkspaceID,
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+	}
+	if err := h.db.Create(&folder).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create folder")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, folder)
+}
+
+// UpdateMediaFolder handles renaming a media folder
+func (h *MediaHandler) UpdateMediaFolder(w http.ResponseWriter, r *http.Request) {
+	folder, ok := h.editableFolder(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMediaFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+
+	taken, err := h.folderNameTaken(folder.WorkspaceID, folder.ParentID, req.Name, folder.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder name")
+		return
+	}
+	if taken {
+		utils.RespondWithError(w, http.StatusConflict, "A folder with this name already exists here")
+		return
+	}
+
+	folder.Name = req.Name
+	if err := h.db.Model(folder).Update("name", req.Name).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update folder")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, folder)
+}
+
+// MoveMediaFolder handles moving a media folder, with everything in it, to
+// another parent folder
+func (h *MediaHandler) MoveMediaFolder(w http.ResponseWriter, r *http.Request) {
+	folder, ok := h.editableFolder(w, r)
+	if !ok {
+		return
+	}
+
+	var req MoveMediaFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.ParentID != nil {
+		var parent models.MediaFolder
+		if err := h.db.Where("id = ? AND workspace_id = ?", *req.ParentID, folder.WorkspaceID).First(&parent).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Parent folder not found in this workspace")
+			return
+		}
+
+		// Refuse to move a folder into itself or one of its subfolders
+		descendants, err := h.folderDescendants(*folder)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder hierarchy")
+			return
+		}
+		for _, id := range descendants {
+			if id == parent.ID {
+				utils.RespondWithError(w, http.StatusBadRequest, "Cannot move a folder into itself or one of its subfolders")
+				return
+			}
+		}
+	}
+
+	taken, err := h.folderNameTaken(folder.WorkspaceID, req.ParentID, folder.Name, folder.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder name")
+		return
+	}
+	if taken {
+		utils.RespondWithError(w, http.StatusConflict, "A folder with this name already exists there")
+		return
+	}
+
+	if err := h.db.Model(folder).Update("parent_id", req.ParentID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to move folder")
+		return
+	}
+	folder.ParentID = req.ParentID
+
+	utils.RespondWithSuccess(w, http.StatusOK, folder)
+}
+
+// DeleteMediaFolder handles deleting an empty media folder
+func (h *MediaHandler) DeleteMediaFolder(w http.ResponseWriter, r *http.Request) {
+	folder, ok := h.editableFolder(w, r)
+	if !ok {
+		return
+	}
+
+	var subfolders, media int64
+	if err := h.db.Model(&models.MediaFolder{}).Where("parent_id = ?", folder.ID).Count(&subfolders).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder contents")
+		return
+	}
+	if err := h.db.Model(&models.Media{}).Where("folder_id = ?", folder.ID).Count(&media).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check folder contents")
+		return
+	}
+	if subfolders > 0 || media > 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Folder is not empty; move or delete its media and subfolders first")
+		return
+	}
+
+	if err := h.db.Delete(folder).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete folder")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Folder deleted successfully"})
+}
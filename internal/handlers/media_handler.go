@@ -2,38 +2,96 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 
 	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/dedup"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/links"
 	"github.com/randilt/floe-cms/internal/middleware"
 	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
 	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/uploads"
+	"github.com/randilt/floe-cms/internal/usage"
 	"github.com/randilt/floe-cms/internal/utils"
 )
 
 // MediaHandler handles media-related requests
 type MediaHandler struct {
-	db      *db.DB
-	storage storage.Manager
+	db       *db.DB
+	storage  storage.Manager
+	images   *images.Processor
+	uploads  *uploads.Checker
+	partials *uploads.Partials
+	cache    *cache.Cache
 }
 
 // NewMediaHandler creates a new media handler
-func NewMediaHandler(db *db.DB, storage storage.Manager) *MediaHandler {
+func NewMediaHandler(db *db.DB, storage storage.Manager, processor *images.Processor, checker *uploads.Checker, partials *uploads.Partials, cache *cache.Cache) *MediaHandler {
 	return &MediaHandler{
-		db:      db,
-		storage: storage,
+		db:       db,
+		storage:  storage,
+		images:   processor,
+		uploads:  checker,
+		partials: partials,
+		cache:    cache,
 	}
 }
 
+// withURLs replaces the storage paths of a media item and its variants with
+// their URLs, which are signed and expire for private media
+func (h *MediaHandler) withURLs(media *models.Media) {
+	media.FilePath = h.storage.GetURL(media.FilePath, media.Private)
+	for i := range media.Variants {
+		media.Variants[i].FilePath = h.storage.GetURL(media.Variants[i].FilePath, media.Private)
+	}
+}
+
+// parseFlag parses an optional boolean form or metadata value
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// checkPrivate checks that the storage can keep the files of private media
+// from the public. It responds with an error and returns false when it cannot.
+func (h *MediaHandler) checkPrivate(w http.ResponseWriter) bool {
+	if err := h.storage.CheckPrivate(); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Private media is not available: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// canViewMedia reports whether the user in claims may see a media item and
+// get URLs of its file: private media is only shown to its workspace
+func (h *MediaHandler) canViewMedia(claims *auth.Claims, media models.Media) (bool, error) {
+	if !media.Private {
+		return true, nil
+	}
+	return hasWorkspaceAccess(h.db, claims, media.WorkspaceID)
+}
+
 // UploadMedia handles media uploads
 func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
-	// Parse multipart form
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
+	if !h.parseUploadForm(w, r) {
 		return
 	}
 
@@ -57,6 +115,21 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, workspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	private, err := parseFlag(r.FormValue("private"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid private flag")
+		return
+	}
+	if private && !h.checkPrivate(w) {
+		return
+	}
+
 	// Get file
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -65,22 +138,106 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// Save file
-	originalName, filePath, err := h.storage.Save(file, header, claims.UserID)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save file: "+err.Error())
+	checked, ok := h.checkUpload(w, workspace, file, header)
+	if !ok {
+		return
+	}
+	if !h.checkQuota(w, workspace, checked.Header.Size, 1) {
+		return
+	}
+	media, ok := h.storeMedia(w, workspace, checked, r.FormValue("name"), private, claims.UserID)
+	if !ok {
 		return
 	}
 
+	// Add URLs to response
+	h.withURLs(&media)
+
+	utils.RespondWithSuccess(w, http.StatusCreated, media)
+}
+
+// parseUploadForm parses a multipart upload form, refusing requests larger
+// than any upload allowed. It responds with an error and returns false when
+// the form is rejected.
+func (h *MediaHandler) parseUploadForm(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, h.uploads.MaxRequestSize())
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Upload is too large: no file type allows more than %d MiB", tooLarge.Limit>>20-1))
+			return false
+		}
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
+		return false
+	}
+	return true
+}
+
+// checkUpload checks an uploaded file against the workspace's allowlist and
+// the size limits. It responds with an error and returns false when the file
+// is rejected.
+func (h *MediaHandler) checkUpload(w http.ResponseWriter, workspace models.Workspace, file multipart.File, header *multipart.FileHeader) (*uploads.Checked, bool) {
+	// The type is detected from the content; the client's Content-Type is ignored
+	checked, err := h.uploads.Check(file, header, workspace)
+	if err != nil {
+		var rejected *uploads.Error
+		if errors.As(err, &rejected) {
+			utils.RespondWithError(w, rejected.Status, rejected.Message)
+			return nil, false
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check file")
+		return nil, false
+	}
+	return checked, true
+}
+
+// checkQuota checks that media of a size and number can be added to a
+// workspace without exceeding its quotas, before its file is stored; the
+// quotas are enforced when the media is recorded. It responds with an error
+// and returns false when they would be exceeded.
+func (h *MediaHandler) checkQuota(w http.ResponseWriter, workspace models.Workspace, bytes, files int64) bool {
+	err := quota.Check(h.db, h.uploads.Quota(workspace), workspace.ID, bytes, files)
+	if errors.Is(err, quota.ErrExceeded) {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return false
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check storage quota")
+		return false
+	}
+	return true
+}
+
+// storeMedia stores a checked file unless the workspace holds an identical
+// one already, and creates its media record. It responds with an error and
+// returns false when this fails.
+func (h *MediaHandler) storeMedia(w http.ResponseWriter, workspace models.Workspace, checked *uploads.Checked, name string, private bool, userID uint) (models.Media, bool) {
+	original, filePath, ok := h.saveFile(w, workspace, checked, userID)
+	if !ok {
+		return models.Media{}, false
+	}
+	duplicate := original.ID != 0
+	originalName := checked.Header.Filename
+
 	// Create media record
 	media := models.Media{
-		WorkspaceID: uint(workspaceID),
-		Name:        r.FormValue("name"),
+		WorkspaceID: workspace.ID,
+		Name:        name,
 		FileName:    originalName,
 		FilePath:    filePath,
-		MimeType:    header.Header.Get("Content-Type"),
-		Size:        header.Size,
-		UploadedBy:  claims.UserID,
+		MimeType:    checked.MimeType,
+		Size:        checked.Header.Size,
+		Hash:        checked.Hash,
+		Width:       original.Width,
+		Height:      original.Height,
+		Private:     private,
+		UploadedBy:  userID,
+		// A duplicate looks the same as the media it duplicates
+		Orientation:   original.Orientation,
+		DominantColor: original.DominantColor,
+		BlurHash:      original.BlurHash,
+		Placeholder:   original.Placeholder,
 	}
 
 	// Use name from form or fallback to filename
@@ -88,17 +245,59 @@ func (h *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
 		media.Name = originalName
 	}
 
-	if err := h.db.Create(&media).Error; err != nil {
-		// Delete the file if database save fails
-		h.storage.Delete(filePath)
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create media record")
-		return
+	// The media only counts towards the quotas once it is recorded
+	err := db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&media).Error; err != nil {
+			return err
+		}
+		return quota.Reserve(tx, h.uploads.Quota(workspace), workspace.ID, userID, media.Size, 1)
+	})
+	if err != nil {
+		// Delete the file if database save fails, unless it is shared
+		if !duplicate {
+			h.storage.Delete(filePath)
+		}
+		if errors.Is(err, quota.ErrExceeded) {
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create media record")
+		}
+		return models.Media{}, false
 	}
 
-	// Add URL to response
-	media.FilePath = h.storage.GetURL(filePath)
+	// Record the dimensions, placeholders and variants of images; the upload stands if this fails.
+	// A duplicate shares the variants of the media it duplicates.
+	if duplicate {
+		if err := dedup.CopyVariants(h.db, original, &media); err != nil {
+			slog.Error("Failed to record shared image variants", "media_id", media.ID, "error", err)
+		}
+	} else if err := h.images.Generate(&media); err != nil {
+		slog.Error("Failed to generate image variants", "media_id", media.ID, "error", err)
+	}
 
-	utils.RespondWithSuccess(w, http.StatusCreated, media)
+	return media, true
+}
+
+// saveFile stores a checked file unless the workspace holds an identical one
+// already, and returns the media item it duplicates, if any, and its storage
+// path. It responds with an error and returns false when it fails.
+func (h *MediaHandler) saveFile(w http.ResponseWriter, workspace models.Workspace, checked *uploads.Checked, userID uint) (models.Media, string, bool) {
+	// Identical files are stored once per workspace and shared by their media
+	original, err := dedup.Find(h.db, h.storage, workspace.ID, checked.Hash)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check for duplicate media")
+		return models.Media{}, "", false
+	}
+	if original.ID != 0 {
+		return original, original.FilePath, true
+	}
+
+	_, filePath, err := h.storage.Save(checked.File, checked.Header, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save file: "+err.Error())
+		return models.Media{}, "", false
+	}
+	return original, filePath, true
 }
 
 // GetMedia handles getting a single media item
@@ -110,17 +309,137 @@ func (h *MediaHandler) GetMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var media models.Media
-	if err := h.db.First(&media, id).Error; err != nil {
+	if err := h.db.Preload("Variants").First(&media, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	visible, err := h.canViewMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !visible {
 		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
 		return
 	}
 
-	// Add URL to response
-	media.FilePath = h.storage.GetURL(media.FilePath)
+	// Add URLs to response
+	h.withURLs(&media)
 
 	utils.RespondWithSuccess(w, http.StatusOK, media)
 }
 
+// likeEscaper escapes the wildcards of a LIKE pattern, using ! as the escape character
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// containsPattern returns a LIKE pattern matching values that contain s
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// maxTagLength is the length limit of media tags
+const maxTagLength = 64
+
+// normalizeTags lowercases tags, collapses their whitespace and drops
+// duplicates, returning them sorted
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// filterMedia narrows a media query of a workspace by the folder_id, subfolders,
+// tag, type, uploaded_by and q query parameters. It responds with an error and
+// returns false when they are invalid.
+func (h *MediaHandler) filterMedia(w http.ResponseWriter, query *gorm.DB, workspaceID uint, params url.Values) (*gorm.DB, bool) {
+	switch folderID := params.Get("folder_id"); folderID {
+	case "":
+	case "root":
+		query = query.Where("folder_id IS NULL")
+	default:
+		var folder models.MediaFolder
+		if err := h.db.Where("id = ? AND workspace_id = ?", utils.ParseUint(folderID), workspaceID).First(&folder).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Folder not found in this workspace")
+			return nil, false
+		}
+		if params.Get("subfolders") == "true" {
+			ids, err := h.folderDescendants(folder)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch subfolders")
+				return nil, false
+			}
+			query = query.Where("folder_id IN ?", ids)
+		} else {
+			query = query.Where("folder_id = ?", folder.ID)
+		}
+	}
+
+	// Tags are stored as a JSON array, so each is matched with its quotes
+	if tags := params.Get("tag"); tags != "" {
+		normalized, err := normalizeTags(strings.Split(tags, ","))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid tag: "+err.Error())
+			return nil, false
+		}
+		for _, tag := range normalized {
+			encoded, _ := json.Marshal(tag)
+			query = query.Where("tags LIKE ? ESCAPE '!'", containsPattern(string(encoded)))
+		}
+	}
+
+	// A type is a family such as image or video, or a full MIME type
+	if mimeType := strings.ToLower(params.Get("type")); mimeType != "" {
+		if strings.Contains(mimeType, "/") {
+			query = query.Where("mime_type = ?", mimeType)
+		} else {
+			query = query.Where("mime_type LIKE ? ESCAPE '!'", likeEscaper.Replace(mimeType)+"/%")
+		}
+	}
+
+	switch params.Get("visibility") {
+	case "":
+	case "public":
+		query = query.Where("private = ?", false)
+	case "private":
+		query = query.Where("private = ?", true)
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Visibility must be public or private")
+		return nil, false
+	}
+
+	if uploadedBy := params.Get("uploaded_by"); uploadedBy != "" {
+		query = query.Where("uploaded_by = ?", utils.ParseUint(uploadedBy))
+	}
+
+	if search := strings.ToLower(strings.TrimSpace(params.Get("q"))); search != "" {
+		pattern := containsPattern(search)
+		query = query.Where("LOWER(name) LIKE ? ESCAPE '!' OR LOWER(file_name) LIKE ? ESCAPE '!' OR LOWER(alt_text) LIKE ? ESCAPE '!' OR LOWER(caption) LIKE ? ESCAPE '!'",
+			pattern, pattern, pattern, pattern)
+	}
+
+	return query, true
+}
+
 // ListMedia handles listing media items
 func (h *MediaHandler) ListMedia(w http.ResponseWriter, r *http.Request) {
 	workspaceID := r.URL.Query().Get("workspace_id")
@@ -151,20 +470,41 @@ func (h *MediaHandler) ListMedia(w http.ResponseWriter, r *http.Request) {
 	var total int64
 
 	query := h.db.Model(&models.Media{}).Where("workspace_id = ?", workspaceID)
+	query, ok := h.filterMedia(w, query, utils.ParseUint(workspaceID), r.URL.Query())
+	if !ok {
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	// Private media is only listed to members of its workspace
+	member, err := hasWorkspaceAccess(h.db, claims, utils.ParseUint(workspaceID))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !member {
+		query = query.Where("private = ?", false)
+	}
 
 	if err := query.Count(&total).Error; err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to count media")
 		return
 	}
 
-	if err := query.Limit(limit).Offset(offset).Order("created_at desc").Find(&media).Error; err != nil {
+	if err := query.Preload("Variants").Limit(limit).Offset(offset).Order("created_at desc").Find(&media).Error; err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media")
 		return
 	}
 
 	// Add URLs to response
 	for i := range media {
-		media[i].FilePath = h.storage.GetURL(media[i].FilePath)
+		h.withURLs(&media[i])
 	}
 
 	utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
@@ -175,6 +515,248 @@ func (h *MediaHandler) ListMedia(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UpdateMediaRequest represents a request to update the details of a media item
+type UpdateMediaRequest struct {
+	Name string `json:"name"`
+	// The following replace the current values when given
+	AltText   *string   `json:"alt_text"`
+	Caption   *string   `json:"caption"`
+	Credit    *string   `json:"credit"`
+	Copyright *string   `json:"copyright"`
+	Tags      *[]string `json:"tags"`
+	// Private media is only served through signed URLs that expire
+	Private *bool `json:"private"`
+}
+
+// MoveMediaRequest represents a request to move media items into a folder,
+// or to the root when folder_id is null
+type MoveMediaRequest struct {
+	MediaIDs []uint `json:"media_ids"`
+	FolderID *uint  `json:"folder_id"`
+}
+
+// MoveMediaResponse reports how many media items were moved
+type MoveMediaResponse struct {
+	Message string `json:"message"`
+	Moved   int    `json:"moved"`
+}
+
+// MediaTagCount is a tag and the number of media items of a workspace with it
+type MediaTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// canEditMedia reports whether the user in claims may change a media item:
+// editors and admins of its workspace, and the user who uploaded it
+func (h *MediaHandler) canEditMedia(claims *auth.Claims, media models.Media) (bool, error) {
+	if claims.UserID == media.UploadedBy {
+		return true, nil
+	}
+	if claims.RoleName != "admin" && claims.RoleName != "editor" {
+		return false, nil
+	}
+	return hasWorkspaceAccess(h.db, claims, media.WorkspaceID)
+}
+
+// UpdateMedia handles updating the name, descriptive details, tags and visibility of a media item
+func (h *MediaHandler) UpdateMedia(w http.ResponseWriter, r *http.Request) {
+	var media models.Media
+	if err := h.db.First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := h.canEditMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	var req UpdateMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		media.Name = name
+	}
+	for _, field := range []struct {
+		value  *string
+		target *string
+	}{
+		{req.AltText, &media.AltText},
+		{req.Caption, &media.Caption},
+		{req.Credit, &media.Credit},
+		{req.Copyright, &media.Copyright},
+	} {
+		if field.value != nil {
+			*field.target = strings.TrimSpace(*field.value)
+		}
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid tag: "+err.Error())
+			return
+		}
+		media.Tags = tags
+	}
+	if req.Private != nil {
+		if *req.Private && !h.checkPrivate(w) {
+			return
+		}
+		media.Private = *req.Private
+	}
+
+	if err := h.db.Model(&media).Select("name", "alt_text", "caption", "credit", "copyright", "tags", "private").Updates(&media).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update media")
+		return
+	}
+
+	h.cache.Invalidate(cache.Media(media.ID))
+
+	if err := h.db.Preload("Variants").First(&media, media.ID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media")
+		return
+	}
+	h.withURLs(&media)
+
+	utils.RespondWithSuccess(w, http.StatusOK, media)
+}
+
+// MoveMedia handles moving media items of one workspace into a folder
+func (h *MediaHandler) MoveMedia(w http.ResponseWriter, r *http.Request) {
+	var req MoveMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.MediaIDs) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Media IDs are required")
+		return
+	}
+
+	var media []models.Media
+	if err := h.db.Select("id", "workspace_id", "uploaded_by").Where("id IN ?", req.MediaIDs).Find(&media).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media")
+		return
+	}
+	if len(media) == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	workspaceID := media[0].WorkspaceID
+	for _, m := range media {
+		if m.WorkspaceID != workspaceID {
+			utils.RespondWithError(w, http.StatusBadRequest, "Media items must belong to one workspace")
+			return
+		}
+		allowed, err := h.canEditMedia(claims, m)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+			return
+		}
+		if !allowed {
+			utils.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("Permission denied for media %d", m.ID))
+			return
+		}
+	}
+
+	if req.FolderID != nil {
+		var folder models.MediaFolder
+		if err := h.db.Where("id = ? AND workspace_id = ?", *req.FolderID, workspaceID).First(&folder).Error; err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Folder not found in this workspace")
+			return
+		}
+	}
+
+	ids := make([]uint, 0, len(media))
+	for _, m := range media {
+		ids = append(ids, m.ID)
+	}
+	if err := h.db.Model(&models.Media{}).Where("id IN ?", ids).Update("folder_id", req.FolderID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to move media")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, MoveMediaResponse{
+		Message: "Media moved successfully",
+		Moved:   len(ids),
+	})
+}
+
+// ListMediaTags handles listing the tags used by media of a workspace, most used first
+func (h *MediaHandler) ListMediaTags(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(r.URL.Query().Get("workspace_id"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	// Tags of private media are only counted for members of its workspace
+	query := h.db.Select("tags").Where("workspace_id = ?", workspaceID)
+	member, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !member {
+		query = query.Where("private = ?", false)
+	}
+
+	var media []models.Media
+	if err := query.Find(&media).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media tags")
+		return
+	}
+
+	counts := map[string]int{}
+	for _, m := range media {
+		for _, tag := range m.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]MediaTagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, MediaTagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	utils.RespondWithSuccess(w, http.StatusOK, tags)
+}
+
 // DeleteMedia handles media deletion
 func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
@@ -203,17 +785,284 @@ func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete file
-	if err := h.storage.Delete(media.FilePath); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete file: "+err.Error())
+	// Media in use is only deleted when forced
+	usages, err := usage.Usages(h.db, media.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check media usage")
+		return
+	}
+	if len(usages) > 0 && r.URL.Query().Get("force") != "true" {
+		utils.RespondWithError(w, http.StatusConflict,
+			fmt.Sprintf("Media is used by %d content items; pass force=true to delete it anyway", usage.ContentCount(usages)))
+		return
+	}
+
+	// Links left pointing at the media are reported, not blocked, if its file goes
+	references, err := links.MediaReferences(h.db, media)
+	if err != nil {
+		logReferenceError("media", media.ID, err)
+	}
+	var variants []models.MediaVariant
+	var versions []models.MediaVersion
+	if err := h.db.Where("media_id = ?", media.ID).Find(&variants).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch image variants")
 		return
 	}
 
-	// Delete media record
-	if err := h.db.Delete(&media).Error; err != nil {
+	// The records go first; a file shared with duplicates is only deleted
+	// with the last of them, once no upload can share it any more
+	var shared int64
+	err = db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", media.ID).Find(&versions).Error; err != nil {
+			return err
+		}
+		count, err := dedup.Shared(tx, media)
+		if err != nil {
+			return err
+		}
+		shared = count
+
+		if err := tx.Delete(&media).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("media_id = ?", media.ID).Delete(&models.MediaVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("media_id = ?", media.ID).Delete(&models.MediaUsage{}).Error; err != nil {
+			return err
+		}
+
+		bytes := media.Size
+		for _, version := range versions {
+			bytes += version.Size
+		}
+		return quota.Add(tx, media.WorkspaceID, media.UploadedBy, -bytes, -1)
+	})
+	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete media record")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Media deleted successfully"})
-}
\ No newline at end of file
+	if !h.deleteUnshared(media, variants, versions, shared) {
+		references = nil
+	}
+
+	h.cache.Invalidate(cache.Media(media.ID))
+
+	utils.RespondWithSuccess(w, http.StatusOK, deleteResponse("Media deleted successfully", "media", references))
+}
+
+// deleteUnshared deletes the files of a deleted media item that no other
+// media item or version uses, counting again since a duplicate may have been
+// recorded meanwhile. It reports whether the file of the media item was deleted.
+func (h *MediaHandler) deleteUnshared(media models.Media, variants []models.MediaVariant, versions []models.MediaVersion, shared int64) bool {
+	if shared == 0 {
+		var err error
+		if shared, err = dedup.Shared(h.db.DB, media); err != nil {
+			slog.Error("Failed to check for shared media files", "media_id", media.ID, "error", err)
+			shared = 1
+		}
+	}
+	if shared == 0 {
+		if err := h.storage.Delete(media.FilePath); err != nil {
+			slog.Warn("Failed to delete media file", "media_id", media.ID, "path", media.FilePath, "error", err)
+		}
+		h.images.RemoveFiles(media, variants)
+	}
+
+	for _, version := range versions {
+		count, err := dedup.VersionShared(h.db, version)
+		if err != nil {
+			slog.Error("Failed to check for shared media files", "media_id", media.ID, "version_id", version.ID, "error", err)
+			continue
+		}
+		if count == 0 {
+			if err := h.storage.Delete(version.FilePath); err != nil {
+				slog.Warn("Failed to delete media version file", "media_id", media.ID, "path", version.FilePath, "error", err)
+			}
+		}
+	}
+	return shared == 0
+}
+
+// TransformURLResponse holds a signed image transform URL
+type TransformURLResponse struct {
+	URL string `json:"url"`
+}
+
+// GetTransformURL handles signing an on-the-fly transform of an image, given
+// as the w, h, fit and fmt query parameters
+func (h *MediaHandler) GetTransformURL(w http.ResponseWriter, r *http.Request) {
+	var media models.Media
+	if err := h.db.First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	visible, err := h.canViewMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+	if images.FormatOf(media.MimeType) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Media is not a transformable image")
+		return
+	}
+
+	opts, err := h.images.ParseOptions(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opts == (images.Options{}) {
+		utils.RespondWithError(w, http.StatusBadRequest, "At least one of w, h, fit or fmt is required")
+		return
+	}
+
+	// Transforms of private media also need the signature of its file
+	transformURL := h.images.URL(media.FilePath, opts)
+	if media.Private {
+		transformURL += "&" + h.storage.Signer().Sign(media.FilePath).Encode()
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, TransformURLResponse{URL: transformURL})
+}
+
+// GetMediaUsages handles listing the content items using a media item
+func (h *MediaHandler) GetMediaUsages(w http.ResponseWriter, r *http.Request) {
+	var media models.Media
+	if err := h.db.First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	visible, err := h.canViewMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Usages name content of the workspace, so only its members see them
+	member, err := hasWorkspaceAccess(h.db, claims, media.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !member {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	usages, err := usage.Usages(h.db, media.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media usage")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, usages)
+}
+
+// ReplaceMediaRequest represents a request to repoint every usage of a media item
+type ReplaceMediaRequest struct {
+	MediaID uint `json:"media_id"`
+}
+
+// ReplaceMediaResponse lists the content items a replacement changed
+type ReplaceMediaResponse struct {
+	Message    string `json:"message"`
+	ContentIDs []uint `json:"content_ids"`
+}
+
+// ReplaceMedia handles repointing every usage of a media item at another one
+func (h *MediaHandler) ReplaceMedia(w http.ResponseWriter, r *http.Request) {
+	var media models.Media
+	if err := h.db.First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	var req ReplaceMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.MediaID == 0 || req.MediaID == media.ID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Another media ID is required")
+		return
+	}
+
+	var replacement models.Media
+	if err := h.db.First(&replacement, req.MediaID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Replacement media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	// Replacing edits content of other authors
+	if claims.RoleName != "admin" && claims.RoleName != "editor" {
+		utils.RespondWithError(w, http.StatusForbidden, "Editor or admin access required")
+		return
+	}
+	allowed, err := hasWorkspaceAccess(h.db, claims, media.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "Permission denied")
+		return
+	}
+
+	changed, err := usage.Replace(h.db, media, replacement)
+	if err != nil {
+		if errors.Is(err, usage.ErrOtherWorkspace) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to replace media")
+		return
+	}
+
+	ids := make([]uint, 0, len(changed))
+	tags := []string{cache.Media(media.ID), cache.Listing(media.WorkspaceID)}
+	for _, content := range changed {
+		ids = append(ids, content.ID)
+		tags = append(tags, cache.Content(content.ID))
+	}
+	h.cache.Invalidate(tags...)
+
+	utils.RespondWithSuccess(w, http.StatusOK, ReplaceMediaResponse{
+		Message:    "Media replaced successfully",
+		ContentIDs: ids,
+	})
+}
@@ -0,0 +1,177 @@
+// internal/handlers/media_handler_test.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// storeMediaFile stores a file and records a media item of it, counting it
+// towards the workspace usage
+func storeMediaFile(t *testing.T, database *db.DB, store storage.Manager, filePath string) models.Media {
+	t.Helper()
+	if err := store.Put(filePath, strings.NewReader(filePath), int64(len(filePath)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	media := models.Media{WorkspaceID: 1, Name: filePath, FileName: "notes.txt", FilePath: filePath, MimeType: "text/plain", Size: int64(len(filePath)), UploadedBy: admin.UserID}
+	if err := database.Create(&media).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := quota.Add(database.DB, 1, admin.UserID, media.Size, 1); err != nil {
+		t.Fatal(err)
+	}
+	return media
+}
+
+// stored reports whether a file is in storage
+func stored(t *testing.T, store storage.Manager, filePath string) bool {
+	t.Helper()
+	r, err := store.Open(filePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	return true
+}
+
+func TestDeleteMedia(t *testing.T) {
+	const file = "2024/01/01/notes.txt"
+
+	tests := []struct {
+		name string
+		// setup records what shares the file of the media deleted
+		setup func(t *testing.T, database *db.DB, store storage.Manager, media models.Media)
+		// kept is whether the file of the media stays stored
+		kept bool
+	}{
+		{
+			name:  "unshared",
+			setup: func(t *testing.T, database *db.DB, store storage.Manager, media models.Media) {},
+		},
+		{
+			name: "shared with a duplicate",
+			setup: func(t *testing.T, database *db.DB, store storage.Manager, media models.Media) {
+				duplicate := media
+				duplicate.ID = 0
+				if err := database.Create(&duplicate).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			kept: true,
+		},
+		{
+			name: "shared with a version of other media",
+			setup: func(t *testing.T, database *db.DB, store storage.Manager, media models.Media) {
+				other := storeMediaFile(t, database, store, "2024/01/01/other.txt")
+				version := models.MediaVersion{MediaID: other.ID, FilePath: file, Size: media.Size}
+				if err := database.Create(&version).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+			kept: true,
+		},
+		{
+			name: "shared with a deleted duplicate",
+			setup: func(t *testing.T, database *db.DB, store storage.Manager, media models.Media) {
+				duplicate := media
+				duplicate.ID = 0
+				if err := database.Create(&duplicate).Error; err != nil {
+					t.Fatal(err)
+				}
+				if err := database.Delete(&duplicate).Error; err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, database, store := newMediaHandler(t, testUploads)
+			media := storeMediaFile(t, database, store, file)
+
+			// A previous file of the media goes with it
+			if err := store.Put("2024/01/01/old.txt", strings.NewReader("old"), 3, "text/plain"); err != nil {
+				t.Fatal(err)
+			}
+			version := models.MediaVersion{MediaID: media.ID, FilePath: "2024/01/01/old.txt", Size: 3}
+			if err := database.Create(&version).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := quota.Add(database.DB, 1, admin.UserID, 3, 0); err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(t, database, store, media)
+			before, err := quota.Workspace(database.DB, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _ := serve(t, h.DeleteMedia, http.MethodDelete, "/media/{id}", fmt.Sprintf("/media/%d", media.ID), nil, admin)
+			if code != http.StatusOK {
+				t.Fatalf("DELETE = %d, want 200", code)
+			}
+
+			if err := database.First(&models.Media{}, media.ID).Error; err == nil {
+				t.Error("media record kept")
+			}
+			var versions int64
+			database.Model(&models.MediaVersion{}).Where("media_id = ?", media.ID).Count(&versions)
+			if versions != 0 {
+				t.Errorf("%d versions kept", versions)
+			}
+			if got := stored(t, store, file); got != tt.kept {
+				t.Errorf("file stored = %v, want %v", got, tt.kept)
+			}
+			if stored(t, store, version.FilePath) {
+				t.Error("version file kept")
+			}
+
+			after, err := quota.Workspace(database.DB, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Bytes != before.Bytes-media.Size-3 || after.Files != before.Files-1 {
+				t.Errorf("usage = %d bytes in %d files, want %d in %d",
+					after.Bytes, after.Files, before.Bytes-media.Size-3, before.Files-1)
+			}
+		})
+	}
+}
+
+// A duplicate recorded after the deletion counted the sharers of a file
+// keeps the file
+func TestDeleteUnsharedRecounts(t *testing.T) {
+	h, database, store := newMediaHandler(t, testUploads)
+	media := storeMediaFile(t, database, store, "2024/01/01/notes.txt")
+	if err := database.Delete(&media).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	duplicate := media
+	duplicate.ID = 0
+	duplicate.DeletedAt = gorm.DeletedAt{}
+	if err := database.Create(&duplicate).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if h.deleteUnshared(media, nil, nil, 0) {
+		t.Error("deleteUnshared deleted the file")
+	}
+	if !stored(t, store, media.FilePath) {
+		t.Error("file shared with the duplicate was deleted")
+	}
+}
@@ -0,0 +1,359 @@
+// internal/handlers/media_upload_handler.go
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/uploads"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// tusVersion is the version of the tus resumable upload protocol served
+const tusVersion = "1.0.0"
+
+// tusExtensions are the tus protocol extensions supported
+const tusExtensions = "creation,termination,expiration"
+
+// mediaIDHeader names the media created by a completed resumable upload
+const mediaIDHeader = "Floe-Media-ID"
+
+// parseUploadMetadata parses an Upload-Metadata header: comma-separated keys,
+// each followed by a space and its base64 value unless it has none
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// tusHeaders sets the headers every resumable upload response carries. It
+// answers requests of other protocol versions and returns false.
+func tusHeaders(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Method != http.MethodOptions && r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		utils.RespondWithError(w, http.StatusPreconditionFailed, "Tus-Resumable "+tusVersion+" is required")
+		return false
+	}
+	return true
+}
+
+// uploadHeaders sets the headers describing the state of a resumable upload
+func uploadHeaders(w http.ResponseWriter, upload models.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Received, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-store")
+	if upload.MediaID != nil {
+		w.Header().Set(mediaIDHeader, strconv.FormatUint(uint64(*upload.MediaID), 10))
+	}
+}
+
+// ownUpload returns the unexpired resumable upload of the URL, responding and
+// returning false when it does not exist or belongs to someone else
+func (h *MediaHandler) ownUpload(w http.ResponseWriter, r *http.Request) (models.Upload, bool) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return models.Upload{}, false
+	}
+
+	var upload models.Upload
+	if err := h.db.First(&upload, chi.URLParam(r, "id")).Error; err != nil ||
+		upload.UserID != claims.UserID || upload.ExpiresAt.Before(time.Now()) {
+		utils.RespondWithError(w, http.StatusNotFound, "Upload not found")
+		return models.Upload{}, false
+	}
+	return upload, true
+}
+
+// UploadOptions handles discovery of the resumable upload protocol
+func (h *MediaHandler) UploadOptions(w http.ResponseWriter, r *http.Request) {
+	tusHeaders(w, r)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.uploads.MaxFileSize(), 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUpload handles starting a resumable upload. The workspace_id, the
+// filename and optionally a name are passed as Upload-Metadata.
+func (h *MediaHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	if !tusHeaders(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Upload-Length is required")
+		return
+	}
+	if length == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "File is empty")
+		return
+	}
+	if length > h.uploads.MaxFileSize() {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Upload is too large: no file type allows more than %d MiB", h.uploads.MaxFileSize()>>20))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid Upload-Metadata: "+err.Error())
+		return
+	}
+	workspaceID, err := strconv.ParseUint(metadata["workspace_id"], 10, 32)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+	if metadata["filename"] == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "File name is required")
+		return
+	}
+	private, err := parseFlag(metadata["private"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid private flag")
+		return
+	}
+	if private && !h.checkPrivate(w) {
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, workspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+	if !h.checkQuota(w, workspace, length, 1) {
+		return
+	}
+
+	upload := models.Upload{
+		WorkspaceID: workspace.ID,
+		UserID:      claims.UserID,
+		Name:        metadata["name"],
+		FileName:    metadata["filename"],
+		Private:     private,
+		Length:      length,
+		ExpiresAt:   h.partials.Expires(),
+	}
+	if err := h.db.Create(&upload).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/media/uploads/%d", upload.ID))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetUploadOffset handles asking how much of a resumable upload was received
+func (h *MediaHandler) GetUploadOffset(w http.ResponseWriter, r *http.Request) {
+	if !tusHeaders(w, r) {
+		return
+	}
+	upload, ok := h.ownUpload(w, r)
+	if !ok {
+		return
+	}
+
+	uploadHeaders(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchUpload handles receiving a chunk of a resumable upload at the offset
+// it was received up to. The chunk completing the upload creates its media,
+// whose ID is returned in the Floe-Media-ID header.
+func (h *MediaHandler) PatchUpload(w http.ResponseWriter, r *http.Request) {
+	if !tusHeaders(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Upload-Offset is required")
+		return
+	}
+
+	upload, ok := h.ownUpload(w, r)
+	if !ok {
+		return
+	}
+	if offset != upload.Received {
+		uploadHeaders(w, upload)
+		utils.RespondWithError(w, http.StatusConflict,
+			fmt.Sprintf("Upload-Offset %d does not match the %d bytes received", offset, upload.Received))
+		return
+	}
+
+	if upload.Received < upload.Length {
+		// Whatever arrived is kept, so that the client can resume after it
+		body := http.MaxBytesReader(w, r.Body, upload.Length-upload.Received)
+		chunk, readErr := uploads.Receive(body)
+		if chunk == nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to receive chunk")
+			return
+		}
+		defer chunk.Close()
+
+		// The chunk is kept only if no other request wrote at the offset meanwhile
+		if err := h.partials.Append(h.db, &upload, chunk); err != nil {
+			if errors.Is(err, uploads.ErrClaimed) {
+				utils.RespondWithError(w, http.StatusConflict, "Upload was written by another request")
+				return
+			}
+			slog.Error("Failed to store chunk", "upload_id", upload.ID, "error", err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to record upload progress")
+			return
+		}
+
+		if readErr != nil {
+			uploadHeaders(w, upload)
+			var tooLarge *http.MaxBytesError
+			if errors.As(readErr, &tooLarge) {
+				utils.RespondWithError(w, http.StatusRequestEntityTooLarge, "Chunk goes past the Upload-Length")
+				return
+			}
+			slog.Warn("Resumable upload interrupted", "upload_id", upload.ID, "received", upload.Received, "error", readErr)
+			utils.RespondWithError(w, http.StatusBadRequest, "Failed to receive chunk")
+			return
+		}
+	}
+
+	// A completed upload is stored once; a failed attempt is retried by
+	// sending an empty chunk at the end
+	if upload.Received == upload.Length && upload.MediaID == nil {
+		if err := h.partials.Complete(h.db, &upload); err != nil {
+			if errors.Is(err, uploads.ErrClaimed) {
+				utils.RespondWithError(w, http.StatusConflict, "Upload is being completed by another request")
+				return
+			}
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to complete upload")
+			return
+		}
+		if !h.completeUpload(w, &upload) {
+			return
+		}
+	}
+
+	uploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeUpload checks a fully received upload, claimed for completion,
+// and creates its media like UploadMedia does. An upload failing the checks
+// is deleted, since sending it again would not change the outcome; one
+// failing otherwise is released to be retried.
+func (h *MediaHandler) completeUpload(w http.ResponseWriter, upload *models.Upload) bool {
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, upload.WorkspaceID).Error; err != nil {
+		h.removeUpload(*upload)
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return false
+	}
+
+	file, err := h.partials.Open(h.db, *upload)
+	if err != nil {
+		slog.Error("Failed to assemble upload", "upload_id", upload.ID, "error", err)
+		h.releaseUpload(*upload)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to read upload")
+		return false
+	}
+	defer file.Close()
+
+	header := &multipart.FileHeader{
+		Filename: upload.FileName,
+		Size:     upload.Length,
+		Header:   textproto.MIMEHeader{},
+	}
+	checked, ok := h.checkUpload(w, workspace, file, header)
+	if !ok {
+		h.removeUpload(*upload)
+		return false
+	}
+	if !h.checkQuota(w, workspace, checked.Header.Size, 1) {
+		h.removeUpload(*upload)
+		return false
+	}
+	media, ok := h.storeMedia(w, workspace, checked, upload.Name, upload.Private, upload.UserID)
+	if !ok {
+		h.releaseUpload(*upload)
+		return false
+	}
+
+	if err := h.partials.Completed(h.db, upload, media.ID); err != nil {
+		slog.Error("Failed to record media of upload", "upload_id", upload.ID, "media_id", media.ID, "error", err)
+	}
+	return true
+}
+
+// releaseUpload gives up completing a resumable upload, so that it can be retried
+func (h *MediaHandler) releaseUpload(upload models.Upload) {
+	if err := h.partials.Release(h.db, upload); err != nil {
+		slog.Error("Failed to release upload", "upload_id", upload.ID, "error", err)
+	}
+}
+
+// removeUpload deletes a resumable upload claimed for completion and its data
+func (h *MediaHandler) removeUpload(upload models.Upload) {
+	if err := h.partials.Remove(h.db, upload); err != nil {
+		slog.Error("Failed to delete upload", "upload_id", upload.ID, "error", err)
+	}
+}
+
+// DeleteUpload handles cancelling a resumable upload. Media it created is kept.
+func (h *MediaHandler) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	if !tusHeaders(w, r) {
+		return
+	}
+	upload, ok := h.ownUpload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.partials.Cancel(h.db, upload); err != nil {
+		if errors.Is(err, uploads.ErrClaimed) {
+			utils.RespondWithError(w, http.StatusConflict, "Upload is being completed by another request")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete upload")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,245 @@
+// internal/handlers/media_upload_handler_test.go
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// tus makes a resumable upload request with the given headers as the user
+// in claims
+func tus(t *testing.T, h *MediaHandler, method, target string, header http.Header, body io.Reader, claims *auth.Claims) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := chi.NewRouter()
+	router.Post("/uploads", h.CreateUpload)
+	router.Head("/uploads/{id}", h.GetUploadOffset)
+	router.Patch("/uploads/{id}", h.PatchUpload)
+	router.Delete("/uploads/{id}", h.DeleteUpload)
+
+	req := httptest.NewRequest(method, target, body)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, claims))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// at returns the headers of a chunk sent at an offset
+func at(offset int64) http.Header {
+	return http.Header{
+		"Upload-Offset": {strconv.FormatInt(offset, 10)},
+		"Content-Type":  {"application/offset+octet-stream"},
+	}
+}
+
+// interrupted is a request body that breaks off after its data
+type interrupted struct {
+	data io.Reader
+}
+
+func (r interrupted) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+// chunk is a request of a resumable upload
+type chunk struct {
+	offset int64
+	data   string
+	// broken breaks the request off after the data
+	broken bool
+	code   int
+	// received is the Upload-Offset returned
+	received int64
+}
+
+func TestResumableUpload(t *testing.T) {
+	const file = "hello world\n"
+
+	tests := []struct {
+		name   string
+		chunks []chunk
+		// created is whether the last chunk created media
+		created bool
+	}{
+		{
+			name:    "one request",
+			chunks:  []chunk{{offset: 0, data: file, code: http.StatusNoContent, received: 12}},
+			created: true,
+		},
+		{
+			name: "resumed",
+			chunks: []chunk{
+				{offset: 0, data: "hello ", code: http.StatusNoContent, received: 6},
+				{offset: 6, data: "world\n", code: http.StatusNoContent, received: 12},
+			},
+			created: true,
+		},
+		{
+			name: "resumed after a broken request",
+			chunks: []chunk{
+				{offset: 0, data: "hello ", broken: true, code: http.StatusBadRequest, received: 6},
+				{offset: 6, data: "world\n", code: http.StatusNoContent, received: 12},
+			},
+			created: true,
+		},
+		{
+			name: "offset already written",
+			chunks: []chunk{
+				{offset: 0, data: "hello ", code: http.StatusNoContent, received: 6},
+				{offset: 0, data: "HELLO ", code: http.StatusConflict, received: 6},
+				{offset: 6, data: "world\n", code: http.StatusNoContent, received: 12},
+			},
+			created: true,
+		},
+		{
+			name:   "offset not reached",
+			chunks: []chunk{{offset: 6, data: "world\n", code: http.StatusConflict, received: 0}},
+		},
+		{
+			name:   "past the length",
+			chunks: []chunk{{offset: 0, data: file + "more", code: http.StatusRequestEntityTooLarge, received: 12}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, database, store := newMediaHandler(t, testUploads)
+
+			metadata := "workspace_id " + base64.StdEncoding.EncodeToString([]byte("1")) +
+				",filename " + base64.StdEncoding.EncodeToString([]byte("notes.txt"))
+			rec := tus(t, h, http.MethodPost, "/uploads", http.Header{
+				"Upload-Length":   {strconv.Itoa(len(file))},
+				"Upload-Metadata": {metadata},
+			}, nil, editor)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST = %d %s, want 201", rec.Code, rec.Body.String())
+			}
+			target := strings.TrimPrefix(rec.Header().Get("Location"), "/api/media")
+
+			var last *httptest.ResponseRecorder
+			for _, c := range tt.chunks {
+				var body io.Reader = strings.NewReader(c.data)
+				if c.broken {
+					body = interrupted{body}
+				}
+				last = tus(t, h, http.MethodPatch, target, at(c.offset), body, editor)
+				if last.Code != c.code {
+					t.Fatalf("PATCH at %d = %d %s, want %d", c.offset, last.Code, last.Body.String(), c.code)
+				}
+
+				// The offset to resume from is kept
+				head := tus(t, h, http.MethodHead, target, nil, nil, editor)
+				if got := head.Header().Get("Upload-Offset"); got != strconv.FormatInt(c.received, 10) {
+					t.Errorf("Upload-Offset after PATCH at %d = %s, want %d", c.offset, got, c.received)
+				}
+			}
+
+			mediaID := last.Header().Get(mediaIDHeader)
+			if !tt.created {
+				if mediaID != "" {
+					t.Errorf("media %s created, want none", mediaID)
+				}
+				return
+			}
+
+			var media models.Media
+			if err := database.First(&media, mediaID).Error; err != nil {
+				t.Fatalf("media %q of the upload: %v", mediaID, err)
+			}
+			if got := readStored(t, store, media.FilePath); got != file {
+				t.Errorf("stored file = %q, want %q", got, file)
+			}
+			var chunks int64
+			database.Model(&models.UploadChunk{}).Count(&chunks)
+			if chunks != 0 {
+				t.Errorf("%d chunks kept after completion", chunks)
+			}
+
+			// Completed uploads only report their media
+			again := tus(t, h, http.MethodPatch, target, at(int64(len(file))), strings.NewReader(""), editor)
+			if again.Code != http.StatusNoContent || again.Header().Get(mediaIDHeader) != mediaID {
+				t.Errorf("PATCH of the completed upload = %d with media %q, want 204 with %s",
+					again.Code, again.Header().Get(mediaIDHeader), mediaID)
+			}
+			var count int64
+			database.Model(&models.Media{}).Count(&count)
+			if count != 1 {
+				t.Errorf("%d media created, want 1", count)
+			}
+		})
+	}
+}
+
+func TestDeleteUpload(t *testing.T) {
+	h, database, _ := newMediaHandler(t, testUploads)
+
+	upload := models.Upload{WorkspaceID: 1, UserID: editor.UserID, FileName: "notes.txt", Length: 12, ExpiresAt: h.partials.Expires()}
+	if err := database.Create(&upload).Error; err != nil {
+		t.Fatal(err)
+	}
+	target := "/uploads/" + strconv.FormatUint(uint64(upload.ID), 10)
+
+	if rec := tus(t, h, http.MethodPatch, target, at(0), strings.NewReader("hello "), editor); rec.Code != http.StatusNoContent {
+		t.Fatalf("PATCH = %d, want 204", rec.Code)
+	}
+	if rec := tus(t, h, http.MethodDelete, target, nil, nil, admin); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE by another user = %d, want 404", rec.Code)
+	}
+
+	// An upload being completed is not deleted under the request completing it
+	database.Model(&upload).Update("completing", true)
+	if rec := tus(t, h, http.MethodDelete, target, nil, nil, editor); rec.Code != http.StatusConflict {
+		t.Errorf("DELETE while completing = %d, want 409", rec.Code)
+	}
+	database.Model(&upload).Update("completing", false)
+
+	if rec := tus(t, h, http.MethodDelete, target, nil, nil, editor); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want 204", rec.Code)
+	}
+	var uploads, chunks int64
+	database.Model(&models.Upload{}).Count(&uploads)
+	database.Model(&models.UploadChunk{}).Count(&chunks)
+	if uploads != 0 || chunks != 0 {
+		t.Errorf("%d uploads and %d chunks kept, want none", uploads, chunks)
+	}
+	if rec := tus(t, h, http.MethodPatch, target, at(6), strings.NewReader("world\n"), editor); rec.Code != http.StatusNotFound {
+		t.Errorf("PATCH after DELETE = %d, want 404", rec.Code)
+	}
+}
+
+// readStored returns the contents of a stored file
+func readStored(t *testing.T, store storage.Manager, filePath string) string {
+	t.Helper()
+	r, err := store.Open(filePath)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", filePath, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,357 @@
+// internal/handlers/media_version_handler.go
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/dedup"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
+	"github.com/randilt/floe-cms/internal/usage"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// fileColumns are the columns of a media item describing its current file
+var fileColumns = []string{
+	"file_name", "file_path", "mime_type", "size", "hash", "width", "height",
+	"orientation", "dominant_color", "blur_hash", "placeholder",
+}
+
+// editableMedia returns the media item of the URL, with its variants, if the
+// user may change it
+func (h *MediaHandler) editableMedia(w http.ResponseWriter, r *http.Request) (*models.Media, *auth.Claims, bool) {
+	var media models.Media
+	if err := h.db.Preload("Variants").First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return nil, nil, false
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return nil, nil, false
+	}
+
+	allowed, err := h.canEditMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return nil, nil, false
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "Permission denied")
+		return nil, nil, false
+	}
+	return &media, claims, true
+}
+
+// versionOf returns a version keeping the current file of a media item
+func versionOf(media models.Media, userID uint) models.MediaVersion {
+	return models.MediaVersion{
+		MediaID:    media.ID,
+		FileName:   media.FileName,
+		FilePath:   media.FilePath,
+		MimeType:   media.MimeType,
+		Size:       media.Size,
+		Hash:       media.Hash,
+		Width:      media.Width,
+		Height:     media.Height,
+		ReplacedBy: userID,
+	}
+}
+
+// swapFile makes the file now described by media the current file of the
+// media item, which had the file of previous. The previous file is kept as a
+// version and its variants and transforms are dropped; a restored version
+// the new file comes from is deleted. The new file shares the variants of
+// original when it is the file of that other media item. Content using the
+// previous file and variants is repointed at the new ones. A new file that
+// was just saved for the swap is deleted if the swap fails to be recorded,
+// which it does with an error wrapping quota.ErrExceeded if the kept version
+// would take the workspace over its quotas.
+func (h *MediaHandler) swapFile(previous models.Media, media *models.Media, original models.Media, restored *models.MediaVersion, limits quota.Limits, userID uint) error {
+	version := versionOf(previous, userID)
+	// The kept version counts as much as the new file, and a restored
+	// version no longer counts on its own
+	added := media.Size
+	if restored != nil {
+		added -= restored.Size
+	}
+	err := db.ExecuteWithTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		if restored != nil {
+			if err := tx.Unscoped().Delete(restored).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(media).Select(fileColumns).Updates(media).Error; err != nil {
+			return err
+		}
+		return quota.Reserve(tx, limits, media.WorkspaceID, media.UploadedBy, added, 0)
+	})
+	if err != nil {
+		// A shared or restored file is still used by its media or version
+		if original.ID == 0 && restored == nil {
+			h.storage.Delete(media.FilePath)
+		}
+		return err
+	}
+
+	// Variants of a file shared with other media stay theirs
+	shared, err := dedup.Shared(h.db.DB, previous)
+	if err != nil {
+		return err
+	}
+	if shared > 0 {
+		err = h.images.Forget(media.ID)
+	} else {
+		err = h.images.Remove(previous)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The file stands if its variants fail, as with uploads
+	media.Variants = nil
+	if original.ID != 0 {
+		if err := dedup.CopyVariants(h.db, original, media); err != nil {
+			slog.Error("Failed to record shared image variants", "media_id", media.ID, "error", err)
+		}
+	} else if err := h.images.Generate(media); err != nil {
+		slog.Error("Failed to generate image variants", "media_id", media.ID, "error", err)
+	}
+
+	changed, err := usage.Rewrite(h.db, previous, previous.Variants, *media)
+	if err != nil {
+		return err
+	}
+
+	tags := []string{cache.Media(media.ID), cache.Listing(media.WorkspaceID)}
+	for _, content := range changed {
+		tags = append(tags, cache.Content(content.ID))
+	}
+	h.cache.Invalidate(tags...)
+	return nil
+}
+
+// ReplaceMediaFile handles replacing the file of a media item in place. The
+// media item keeps its ID, details and usages, which are repointed at the
+// new file; the previous file is kept as a version that can be restored.
+func (h *MediaHandler) ReplaceMediaFile(w http.ResponseWriter, r *http.Request) {
+	media, claims, ok := h.editableMedia(w, r)
+	if !ok {
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, media.WorkspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	if !h.parseUploadForm(w, r) {
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "No file provided")
+		return
+	}
+	defer file.Close()
+
+	checked, ok := h.checkUpload(w, workspace, file, header)
+	if !ok {
+		return
+	}
+	if checked.Hash != "" && checked.Hash == media.Hash {
+		utils.RespondWithError(w, http.StatusBadRequest, "File is identical to the current file")
+		return
+	}
+	// The current file is kept as a version, so the new one adds its whole size
+	if !h.checkQuota(w, workspace, checked.Header.Size, 0) {
+		return
+	}
+	original, filePath, ok := h.saveFile(w, workspace, checked, claims.UserID)
+	if !ok {
+		return
+	}
+
+	previous := *media
+	media.FileName = checked.Header.Filename
+	media.FilePath = filePath
+	media.MimeType = checked.MimeType
+	media.Size = checked.Header.Size
+	media.Hash = checked.Hash
+	// A duplicate looks the same as the media it duplicates
+	media.Width, media.Height = original.Width, original.Height
+	media.Orientation = original.Orientation
+	media.DominantColor = original.DominantColor
+	media.BlurHash = original.BlurHash
+	media.Placeholder = original.Placeholder
+
+	err = h.swapFile(previous, media, original, nil, h.uploads.Quota(workspace), claims.UserID)
+	if errors.Is(err, quota.ErrExceeded) {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to replace media file", "media_id", media.ID, "error", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to replace media file")
+		return
+	}
+
+	// Add URLs to response
+	h.withURLs(media)
+
+	utils.RespondWithSuccess(w, http.StatusOK, media)
+}
+
+// ListMediaVersions handles listing the previous files of a media item, newest first
+func (h *MediaHandler) ListMediaVersions(w http.ResponseWriter, r *http.Request) {
+	var media models.Media
+	if err := h.db.First(&media, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	// Get user from context
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	visible, err := h.canViewMedia(claims, media)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Media not found")
+		return
+	}
+
+	versions := []models.MediaVersion{}
+	if err := h.db.Where("media_id = ?", media.ID).Order("id desc").Find(&versions).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch media versions")
+		return
+	}
+	for i := range versions {
+		versions[i].FilePath = h.storage.GetURL(versions[i].FilePath, media.Private)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, versions)
+}
+
+// mediaVersion returns the version of the URL belonging to a media item
+func (h *MediaHandler) mediaVersion(w http.ResponseWriter, r *http.Request, media *models.Media) (*models.MediaVersion, bool) {
+	var version models.MediaVersion
+	if err := h.db.Where("id = ? AND media_id = ?", chi.URLParam(r, "versionId"), media.ID).First(&version).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media version not found")
+		return nil, false
+	}
+	return &version, true
+}
+
+// RestoreMediaVersion handles making a previous file of a media item its
+// current file again. The file it replaces is kept as a version in turn.
+func (h *MediaHandler) RestoreMediaVersion(w http.ResponseWriter, r *http.Request) {
+	media, claims, ok := h.editableMedia(w, r)
+	if !ok {
+		return
+	}
+	version, ok := h.mediaVersion(w, r, media)
+	if !ok {
+		return
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, media.WorkspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	// A file shared with another media item also shares its variants
+	var original models.Media
+	if err := h.db.Preload("Variants").Where("file_path = ? AND id <> ?", version.FilePath, media.ID).
+		Order("id asc").Limit(1).Find(&original).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check for shared media files")
+		return
+	}
+
+	previous := *media
+	media.FileName = version.FileName
+	media.FilePath = version.FilePath
+	media.MimeType = version.MimeType
+	media.Size = version.Size
+	media.Hash = version.Hash
+	media.Width, media.Height = version.Width, version.Height
+	media.Orientation = original.Orientation
+	media.DominantColor = original.DominantColor
+	media.BlurHash = original.BlurHash
+	media.Placeholder = original.Placeholder
+
+	// Restoring swaps a version for the current file, so it leaves the usage as it is
+	if err := h.swapFile(previous, media, original, version, h.uploads.Quota(workspace), claims.UserID); err != nil {
+		slog.Error("Failed to restore media version", "media_id", media.ID, "version_id", version.ID, "error", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to restore media version")
+		return
+	}
+
+	// Add URLs to response
+	h.withURLs(media)
+
+	utils.RespondWithSuccess(w, http.StatusOK, media)
+}
+
+// deleteVersion deletes a version of a media item, and its file unless other
+// media or versions use it
+func (h *MediaHandler) deleteVersion(media models.Media, version models.MediaVersion) error {
+	shared, err := dedup.VersionShared(h.db, version)
+	if err != nil {
+		return err
+	}
+	if shared == 0 {
+		if err := h.storage.Delete(version.FilePath); err != nil {
+			return err
+		}
+	}
+	if err := h.db.Unscoped().Delete(&version).Error; err != nil {
+		return err
+	}
+
+	if err := quota.Add(h.db.DB, media.WorkspaceID, media.UploadedBy, -version.Size, 0); err != nil {
+		slog.Error("Failed to record storage usage", "media_id", media.ID, "version_id", version.ID, "error", err)
+	}
+	return nil
+}
+
+// DeleteMediaVersion handles deleting a previous file of a media item
+func (h *MediaHandler) DeleteMediaVersion(w http.ResponseWriter, r *http.Request) {
+	media, _, ok := h.editableMedia(w, r)
+	if !ok {
+		return
+	}
+	version, ok := h.mediaVersion(w, r, media)
+	if !ok {
+		return
+	}
+
+	if err := h.deleteVersion(*media, *version); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete media version: "+err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Media version deleted successfully"})
+}
@@ -0,0 +1,129 @@
+// internal/handlers/quota_handler.go
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// QuotaHandler handles storage usage and quota requests
+type QuotaHandler struct {
+	db  *db.DB
+	cfg config.UploadsConfig
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(db *db.DB, cfg config.UploadsConfig) *QuotaHandler {
+	return &QuotaHandler{
+		db:  db,
+		cfg: cfg,
+	}
+}
+
+// WorkspaceStorage reports the media usage of a workspace against its quotas
+type WorkspaceStorage struct {
+	WorkspaceID uint         `json:"workspace_id"`
+	Name        string       `json:"name"`
+	Slug        string       `json:"slug"`
+	Usage       quota.Usage  `json:"usage"`
+	Quota       quota.Limits `json:"quota"`
+	// Uploaders is only reported for a single workspace
+	Uploaders []UploaderStorage `json:"uploaders,omitempty"`
+}
+
+// UploaderStorage reports the media usage of a user in a workspace
+type UploaderStorage struct {
+	UserID      uint        `json:"user_id"`
+	Email       string      `json:"email"`
+	DisplayName string      `json:"display_name"`
+	Usage       quota.Usage `json:"usage"`
+}
+
+// ListStorageUsage handles reporting the media usage of every workspace, largest first
+func (h *QuotaHandler) ListStorageUsage(w http.ResponseWriter, r *http.Request) {
+	var workspaces []models.Workspace
+	if err := h.db.Find(&workspaces).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch workspaces")
+		return
+	}
+
+	usages, err := quota.Workspaces(h.db)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch storage usage")
+		return
+	}
+
+	report := make([]WorkspaceStorage, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		report = append(report, WorkspaceStorage{
+			WorkspaceID: workspace.ID,
+			Name:        workspace.Name,
+			Slug:        workspace.Slug,
+			Usage:       usages[workspace.ID],
+			Quota:       quota.Of(h.cfg, workspace),
+		})
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		return report[i].Usage.Bytes > report[j].Usage.Bytes
+	})
+
+	utils.RespondWithSuccess(w, http.StatusOK, report)
+}
+
+// GetStorageUsage handles reporting the media usage of a workspace and of
+// each user who uploaded to it
+func (h *QuotaHandler) GetStorageUsage(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	rows, err := quota.Uploaders(h.db, workspace.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch storage usage")
+		return
+	}
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.UserID)
+	}
+	var users []models.User
+	if err := h.db.Unscoped().Where("id IN ?", ids).Find(&users).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	report := WorkspaceStorage{
+		WorkspaceID: workspace.ID,
+		Name:        workspace.Name,
+		Slug:        workspace.Slug,
+		Quota:       quota.Of(h.cfg, workspace),
+		Uploaders:   make([]UploaderStorage, 0, len(rows)),
+	}
+	for _, row := range rows {
+		report.Usage.Bytes += row.Bytes
+		report.Usage.Files += row.Files
+		report.Uploaders = append(report.Uploaders, UploaderStorage{
+			UserID:      row.UserID,
+			Email:       byID[row.UserID].Email,
+			DisplayName: byID[row.UserID].DisplayName,
+			Usage:       quota.Usage{Bytes: row.Bytes, Files: row.Files},
+		})
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, report)
+}
@@ -0,0 +1,557 @@
+// internal/handlers/release_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/related"
+	"github.com/randilt/floe-cms/internal/releases"
+	"github.com/randilt/floe-cms/internal/seo"
+	"github.com/randilt/floe-cms/internal/usage"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// ReleaseHandler handles release bundle requests
+type ReleaseHandler struct {
+	db      *db.DB
+	public  *delivery.Serializer
+	related *related.Refresher
+	cache   *cache.Cache
+}
+
+// NewReleaseHandler creates a new release handler
+func NewReleaseHandler(db *db.DB, public *delivery.Serializer, related *related.Refresher, cache *cache.Cache) *ReleaseHandler {
+	return &ReleaseHandler{
+		db:      db,
+		public:  public,
+		related: related,
+		cache:   cache,
+	}
+}
+
+// CreateReleaseRequest represents a request to create a release
+type CreateReleaseRequest struct {
+	WorkspaceID uint   `json:"workspace_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// UpdateReleaseRequest represents a request to update a release
+type UpdateReleaseRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ReleaseItemRequest represents a request to add a content change to a release
+type ReleaseItemRequest struct {
+	ContentID uint                  `json:"content_id"`
+	Action    string                `json:"action"`
+	Changes   models.ContentChanges `json:"changes"`
+}
+
+// ScheduleReleaseRequest represents a request to schedule a release. A null
+// scheduled_at returns the release to draft.
+type ScheduleReleaseRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// loadRelease fetches the release in the URL and checks the user may access it
+func (h *ReleaseHandler) loadRelease(w http.ResponseWriter, r *http.Request) (*models.Release, bool) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Release ID is required")
+		return nil, false
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return nil, false
+	}
+
+	var release models.Release
+	if err := h.db.Preload("Items").First(&release, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Release not found")
+		return nil, false
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, release.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return nil, false
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return nil, false
+	}
+
+	return &release, true
+}
+
+// releaseContentIDs returns the IDs of the content items of a release
+func releaseContentIDs(release models.Release) []uint {
+	ids := make([]uint, 0, len(release.Items))
+	for _, item := range release.Items {
+		ids = append(ids, item.ContentID)
+	}
+	return ids
+}
+
+// requirePending responds with a conflict if the release can no longer be changed
+func requirePending(w http.ResponseWriter, release *models.Release) bool {
+	if !releases.IsPending(*release) {
+		utils.RespondWithError(w, http.StatusConflict, "Release has already been published")
+		return false
+	}
+	return true
+}
+
+// CreateRelease handles release creation
+func (h *ReleaseHandler) CreateRelease(w http.ResponseWriter, r *http.Request) {
+	var req CreateReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.WorkspaceID == 0 || req.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID and name are required")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, req.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	release := models.Release{
+		WorkspaceID:  req.WorkspaceID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Status:       releases.StatusDraft,
+		PreviewToken: utils.GenerateRandomString(64),
+		CreatedBy:    claims.UserID,
+		Items:        []models.ReleaseItem{},
+	}
+
+	if err := h.db.Create(&release).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create release")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, release)
+}
+
+// ListReleases handles listing the releases of a workspace
+func (h *ReleaseHandler) ListReleases(w http.ResponseWriter, r *http.Request) {
+	workspaceID := utils.ParseUint(r.URL.Query().Get("workspace_id"))
+	if workspaceID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace ID is required")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, workspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this workspace")
+		return
+	}
+
+	query := h.db.Preload("Items").Where("workspace_id = ?", workspaceID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var list []models.Release
+	if err := query.Order("created_at desc").Find(&list).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch releases")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, list)
+}
+
+// GetRelease handles getting a single release
+func (h *ReleaseHandler) GetRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok {
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, release)
+}
+
+// UpdateRelease handles release updates
+func (h *ReleaseHandler) UpdateRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	var req UpdateReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.Name != "" {
+		release.Name = req.Name
+	}
+	if req.Description != "" {
+		release.Description = req.Description
+	}
+
+	if err := h.db.Model(release).Updates(map[string]interface{}{
+		"name":        release.Name,
+		"description": release.Description,
+	}).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update release")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, release)
+}
+
+// DeleteRelease handles deleting a release that has not been published
+func (h *ReleaseHandler) DeleteRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	tx := h.db.Begin()
+	if err := tx.Where("release_id = ?", release.ID).Delete(&models.ReleaseItem{}).Error; err != nil {
+		tx.Rollback()
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete release items")
+		return
+	}
+	if err := tx.Delete(release).Error; err != nil {
+		tx.Rollback()
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete release")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete release")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Release deleted successfully"})
+}
+
+// AddReleaseItem handles adding a content change to a release. Adding the same
+// content item again replaces its pending change.
+func (h *ReleaseHandler) AddReleaseItem(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	var req ReleaseItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if req.ContentID == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content ID is required")
+		return
+	}
+	if !releases.ValidAction(req.Action) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Action must be one of publish, unpublish or update")
+		return
+	}
+	if req.Changes.Title != nil && *req.Changes.Title == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Title cannot be empty")
+		return
+	}
+	if req.Changes.Slug != nil {
+		if *req.Changes.Slug == "" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Slug cannot be empty")
+			return
+		}
+		slug := utils.ToSlug(*req.Changes.Slug)
+		if err := checkSlug(slug); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Changes.Slug = &slug
+	}
+
+	if req.Changes.SEO != nil {
+		if err := seo.Check(h.db, release.WorkspaceID, req.Changes.SEO); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var content models.Content
+	if err := h.db.Where("id = ? AND workspace_id = ?", req.ContentID, release.WorkspaceID).First(&content).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found in release workspace")
+		return
+	}
+
+	var item models.ReleaseItem
+	err := h.db.Where("release_id = ? AND content_id = ?", release.ID, content.ID).First(&item).Error
+	item.ReleaseID = release.ID
+	item.ContentID = content.ID
+	item.Action = req.Action
+	item.Changes = req.Changes
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusCreated
+	}
+	if err := h.db.Save(&item).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save release item")
+		return
+	}
+
+	utils.RespondWithSuccess(w, status, item)
+}
+
+// RemoveReleaseItem handles removing a content change from a release
+func (h *ReleaseHandler) RemoveReleaseItem(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	itemID := utils.ParseUint(chi.URLParam(r, "itemId"))
+	result := h.db.Where("id = ? AND release_id = ?", itemID, release.ID).Delete(&models.ReleaseItem{})
+	if result.Error != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to remove release item")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Release item not found")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Release item removed successfully"})
+}
+
+// PublishRelease handles publishing every item of a release at once
+func (h *ReleaseHandler) PublishRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	if err := releases.Publish(h.db, release.ID); err != nil {
+		if errors.Is(err, releases.ErrEmpty) || errors.Is(err, releases.ErrNotPending) {
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to publish release: "+err.Error())
+		return
+	}
+
+	h.related.Schedule(release.WorkspaceID, releaseContentIDs(*release)...)
+	h.cache.Invalidate(cache.ReleaseTags(*release)...)
+	usage.ReindexRelease(h.db, *release)
+
+	h.respondWithRelease(w, release.ID)
+}
+
+// ScheduleRelease handles scheduling a release for automatic publishing
+func (h *ReleaseHandler) ScheduleRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok || !requirePending(w, release) {
+		return
+	}
+
+	var req ScheduleReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	status := releases.StatusDraft
+	if req.ScheduledAt != nil {
+		if !req.ScheduledAt.After(time.Now()) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Scheduled time must be in the future")
+			return
+		}
+		if len(release.Items) == 0 {
+			utils.RespondWithError(w, http.StatusConflict, releases.ErrEmpty.Error())
+			return
+		}
+		status = releases.StatusScheduled
+	}
+
+	// The release may have been published since it was loaded
+	result := h.db.Model(release).Where("status = ?", release.Status).Updates(map[string]interface{}{
+		"status":       status,
+		"scheduled_at": req.ScheduledAt,
+		"error":        "",
+	})
+	if result.Error != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to schedule release")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.RespondWithError(w, http.StatusConflict, releases.ErrNotPending.Error())
+		return
+	}
+
+	h.respondWithRelease(w, release.ID)
+}
+
+// RollbackRelease handles restoring every item of a published release. It
+// is refused when content of the release was edited since it went live,
+// unless ?force=true discards those edits.
+func (h *ReleaseHandler) RollbackRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok {
+		return
+	}
+	force, err := parseFlag(r.URL.Query().Get("force"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid force flag")
+		return
+	}
+
+	if err := releases.Rollback(h.db, release.ID, force); err != nil {
+		var changed *releases.ChangedError
+		if errors.As(err, &changed) {
+			utils.RespondWithError(w, http.StatusConflict, changed.Error()+"; roll back with ?force=true to discard those edits")
+			return
+		}
+		if errors.Is(err, releases.ErrNotPublished) {
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to roll back release")
+		return
+	}
+
+	h.related.Schedule(release.WorkspaceID, releaseContentIDs(*release)...)
+	h.cache.Invalidate(cache.ReleaseTags(*release)...)
+	usage.ReindexRelease(h.db, *release)
+
+	h.respondWithRelease(w, release.ID)
+}
+
+// respondWithRelease responds with the current state of a release
+func (h *ReleaseHandler) respondWithRelease(w http.ResponseWriter, id uint) {
+	var release models.Release
+	if err := h.db.Preload("Items").First(&release, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch release")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, release)
+}
+
+// PreviewRelease handles getting the workspace's published content as it will
+// look after the release, including drafts and internal fields
+func (h *ReleaseHandler) PreviewRelease(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.loadRelease(w, r)
+	if !ok {
+		return
+	}
+
+	contents, err := releases.Preview(h.db, *release)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build release preview")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
+		"release":  release,
+		"contents": contents,
+	})
+}
+
+// releaseByToken fetches a pending release by its preview token
+func (h *ReleaseHandler) releaseByToken(w http.ResponseWriter, r *http.Request) (*models.Release, bool) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Preview token is required")
+		return nil, false
+	}
+
+	var release models.Release
+	if err := h.db.Where("preview_token = ?", token).First(&release).Error; err != nil || !releases.IsPending(release) {
+		utils.RespondWithError(w, http.StatusNotFound, "Release not found")
+		return nil, false
+	}
+
+	return &release, true
+}
+
+// GetReleasePreview handles the public, token-scoped preview of a release
+func (h *ReleaseHandler) GetReleasePreview(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.releaseByToken(w, r)
+	if !ok {
+		return
+	}
+
+	contents, err := releases.Preview(h.db, *release)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build release preview")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, map[string]interface{}{
+		"release":  release.Name,
+		"contents": h.public.ContentList(contents),
+		"total":    len(contents),
+	})
+}
+
+// GetReleasePreviewBySlug handles the public preview of a single content item in a release
+func (h *ReleaseHandler) GetReleasePreviewBySlug(w http.ResponseWriter, r *http.Request) {
+	release, ok := h.releaseByToken(w, r)
+	if !ok {
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+
+	contents, err := releases.Preview(h.db, *release)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to build release preview")
+		return
+	}
+
+	for _, content := range contents {
+		if content.Slug == slug {
+			utils.RespondWithSuccess(w, http.StatusOK, h.public.Content(content))
+			return
+		}
+	}
+
+	utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+}
@@ -0,0 +1,195 @@
+// internal/handlers/seo_handler.go
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/middleware"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/seo"
+	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// SEOHandler handles SEO metadata requests
+type SEOHandler struct {
+	db      *db.DB
+	storage storage.Manager
+}
+
+// NewSEOHandler creates a new SEO handler
+func NewSEOHandler(db *db.DB, storage storage.Manager) *SEOHandler {
+	return &SEOHandler{
+		db:      db,
+		storage: storage,
+	}
+}
+
+// SEOReport represents the SEO settings of a content item as they will be rendered
+type SEOReport struct {
+	SEO      models.SEO    `json:"seo"`
+	Meta     seo.Meta      `json:"meta"`
+	Tags     []seo.Tag     `json:"tags"`
+	Warnings []seo.Warning `json:"warnings"`
+}
+
+// HeadResponse represents the head elements of a published content item
+type HeadResponse struct {
+	Title string    `json:"title"`
+	Tags  []seo.Tag `json:"tags"`
+	// HTML holds the title and tags rendered for direct inclusion in the page head
+	HTML string `json:"html"`
+}
+
+// imageURL returns the absolute URL of the image shared for a content item, if any
+func (h *SEOHandler) imageURL(content models.Content, workspace models.Workspace) string {
+	imageID := seo.ImageID(content, workspace)
+	if imageID == nil {
+		return ""
+	}
+
+	var media models.Media
+	if err := h.db.Where("id = ? AND workspace_id = ?", *imageID, workspace.ID).First(&media).Error; err != nil {
+		return ""
+	}
+	// Head tags are cached and shared, which a signed URL must not be
+	if media.Private {
+		return ""
+	}
+
+	// Files served by this server have relative URLs. They are resolved
+	// against the public site URL of the workspace, never the request headers,
+	// and left out without one.
+	url := h.storage.GetURL(media.FilePath, false)
+	if strings.HasPrefix(url, "/") {
+		if workspace.SEO.BaseURL == "" {
+			return ""
+		}
+		url = workspace.SEO.BaseURL + url
+	}
+	return url
+}
+
+// GetContentSEO handles getting the resolved SEO metadata and warnings of a
+// content item. Published items are reported with their working draft applied.
+func (h *SEOHandler) GetContentSEO(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content ID is required")
+		return
+	}
+
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*auth.Claims)
+	if !ok {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from context")
+		return
+	}
+
+	var content models.Content
+	if err := h.db.First(&content, id).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	allowed, err := hasWorkspaceAccess(h.db, claims, content.WorkspaceID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check workspace access")
+		return
+	}
+	if !allowed {
+		utils.RespondWithError(w, http.StatusForbidden, "You don't have access to this content")
+		return
+	}
+
+	draft, err := findDraft(h.db.DB, content.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch draft")
+		return
+	}
+	if draft != nil {
+		applyDraft(&content, *draft)
+	}
+
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, content.WorkspaceID).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	warnings, err := seo.Validate(h.db, content, workspace)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to validate SEO metadata")
+		return
+	}
+
+	meta := seo.Resolve(content, workspace, h.imageURL(content, workspace))
+	utils.RespondWithSuccess(w, http.StatusOK, SEOReport{
+		SEO:      content.SEO,
+		Meta:     meta,
+		Tags:     seo.Tags(meta),
+		Warnings: warnings,
+	})
+}
+
+// GetWorkspaceSEOReport handles listing the SEO warnings of every content item of a workspace
+func (h *SEOHandler) GetWorkspaceSEOReport(w http.ResponseWriter, r *http.Request) {
+	var workspace models.Workspace
+	if err := h.db.First(&workspace, chi.URLParam(r, "id")).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	reports, err := seo.Audit(h.db, workspace)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to audit SEO metadata")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, reports)
+}
+
+// GetContentHead handles getting the head elements of a published content item
+func (h *SEOHandler) GetContentHead(w http.ResponseWriter, r *http.Request) {
+	workspace := chi.URLParam(r, "workspace")
+	slug := chi.URLParam(r, "slug")
+
+	if workspace == "" || slug == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Workspace and slug are required")
+		return
+	}
+
+	var workspaceObj models.Workspace
+	if err := h.db.Where("slug = ?", workspace).First(&workspaceObj).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+		return
+	}
+
+	var content models.Content
+	if err := h.db.Where("workspace_id = ? AND slug = ? AND status = ?", workspaceObj.ID, slug, "published").
+		First(&content).Error; err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		return
+	}
+
+	meta := seo.Resolve(content, workspaceObj, h.imageURL(content, workspaceObj))
+	tags := seo.Tags(meta)
+
+	// Duplicate titles and the author do not matter here, only the item, its
+	// workspace defaults and the shared image
+	cache.TagWorkspace(r, workspaceObj)
+	cache.Tag(r, cache.Content(content.ID))
+	if imageID := seo.ImageID(content, workspaceObj); imageID != nil {
+		cache.Tag(r, cache.Media(*imageID))
+	}
+	utils.RespondWithSuccess(w, http.StatusOK, HeadResponse{
+		Title: meta.Title,
+		Tags:  tags,
+		HTML:  seo.Render(meta.Title, tags),
+	})
+}
@@ -0,0 +1,76 @@
+// internal/handlers/seo_handler_test.go
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+func TestContentHeadImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		// image is the og:image expected, empty for none
+		image string
+	}{
+		{name: "base url", baseURL: "https://blog.example.com", image: "https://blog.example.com/uploads/2024/01/01/cover.png"},
+		{name: "no base url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			store := storage.NewLocalStorage(t.TempDir(), storage.NewSigner(config.StorageConfig{URLSecret: "secret"}, ""))
+			h := NewSEOHandler(database, store)
+
+			media := models.Media{WorkspaceID: 1, Name: "cover", FileName: "cover.png", FilePath: "2024/01/01/cover.png", MimeType: "image/png", Size: 1}
+			if err := database.Create(&media).Error; err != nil {
+				t.Fatal(err)
+			}
+			workspace := models.Workspace{Name: "Site", Slug: "site", SEO: models.SEODefaults{BaseURL: tt.baseURL, ImageID: &media.ID}}
+			content := models.Content{WorkspaceID: 1, ContentTypeID: 1, Title: "Hello", Slug: "hello", Status: "published"}
+			for _, record := range []interface{}{&workspace, &content} {
+				if err := database.Create(record).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			router := chi.NewRouter()
+			router.Get("/content/{workspace}/{slug}/head", h.GetContentHead)
+			// Headers a client can send do not end up in the shared tags
+			req := httptest.NewRequest(http.MethodGet, "/content/site/hello/head", nil)
+			req.Host = "attacker.example"
+			req.Header.Set("X-Forwarded-Proto", "javascript")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET = %d %s, want 200", rec.Code, rec.Body.String())
+			}
+
+			var response struct {
+				Data HeadResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			var image string
+			for _, tag := range response.Data.Tags {
+				if tag.Attributes["property"] == "og:image" {
+					image = tag.Attributes["content"]
+				}
+			}
+			if image != tt.image {
+				t.Errorf("og:image = %q, want %q", image, tt.image)
+			}
+		})
+	}
+}
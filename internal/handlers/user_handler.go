@@ -10,6 +10,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/db"
 	"github.com/randilt/floe-cms/internal/middleware"
 	"github.com/randilt/floe-cms/internal/models"
@@ -18,13 +19,15 @@ import (
 
 // UserHandler handles user-related requests
 type UserHandler struct {
-	db *db.DB
+	db    *db.DB
+	cache *cache.Cache
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(db *db.DB) *UserHandler {
+func NewUserHandler(db *db.DB, cache *cache.Cache) *UserHandler {
 	return &UserHandler{
-		db: db,
+		db:    db,
+		cache: cache,
 	}
 }
 
@@ -136,6 +139,9 @@ type UpdateUserRequest struct {
 	Email     string `json:"email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
+	DisplayName string `json:"display_name"`
+	Avatar    string `json:"avatar"`
+	Bio       string `json:"bio"`
 	RoleID    uint   `json:"role_id"`
 	Active    *bool  `json:"active"`
 }
@@ -189,6 +195,15 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if req.LastName != "" {
 		user.LastName = req.LastName
 	}
+	if req.DisplayName != "" {
+		user.DisplayName = req.DisplayName
+	}
+	if req.Avatar != "" {
+		user.Avatar = req.Avatar
+	}
+	if req.Bio != "" {
+		user.Bio = req.Bio
+	}
 	if req.RoleID != 0 {
 		user.RoleID = req.RoleID
 	}
@@ -201,6 +216,9 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Public content shows the user as its author
+	h.cache.Invalidate(cache.User(user.ID))
+
 	// Don't return password hash
 	user.PasswordHash = ""
 
@@ -320,6 +338,8 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.cache.Invalidate(cache.User(user.ID))
+
 	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "User deleted successfully"})
 }
 
@@ -364,6 +384,9 @@ type UpdateCurrentUserRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Email     string `json:"email"`
+	DisplayName string `json:"display_name"`
+	Avatar    string `json:"avatar"`
+	Bio       string `json:"bio"`
 }
 
 // UpdateCurrentUser handles updating the current user
@@ -416,12 +439,24 @@ func (h *UserHandler) UpdateCurrentUser(w http.ResponseWriter, r *http.Request)
 	if req.LastName != "" {
 		user.LastName = req.LastName
 	}
+	if req.DisplayName != "" {
+		user.DisplayName = req.DisplayName
+	}
+	if req.Avatar != "" {
+		user.Avatar = req.Avatar
+	}
+	if req.Bio != "" {
+		user.Bio = req.Bio
+	}
 
 	if err := h.db.Save(&user).Error; err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
 
+	// Public content shows the user as its author
+	h.cache.Invalidate(cache.User(user.ID))
+
 	// Don't return password hash
 	user.PasswordHash = ""
 
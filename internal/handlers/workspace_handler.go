@@ -7,28 +7,45 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/db"
 	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/seo"
+	"github.com/randilt/floe-cms/internal/uploads"
 	"github.com/randilt/floe-cms/internal/utils"
 )
 
 // WorkspaceHandler handles workspace-related requests
 type WorkspaceHandler struct {
-	db *db.DB
+	db    *db.DB
+	cache *cache.Cache
 }
 
 // NewWorkspaceHandler creates a new workspace handler
-func NewWorkspaceHandler(db *db.DB) *WorkspaceHandler {
+func NewWorkspaceHandler(db *db.DB, cache *cache.Cache) *WorkspaceHandler {
 	return &WorkspaceHandler{
-		db: db,
+		db:    db,
+		cache: cache,
 	}
 }
 
 // CreateWorkspaceRequest represents a request to create a workspace
 type CreateWorkspaceRequest struct {
-	Name        string `json:"name"`
-	Slug        string `json:"slug"`
-	Description string `json:"description"`
+	Name        string             `json:"name"`
+	Slug        string             `json:"slug"`
+	Description string             `json:"description"`
+	SEO         models.SEODefaults `json:"seo"`
+	// CacheControl overrides delivery.cache_control for public responses
+	CacheControl string `json:"cache_control"`
+	// AllowedMediaTypes overrides uploads.allowed_types with MIME types,
+	// wildcards such as image/* and file extensions such as .pdf
+	AllowedMediaTypes []string `json:"allowed_media_types"`
+	// StripImageMetadata overrides uploads.strip_metadata when given
+	StripImageMetadata *bool `json:"strip_image_metadata"`
+	// MediaQuotaSize and MediaQuotaFiles override uploads.quota_size (MiB)
+	// and uploads.quota_files when given; 0 means unlimited
+	MediaQuotaSize  *int `json:"media_quota_size"`
+	MediaQuotaFiles *int `json:"media_quota_files"`
 }
 
 // CreateWorkspace handles workspace creation
@@ -50,11 +67,39 @@ func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 		req.Slug = utils.ToSlug(req.Name)
 	}
 
+	// A new workspace has no media yet, so no default image can be set
+	if err := seo.CheckDefaults(h.db, 0, &req.SEO); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	policy, err := cache.NormalizePolicy(req.CacheControl)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	allowlist, err := uploads.NormalizeAllowlist(req.AllowedMediaTypes)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validQuotas(w, req.MediaQuotaSize, req.MediaQuotaFiles) {
+		return
+	}
+
 	// Create workspace
 	workspace := models.Workspace{
-		Name:        req.Name,
-		Slug:        req.Slug,
-		Description: req.Description,
+		Name:               req.Name,
+		Slug:               req.Slug,
+		Description:        req.Description,
+		SEO:                req.SEO,
+		CacheControl:       policy,
+		AllowedMediaTypes:  allowlist,
+		StripImageMetadata: req.StripImageMetadata,
+		MediaQuotaSize:     req.MediaQuotaSize,
+		MediaQuotaFiles:    req.MediaQuotaFiles,
 	}
 
 	if err := h.db.Create(&workspace).Error; err != nil {
@@ -65,11 +110,34 @@ func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 	utils.RespondWithSuccess(w, http.StatusCreated, workspace)
 }
 
+// validQuotas checks the media quotas of a workspace request. It responds
+// with an error and returns false when one is negative.
+func validQuotas(w http.ResponseWriter, size, files *int) bool {
+	if (size != nil && *size < 0) || (files != nil && *files < 0) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Media quotas must not be negative")
+		return false
+	}
+	return true
+}
+
 // UpdateWorkspaceRequest represents a request to update a workspace
 type UpdateWorkspaceRequest struct {
 	Name        string `json:"name"`
 	Slug        string `json:"slug"`
 	Description string `json:"description"`
+	// SEO replaces the SEO defaults when given
+	SEO *models.SEODefaults `json:"seo"`
+	// CacheControl replaces the Cache-Control policy when given; an empty
+	// string falls back to delivery.cache_control
+	CacheControl *string `json:"cache_control"`
+	// AllowedMediaTypes replaces the upload allowlist when given; an empty
+	// list falls back to uploads.allowed_types
+	AllowedMediaTypes *[]string `json:"allowed_media_types"`
+	// StripImageMetadata replaces the metadata stripping setting when given
+	StripImageMetadata *bool `json:"strip_image_metadata"`
+	// MediaQuotaSize and MediaQuotaFiles replace the media quotas when given
+	MediaQuotaSize  *int `json:"media_quota_size"`
+	MediaQuotaFiles *int `json:"media_quota_files"`
 }
 
 // UpdateWorkspace handles workspace updates
@@ -103,12 +171,49 @@ func (h *WorkspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Reques
 	if req.Description != "" {
 		workspace.Description = req.Description
 	}
+	if req.SEO != nil {
+		if err := seo.CheckDefaults(h.db, workspace.ID, req.SEO); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		workspace.SEO = *req.SEO
+	}
+	if req.CacheControl != nil {
+		policy, err := cache.NormalizePolicy(*req.CacheControl)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		workspace.CacheControl = policy
+	}
+	if req.AllowedMediaTypes != nil {
+		allowlist, err := uploads.NormalizeAllowlist(*req.AllowedMediaTypes)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		workspace.AllowedMediaTypes = allowlist
+	}
+	if req.StripImageMetadata != nil {
+		workspace.StripImageMetadata = req.StripImageMetadata
+	}
+	if !validQuotas(w, req.MediaQuotaSize, req.MediaQuotaFiles) {
+		return
+	}
+	if req.MediaQuotaSize != nil {
+		workspace.MediaQuotaSize = req.MediaQuotaSize
+	}
+	if req.MediaQuotaFiles != nil {
+		workspace.MediaQuotaFiles = req.MediaQuotaFiles
+	}
 
 	if err := h.db.Save(&workspace).Error; err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update workspace")
 		return
 	}
 
+	h.cache.Invalidate(cache.Workspace(workspace.ID))
+
 	utils.RespondWithSuccess(w, http.StatusOK, workspace)
 }
 
@@ -166,6 +271,8 @@ func (h *WorkspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.cache.Invalidate(cache.Workspace(utils.ParseUint(id)))
+
 	utils.RespondWithSuccess(w, http.StatusOK, map[string]string{"message": "Workspace deleted successfully"})
 }
 
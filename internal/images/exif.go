@@ -0,0 +1,306 @@
+// internal/images/exif.go
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// exifOrientationTag is the TIFF tag holding the EXIF orientation
+const exifOrientationTag = 0x0112
+
+// exifHeader starts the EXIF data of JPEG APP1 segments
+var exifHeader = []byte("Exif\x00\x00")
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the PNG chunks that carry EXIF, XMP or free text
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true}
+
+// jpegKeptSegments are the JPEG application segments kept when stripping
+// metadata: JFIF, the ICC color profile and Adobe's color transform
+var jpegKeptSegments = map[byte]bool{0xe0: true, 0xe2: true, 0xee: true}
+
+// ErrMalformed is returned for image data whose structure cannot be parsed
+var ErrMalformed = errors.New("malformed image data")
+
+// Orientation returns the EXIF orientation of JPEG, PNG or WebP data, from 1
+// to 8, or 1 when there is none
+func Orientation(data []byte) int {
+	tiff := exifData(data)
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == exifOrientationTag {
+			if value := int(order.Uint16(tiff[entry+8:])); value >= 1 && value <= 8 {
+				return value
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// Swapped reports whether an EXIF orientation turns the image on its side,
+// swapping its width and height
+func Swapped(orientation int) bool {
+	return orientation >= 5
+}
+
+// exifData returns the TIFF-structured EXIF data of JPEG, PNG or WebP data
+func exifData(data []byte) []byte {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		var tiff []byte
+		walkJPEG(data, func(marker byte, segment []byte) bool {
+			if marker == 0xe1 && bytes.HasPrefix(segment[4:], exifHeader) {
+				tiff = segment[4+len(exifHeader):]
+				return false
+			}
+			return true
+		})
+		return tiff
+	case bytes.HasPrefix(data, pngSignature):
+		var tiff []byte
+		walkPNG(data, func(kind string, chunk []byte) {
+			if kind == "eXIf" {
+				tiff = chunk[8 : len(chunk)-4]
+			}
+		})
+		return tiff
+	case isWebP(data):
+		var tiff []byte
+		walkWebP(data, func(kind string, chunk []byte) {
+			if kind == "EXIF" {
+				tiff = bytes.TrimPrefix(chunk[8:], exifHeader)
+			}
+		})
+		return tiff
+	}
+	return nil
+}
+
+// walkJPEG calls fn with the marker and bytes of every segment before the
+// image data, until fn returns false. It returns the offset of the image
+// data, or -1 if the segments are malformed.
+func walkJPEG(data []byte, fn func(marker byte, segment []byte) bool) int {
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return -1
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// Fill byte
+			pos++
+			continue
+		}
+		if marker == 0xda {
+			return pos
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return -1
+		}
+		if !fn(marker, data[pos:pos+2+length]) {
+			return pos
+		}
+		pos += 2 + length
+	}
+	return -1
+}
+
+// walkPNG calls fn with the type and bytes of every chunk, including its
+// length and checksum. It returns false if the chunks are malformed.
+func walkPNG(data []byte, fn func(kind string, chunk []byte)) bool {
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) {
+			return false
+		}
+		kind := string(data[pos+4 : pos+8])
+		fn(kind, data[pos:end])
+		if kind == "IEND" {
+			return true
+		}
+		pos = end
+	}
+	return false
+}
+
+// isWebP reports whether data is a WebP file
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// walkWebP calls fn with the FourCC and bytes of every chunk, including its
+// header and padding. It returns false if the chunks are malformed.
+func walkWebP(data []byte, fn func(kind string, chunk []byte)) bool {
+	pos := 12
+	for pos+8 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if pos+8+size > len(data) {
+			return false
+		}
+		// Chunks are padded to an even size; a missing final pad byte is tolerated
+		end := min(pos+8+size+size%2, len(data))
+		fn(string(data[pos:pos+4]), data[pos:end])
+		pos = end
+	}
+	return pos == len(data)
+}
+
+// orientationSegment returns a JPEG APP1 segment with EXIF data holding
+// nothing but an orientation
+func orientationSegment(orientation int) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1}
+	tiff = binary.BigEndian.AppendUint16(tiff, exifOrientationTag)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // padding and no next IFD
+
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(exifHeader)+len(tiff)))
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff...)
+}
+
+// StripMetadata removes EXIF, XMP, IPTC and comment metadata, such as GPS
+// coordinates and camera details, from JPEG, PNG and WebP data without
+// re-encoding it. JPEGs keep their orientation so that they still display
+// upright. Other data is returned as it is.
+func StripMetadata(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		orientation := Orientation(data)
+		out := make([]byte, 0, len(data))
+		out = append(out, 0xff, 0xd8)
+		inserted := orientation == 1
+		end := walkJPEG(data, func(marker byte, segment []byte) bool {
+			if (marker >= 0xe0 && marker <= 0xef && !jpegKeptSegments[marker]) || marker == 0xfe {
+				return true
+			}
+			out = append(out, segment...)
+			if !inserted && marker == 0xe0 {
+				out = append(out, orientationSegment(orientation)...)
+				inserted = true
+			}
+			return true
+		})
+		if end < 0 {
+			return nil, ErrMalformed
+		}
+		if !inserted {
+			// Without a JFIF segment, EXIF goes right after the start of image
+			out = append(out[:2], append(orientationSegment(orientation), out[2:]...)...)
+		}
+		return append(out, data[end:]...), nil
+
+	case bytes.HasPrefix(data, pngSignature):
+		out := make([]byte, 0, len(data))
+		out = append(out, pngSignature...)
+		ok := walkPNG(data, func(kind string, chunk []byte) {
+			if !pngMetadataChunks[kind] {
+				out = append(out, chunk...)
+			}
+		})
+		if !ok {
+			return nil, ErrMalformed
+		}
+		return out, nil
+
+	case isWebP(data):
+		out := make([]byte, 0, len(data))
+		out = append(out, data[:12]...)
+		ok := walkWebP(data, func(kind string, chunk []byte) {
+			switch kind {
+			case "EXIF", "XMP ":
+				return
+			case "VP8X":
+				// Clear the flags announcing EXIF and XMP chunks
+				chunk = append([]byte{}, chunk...)
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out = append(out, chunk...)
+		})
+		if !ok {
+			return nil, ErrMalformed
+		}
+		binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+		return out, nil
+	}
+	return data, nil
+}
+
+// Orient returns an image turned upright as its EXIF orientation says
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if Swapped(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
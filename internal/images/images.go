@@ -0,0 +1,253 @@
+// internal/images/images.go
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // GIF decoding
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // WebP decoding
+)
+
+// Image formats, as reported by image.Decode and accepted by fmt
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// Ways of fitting an image into the requested size
+const (
+	// FitContain scales the image down to fit inside the size
+	FitContain = "contain"
+	// FitCover scales and crops the image to fill the size
+	FitCover = "cover"
+	// FitFill stretches the image to the size
+	FitFill = "fill"
+)
+
+var (
+	// ErrNotImage is returned for files that are not decodable images
+	ErrNotImage = errors.New("file is not a supported image")
+	// ErrTooLarge is returned for images with more pixels than allowed
+	ErrTooLarge = errors.New("image is too large to process")
+)
+
+// mimeTypes maps output formats to their MIME types
+var mimeTypes = map[string]string{
+	FormatJPEG: "image/jpeg",
+	FormatPNG:  "image/png",
+	FormatWebP: "image/webp",
+}
+
+// Options describes a transform of an image. Zero values keep the original
+// size and format.
+type Options struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// ParseOptions reads transform options from the w, h, fit and fmt query
+// parameters, allowing sizes up to maxSize
+func ParseOptions(query url.Values, maxSize int) (Options, error) {
+	var opts Options
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"w", &opts.Width}, {"h", &opts.Height}} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSize {
+			return Options{}, fmt.Errorf("%s must be between 1 and %d", param.name, maxSize)
+		}
+		*param.value = n
+	}
+
+	opts.Fit = query.Get("fit")
+	switch opts.Fit {
+	case "", FitContain, FitCover, FitFill:
+	default:
+		return Options{}, fmt.Errorf("fit must be one of %s, %s or %s", FitContain, FitCover, FitFill)
+	}
+
+	opts.Format = query.Get("fmt")
+	if opts.Format == "jpg" {
+		opts.Format = FormatJPEG
+	}
+	switch opts.Format {
+	case "", FormatJPEG, FormatPNG, FormatWebP:
+	default:
+		return Options{}, fmt.Errorf("fmt must be one of %s, %s or %s", FormatJPEG, FormatPNG, FormatWebP)
+	}
+
+	return opts, nil
+}
+
+// Query returns the canonical query parameters of the options
+func (o Options) Query() url.Values {
+	q := url.Values{}
+	if o.Width > 0 {
+		q.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		q.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" {
+		q.Set("fit", o.Fit)
+	}
+	if o.Format != "" {
+		q.Set("fmt", o.Format)
+	}
+	return q
+}
+
+// FormatOf returns the image format of a MIME type, or "" if it is not a
+// decodable image
+func FormatOf(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg", "image/jpg", "image/pjpeg":
+		return FormatJPEG
+	case "image/png":
+		return FormatPNG
+	case "image/gif":
+		return FormatGIF
+	case "image/webp":
+		return FormatWebP
+	}
+	return ""
+}
+
+// OutputFormat returns the format an image of the source format is written in
+// when no format is requested. GIFs are written as PNG.
+func OutputFormat(source string) string {
+	switch source {
+	case FormatJPEG, FormatWebP:
+		return source
+	}
+	return FormatPNG
+}
+
+// Extension returns the file extension of an output format
+func Extension(format string) string {
+	if format == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + format
+}
+
+// MimeType returns the MIME type of an output format
+func MimeType(format string) string {
+	return mimeTypes[format]
+}
+
+// Decode decodes an image, refusing images with more than maxPixels pixels
+// before their pixel data is read
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrNotImage
+	}
+	if maxPixels > 0 && cfg.Width*cfg.Height > maxPixels {
+		return nil, format, ErrTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to decode image: %v", err)
+	}
+	return img, format, nil
+}
+
+// Resize returns the image scaled to the options' size. Images are never
+// enlarged, except by FitFill.
+func Resize(src image.Image, opts Options) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	w, h := opts.Width, opts.Height
+	if sw == 0 || sh == 0 || (w == 0 && h == 0) {
+		return src
+	}
+
+	switch {
+	case opts.Fit == FitFill:
+		if w == 0 {
+			w = sw
+		}
+		if h == 0 {
+			h = sh
+		}
+		return scale(src, b, w, h)
+
+	case opts.Fit == FitCover && w > 0 && h > 0:
+		// Crop the largest centred area of the requested aspect ratio
+		aspect := float64(w) / float64(h)
+		cw, ch := sw, int(math.Round(float64(sw)/aspect))
+		if ch > sh {
+			cw, ch = int(math.Round(float64(sh)*aspect)), sh
+		}
+		crop := image.Rect(0, 0, cw, ch).Add(b.Min).Add(image.Pt((sw-cw)/2, (sh-ch)/2))
+		if cw < w {
+			w, h = cw, ch
+		}
+		return scale(src, crop, w, h)
+	}
+
+	ratio := math.Inf(1)
+	if w > 0 {
+		ratio = float64(w) / float64(sw)
+	}
+	if h > 0 {
+		ratio = math.Min(ratio, float64(h)/float64(sh))
+	}
+	if ratio >= 1 {
+		return src
+	}
+	return scale(src, b, max(1, int(math.Round(float64(sw)*ratio))), max(1, int(math.Round(float64(sh)*ratio))))
+}
+
+// scale draws an area of an image into a new image of the given size
+func scale(src image.Image, area image.Rectangle, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, area, draw.Src, nil)
+	return dst
+}
+
+// Encode writes an image in an output format. JPEG has no transparency, so
+// transparent areas are flattened onto white.
+func Encode(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		opaque := image.NewRGBA(img.Bounds())
+		draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(opaque, opaque.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		err = png.Encode(&buf, img)
+	case FormatWebP:
+		err = nativewebp.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported output format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,43 @@
+// internal/images/images_test.go
+package images
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Options
+		// invalid is whether the options are rejected
+		invalid bool
+	}{
+		{query: "", want: Options{}},
+		{query: "w=400&h=300&fit=cover&fmt=webp", want: Options{Width: 400, Height: 300, Fit: FitCover, Format: FormatWebP}},
+		{query: "fmt=jpg", want: Options{Format: FormatJPEG}},
+		{query: "fmt=png", want: Options{Format: FormatPNG}},
+		{query: "w=2000", want: Options{Width: 2000}},
+		{query: "w=2001", invalid: true},
+		{query: "h=0", invalid: true},
+		{query: "fit=crop", invalid: true},
+		// No AVIF encoder is available, so AVIF is not offered as an output
+		{query: "fmt=avif", invalid: true},
+		{query: "fmt=gif", invalid: true},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseOptions(query, 2000)
+		if invalid := err != nil; invalid != tt.invalid {
+			t.Errorf("ParseOptions(%q) error = %v, want invalid %v", tt.query, err, tt.invalid)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseOptions(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// internal/images/placeholder.go
+package images
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Orientations of an image's shape, once turned upright
+const (
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+	OrientationSquare    = "square"
+)
+
+const (
+	// sampleSize is the size images are scaled down to for their summary
+	sampleSize = 32
+	// placeholderSize is the size of the low-quality image placeholder
+	placeholderSize = 16
+	// placeholderQuality is the JPEG quality of the placeholder
+	placeholderQuality = 50
+)
+
+// base83 is the alphabet of BlurHash strings
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Summary describes what an image looks like before it is loaded
+type Summary struct {
+	// DominantColor is the most common color, as #rrggbb
+	DominantColor string
+	// BlurHash is a compact blurred representation, see https://blurha.sh
+	BlurHash string
+	// Placeholder is a tiny JPEG of the image as a data URI
+	Placeholder string
+}
+
+// Shape returns the orientation of an image of the given size
+func Shape(width, height int) string {
+	switch {
+	case width > height:
+		return OrientationLandscape
+	case height > width:
+		return OrientationPortrait
+	}
+	return OrientationSquare
+}
+
+// Summarize computes the dominant color, BlurHash and placeholder of an
+// upright image. Transparent areas are flattened onto white.
+func Summarize(img image.Image) (Summary, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return Summary{}, ErrNotImage
+	}
+
+	sample := Resize(img, Options{Width: sampleSize, Height: sampleSize})
+	sb := sample.Bounds()
+	opaque := image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), sample, sb.Min, draw.Over)
+
+	// More components along the longer side keep the hash's detail even
+	cx, cy := 4, 3
+	if b.Dy() > b.Dx() {
+		cx, cy = 3, 4
+	}
+
+	placeholder, err := Encode(Resize(img, Options{Width: placeholderSize, Height: placeholderSize}), FormatJPEG, placeholderQuality)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return Summary{
+		DominantColor: dominantColor(opaque),
+		BlurHash:      blurHash(opaque, cx, cy),
+		Placeholder:   "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(placeholder),
+	}, nil
+}
+
+// dominantColor returns the average color of the most common bucket of
+// similar colors in an image
+func dominantColor(img *image.RGBA) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := map[int]*bucket{}
+	var top *bucket
+
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+		key := r>>4<<8 | g>>4<<4 | b>>4
+		bk := buckets[key]
+		if bk == nil {
+			bk = &bucket{}
+			buckets[key] = bk
+		}
+		bk.count++
+		bk.r, bk.g, bk.b = bk.r+r, bk.g+g, bk.b+b
+		if top == nil || bk.count > top.count {
+			top = bk
+		}
+	}
+	if top == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", top.r/top.count, top.g/top.count, top.b/top.count)
+}
+
+// blurHash encodes an image as a BlurHash with the given number of
+// components along each axis
+func blurHash(img *image.RGBA, cx, cy int) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	factors := make([][3]float64, 0, cx*cy)
+
+	for j := 0; j < cy; j++ {
+		for i := 0; i < cx; i++ {
+			normalization := 2.0
+			if i == 0 && j == 0 {
+				normalization = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalization *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					p := img.PixOffset(x, y)
+					for c := 0; c < 3; c++ {
+						f[c] += basis * srgbToLinear(img.Pix[p+c])
+					}
+				}
+			}
+			for c := range f {
+				f[c] /= float64(w * h)
+			}
+			factors = append(factors, f)
+		}
+	}
+
+	var sb strings.Builder
+	encode83(&sb, (cx-1)+(cy-1)*9, 1)
+
+	maximum := 1.0
+	if len(factors) > 1 {
+		actual := 0.0
+		for _, f := range factors[1:] {
+			actual = math.Max(actual, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := clamp(int(math.Floor(actual*166-0.5)), 0, 82)
+		maximum = float64(quantised+1) / 166
+		encode83(&sb, quantised, 1)
+	} else {
+		encode83(&sb, 0, 1)
+	}
+
+	dc := factors[0]
+	encode83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+
+	for _, f := range factors[1:] {
+		var q [3]int
+		for c := range f {
+			q[c] = clamp(int(math.Floor(signPow(f[c]/maximum, 0.5)*9+9.5)), 0, 18)
+		}
+		encode83(&sb, q[0]*19*19+q[1]*19+q[2], 2)
+	}
+
+	return sb.String()
+}
+
+// encode83 writes a value as the given number of base 83 digits
+func encode83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		sb.WriteByte(base83[digit])
+	}
+}
+
+// srgbToLinear converts an sRGB channel value to linear light
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts linear light to an sRGB channel value
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow raises the magnitude of a value to a power, keeping its sign
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+// clamp limits a value to a range
+func clamp(value, low, high int) int {
+	return max(low, min(high, value))
+}
@@ -0,0 +1,382 @@
+// internal/images/processor.go
+package images
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// TransformsDir is the storage directory generated transforms are cached in
+const TransformsDir = "_transforms"
+
+// Processor generates image variants and on-the-fly transforms of media
+type Processor struct {
+	db      *db.DB
+	storage storage.Manager
+	cfg     config.ImagesConfig
+	secret  []byte
+	// slots bounds the number of images decoded at once
+	slots chan struct{}
+}
+
+// NewProcessor creates an image processor. Without a configured transform
+// secret, one is derived from the JWT secret.
+func NewProcessor(database *db.DB, store storage.Manager, cfg config.ImagesConfig, jwtSecret string) *Processor {
+	secret := []byte(cfg.TransformSecret)
+	if len(secret) == 0 {
+		mac := hmac.New(sha256.New, []byte(jwtSecret))
+		mac.Write([]byte("floe image transforms"))
+		secret = mac.Sum(nil)
+	}
+
+	return &Processor{
+		db:      database,
+		storage: store,
+		cfg:     cfg,
+		secret:  secret,
+		slots:   make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+// read returns the contents of a stored file
+func (p *Processor) read(filePath string) ([]byte, error) {
+	file, err := p.storage.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// acquire waits for a free processing slot
+func (p *Processor) acquire() {
+	p.slots <- struct{}{}
+}
+
+// release frees a processing slot
+func (p *Processor) release() {
+	<-p.slots
+}
+
+// output is an encoded variant
+type output struct {
+	format string
+	data   []byte
+}
+
+// variantPath returns the storage path of a variant of a media file
+func variantPath(filePath, name, format string) string {
+	return strings.TrimSuffix(filePath, path.Ext(filePath)) + "_" + name + Extension(format)
+}
+
+// Generate records the upright dimensions, orientation, dominant color and
+// placeholders of an image and generates its configured variants, replacing
+// those generated before. Files that are not images are left alone; GIFs are
+// summarized but not resized, as that would drop their animation.
+func (p *Processor) Generate(media *models.Media) error {
+	if !strings.HasPrefix(media.MimeType, "image/") || media.MimeType == "image/svg+xml" {
+		return nil
+	}
+
+	p.acquire()
+	defer p.release()
+
+	data, err := p.read(media.FilePath)
+	if err != nil {
+		return err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	orientation := Orientation(data)
+	media.Width, media.Height = cfg.Width, cfg.Height
+	if Swapped(orientation) {
+		media.Width, media.Height = cfg.Height, cfg.Width
+	}
+	media.Orientation = Shape(media.Width, media.Height)
+	if err := p.db.Model(media).UpdateColumns(map[string]interface{}{
+		"width":       media.Width,
+		"height":      media.Height,
+		"orientation": media.Orientation,
+	}).Error; err != nil {
+		return err
+	}
+
+	img, format, err := Decode(data, p.cfg.MaxPixels)
+	if errors.Is(err, ErrTooLarge) {
+		slog.Warn("Image too large for variants", "media_id", media.ID, "width", cfg.Width, "height", cfg.Height)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	img = Orient(img, orientation)
+
+	summary, err := Summarize(img)
+	if err != nil {
+		return err
+	}
+	media.DominantColor, media.BlurHash, media.Placeholder = summary.DominantColor, summary.BlurHash, summary.Placeholder
+	if err := p.db.Model(media).UpdateColumns(map[string]interface{}{
+		"dominant_color": media.DominantColor,
+		"blur_hash":      media.BlurHash,
+		"placeholder":    media.Placeholder,
+	}).Error; err != nil {
+		return err
+	}
+	if format == FormatGIF {
+		return nil
+	}
+
+	variants := []models.MediaVariant{}
+	for _, v := range p.cfg.Variants {
+		resized := Resize(img, Options{Width: v.Width, Height: v.Height, Fit: v.Fit})
+		base := OutputFormat(format)
+		encoded, err := Encode(resized, base, p.cfg.Quality)
+		if err != nil {
+			return err
+		}
+
+		outputs := []output{{base, encoded}}
+		if p.cfg.WebP && base != FormatWebP {
+			// WebP is written losslessly, which only pays off for some images
+			if webp, err := Encode(resized, FormatWebP, p.cfg.Quality); err == nil && len(webp) < len(encoded) {
+				outputs = append(outputs, output{FormatWebP, webp})
+			}
+		}
+
+		for _, out := range outputs {
+			variantFile := variantPath(media.FilePath, v.Name, out.format)
+			if err := p.storage.Put(variantFile, bytes.NewReader(out.data), int64(len(out.data)), MimeType(out.format)); err != nil {
+				return err
+			}
+			variants = append(variants, models.MediaVariant{
+				MediaID:  media.ID,
+				Name:     v.Name,
+				FilePath: variantFile,
+				MimeType: MimeType(out.format),
+				Size:     int64(len(out.data)),
+				Width:    resized.Bounds().Dx(),
+				Height:   resized.Bounds().Dy(),
+			})
+		}
+	}
+
+	previous, err := p.variants(media.ID)
+	if err != nil {
+		return err
+	}
+	err = db.ExecuteWithTransaction(p.db, func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variants) == 0 {
+			return nil
+		}
+		return tx.Create(&variants).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files of variants that were not generated again, such as a dropped WebP copy
+	current := map[string]bool{}
+	for _, v := range variants {
+		current[v.FilePath] = true
+	}
+	for _, v := range previous {
+		if !current[v.FilePath] {
+			p.storage.Delete(v.FilePath)
+		}
+	}
+
+	media.Variants = variants
+	return nil
+}
+
+// variants returns the recorded variants of a media item
+func (p *Processor) variants(mediaID uint) ([]models.MediaVariant, error) {
+	var variants []models.MediaVariant
+	err := p.db.Where("media_id = ?", mediaID).Order("id asc").Find(&variants).Error
+	return variants, err
+}
+
+// Remove deletes the variants and cached transforms of a media item
+func (p *Processor) Remove(media models.Media) error {
+	variants, err := p.variants(media.ID)
+	if err != nil {
+		return err
+	}
+	p.RemoveFiles(media, variants)
+	return p.Forget(media.ID)
+}
+
+// RemoveFiles deletes the files of the given variants and the cached
+// transforms of a media item, whose records are deleted separately
+func (p *Processor) RemoveFiles(media models.Media, variants []models.MediaVariant) {
+	for _, v := range variants {
+		if err := p.storage.Delete(v.FilePath); err != nil {
+			slog.Warn("Failed to delete image variant", "path", v.FilePath, "error", err)
+		}
+	}
+	if err := p.storage.DeleteDir(TransformsDir + "/" + media.FilePath); err != nil {
+		slog.Warn("Failed to delete image transforms", "path", media.FilePath, "error", err)
+	}
+}
+
+// Forget deletes the variant records of a media item but not their files,
+// which other media sharing its file still use
+func (p *Processor) Forget(mediaID uint) error {
+	return p.db.Unscoped().Where("media_id = ?", mediaID).Delete(&models.MediaVariant{}).Error
+}
+
+// Backfill generates the variants and placeholders of images uploaded before
+// they were generated, that is images without recorded dimensions or BlurHash
+func (p *Processor) Backfill() {
+	var media []models.Media
+	if err := p.db.Where("(width = 0 OR blur_hash = '' OR blur_hash IS NULL) AND mime_type LIKE ? AND mime_type <> ?", "image/%", "image/svg+xml").Find(&media).Error; err != nil {
+		slog.Error("Failed to fetch media for image variants", "error", err)
+		return
+	}
+	for i := range media {
+		if err := p.Generate(&media[i]); err != nil {
+			slog.Error("Failed to generate image variants", "media_id", media[i].ID, "error", err)
+		}
+	}
+}
+
+// Sign returns the signature of a transform of a stored file
+func (p *Processor) Sign(filePath string, opts Options) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(filePath + "?" + opts.Query().Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// URL returns the signed URL of a transform of a stored file
+func (p *Processor) URL(filePath string, opts Options) string {
+	q := opts.Query()
+	q.Set("s", p.Sign(filePath, opts))
+	return "/uploads/" + filePath + "?" + q.Encode()
+}
+
+// ParseOptions reads transform options from a query, within the configured limits
+func (p *Processor) ParseOptions(query url.Values) (Options, error) {
+	return ParseOptions(query, p.cfg.MaxTransformSize)
+}
+
+// Transform returns the storage path of a transform of a media item,
+// generating and storing it unless it was cached before
+func (p *Processor) Transform(media models.Media, opts Options) (string, error) {
+	output := opts.Format
+	if output == "" {
+		output = OutputFormat(FormatOf(media.MimeType))
+	}
+	name := fmt.Sprintf("%dx%d", opts.Width, opts.Height)
+	if opts.Fit != "" {
+		name += "_" + opts.Fit
+	}
+	cached := TransformsDir + "/" + media.FilePath + "/" + name + Extension(output)
+	if file, err := p.storage.Open(cached); err == nil {
+		file.Close()
+		return cached, nil
+	}
+
+	p.acquire()
+	defer p.release()
+
+	data, err := p.read(media.FilePath)
+	if err != nil {
+		return "", err
+	}
+	img, _, err := Decode(data, p.cfg.MaxPixels)
+	if err != nil {
+		return "", err
+	}
+	img = Orient(img, Orientation(data))
+
+	encoded, err := Encode(Resize(img, opts), output, p.cfg.Quality)
+	if err != nil {
+		return "", err
+	}
+	if err := p.storage.Put(cached, bytes.NewReader(encoded), int64(len(encoded)), MimeType(output)); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// Middleware answers requests for stored files that carry transform
+// parameters with the signed transform, and passes the others on. It is
+// mounted with the /uploads/ prefix stripped.
+func (p *Processor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("w") && !query.Has("h") && !query.Has("fit") && !query.Has("fmt") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		filePath := strings.TrimPrefix(r.URL.Path, "/")
+		opts, err := p.ParseOptions(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(query.Get("s")), []byte(p.Sign(filePath, opts))) {
+			http.Error(w, "Invalid transform signature", http.StatusForbidden)
+			return
+		}
+
+		var media models.Media
+		if err := p.db.Where("file_path = ?", filePath).Limit(1).Find(&media).Error; err != nil {
+			http.Error(w, "Failed to fetch media", http.StatusInternalServerError)
+			return
+		}
+		if media.ID == 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		cached, err := p.Transform(media, opts)
+		switch {
+		case errors.Is(err, ErrNotImage):
+			http.Error(w, "Media is not a transformable image", http.StatusBadRequest)
+			return
+		case errors.Is(err, ErrTooLarge):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			http.NotFound(w, r)
+			return
+		case err != nil:
+			slog.Error("Failed to transform image", "path", filePath, "error", err)
+			http.Error(w, "Failed to transform image", http.StatusInternalServerError)
+			return
+		}
+
+		served := r.Clone(r.Context())
+		served.URL.Path = "/" + cached
+		served.URL.RawPath = ""
+		served.URL.RawQuery = ""
+		next.ServeHTTP(w, served)
+	})
+}
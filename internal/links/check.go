@@ -0,0 +1,332 @@
+// internal/links/check.go
+package links
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Broken is a link that does not resolve
+type Broken struct {
+	Link
+	Reason string `json:"reason"`
+}
+
+// Report lists the broken links of a content item
+type Report struct {
+	ContentID uint     `json:"content_id"`
+	Title     string   `json:"title"`
+	Status    string   `json:"status"`
+	Links     []Broken `json:"links"`
+}
+
+// Result is the outcome of checking the links of a workspace
+type Result struct {
+	CheckedAt *time.Time `json:"checked_at"`
+	Broken    int        `json:"broken"`
+	Reports   []Report   `json:"reports"`
+}
+
+// Reference is a content item linking to a content item or media
+type Reference struct {
+	ContentID uint   `json:"content_id"`
+	Title     string `json:"title"`
+	Field     string `json:"field"`
+	URL       string `json:"url"`
+}
+
+// index holds what the internal links of a workspace can resolve to
+type index struct {
+	// slugs maps content slugs to whether any item using them is published
+	slugs map[string]bool
+	media map[string]bool
+}
+
+// load reads the content and media of a workspace
+func load(database *db.DB, workspaceID uint) ([]models.Content, index, error) {
+	var contents []models.Content
+	if err := database.Select("id", "title", "slug", "status", "body", "fields").
+		Where("workspace_id = ?", workspaceID).
+		Order("id asc").
+		Find(&contents).Error; err != nil {
+		return nil, index{}, err
+	}
+
+	var paths, variantPaths []string
+	if err := database.Model(&models.Media{}).Where("workspace_id = ?", workspaceID).Pluck("file_path", &paths).Error; err != nil {
+		return nil, index{}, err
+	}
+	if err := database.Model(&models.MediaVariant{}).
+		Where("media_id IN (?)", database.Model(&models.Media{}).Select("id").Where("workspace_id = ?", workspaceID)).
+		Pluck("file_path", &variantPaths).Error; err != nil {
+		return nil, index{}, err
+	}
+	paths = append(paths, variantPaths...)
+
+	idx := index{slugs: map[string]bool{}, media: map[string]bool{}}
+	for _, content := range contents {
+		idx.slugs[content.Slug] = idx.slugs[content.Slug] || content.Status == "published"
+	}
+	for _, path := range paths {
+		idx.media[path] = true
+	}
+	return contents, idx, nil
+}
+
+// resolve returns why a link is broken, or "" if it resolves
+func (idx index) resolve(link Link) string {
+	if link.Kind == KindMedia {
+		if !idx.media[link.Target] {
+			return ReasonMissing
+		}
+		return ""
+	}
+
+	published, ok := idx.slugs[link.Target]
+	switch {
+	case !ok:
+		return ReasonMissing
+	case !published:
+		return ReasonUnpublished
+	}
+	return ""
+}
+
+// Check reports the broken internal links of every content item of a workspace
+func Check(database *db.DB, workspace models.Workspace) ([]Report, error) {
+	contents, idx, err := load(database, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []Report{}
+	for _, content := range contents {
+		broken := []Broken{}
+		for _, link := range Extract(content, workspace) {
+			if reason := idx.resolve(link); reason != "" {
+				broken = append(broken, Broken{Link: link, Reason: reason})
+			}
+		}
+		if len(broken) > 0 {
+			reports = append(reports, Report{
+				ContentID: content.ID,
+				Title:     content.Title,
+				Status:    content.Status,
+				Links:     broken,
+			})
+		}
+	}
+	return reports, nil
+}
+
+// Run checks the links of a workspace and stores the result
+func Run(database *db.DB, workspace models.Workspace) (Result, error) {
+	reports, err := Check(database, workspace)
+	if err != nil {
+		return Result{}, err
+	}
+
+	rows := []models.BrokenLink{}
+	for _, report := range reports {
+		for _, link := range report.Links {
+			rows = append(rows, models.BrokenLink{
+				WorkspaceID: workspace.ID,
+				ContentID:   report.ContentID,
+				Field:       link.Field,
+				URL:         link.URL,
+				Kind:        link.Kind,
+				Reason:      link.Reason,
+			})
+		}
+	}
+
+	now := time.Now()
+	err = db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("workspace_id = ?", workspace.ID).Delete(&models.BrokenLink{}).Error; err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+				return err
+			}
+		}
+
+		var check models.LinkCheck
+		if err := tx.Where("workspace_id = ?", workspace.ID).FirstOrInit(&check).Error; err != nil {
+			return err
+		}
+		check.WorkspaceID = workspace.ID
+		check.CheckedAt = now
+		check.Broken = len(rows)
+		return tx.Save(&check).Error
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{CheckedAt: &now, Broken: len(rows), Reports: reports}, nil
+}
+
+// Stored returns the result of the last check of a workspace. Items deleted
+// since are left out; CheckedAt is nil if the workspace was never checked.
+func Stored(database *db.DB, workspaceID uint) (Result, error) {
+	result := Result{Reports: []Report{}}
+
+	var check models.LinkCheck
+	err := database.Where("workspace_id = ?", workspaceID).Limit(1).Find(&check).Error
+	if err != nil || check.ID == 0 {
+		return result, err
+	}
+	result.CheckedAt = &check.CheckedAt
+
+	var rows []models.BrokenLink
+	if err := database.Where("workspace_id = ?", workspaceID).Order("content_id asc, id asc").Find(&rows).Error; err != nil {
+		return result, err
+	}
+
+	ids := []uint{}
+	for _, row := range rows {
+		ids = append(ids, row.ContentID)
+	}
+	var contents []models.Content
+	if len(ids) > 0 {
+		if err := database.Select("id", "title", "status").Where("id IN ?", ids).Find(&contents).Error; err != nil {
+			return result, err
+		}
+	}
+	byID := make(map[uint]models.Content, len(contents))
+	for _, content := range contents {
+		byID[content.ID] = content
+	}
+
+	for _, row := range rows {
+		content, ok := byID[row.ContentID]
+		if !ok {
+			continue
+		}
+		n := len(result.Reports)
+		if n == 0 || result.Reports[n-1].ContentID != row.ContentID {
+			result.Reports = append(result.Reports, Report{
+				ContentID: content.ID,
+				Title:     content.Title,
+				Status:    content.Status,
+				Links:     []Broken{},
+			})
+			n++
+		}
+		result.Reports[n-1].Links = append(result.Reports[n-1].Links, Broken{
+			Link:   Link{Field: row.Field, URL: row.URL, Kind: row.Kind},
+			Reason: row.Reason,
+		})
+		result.Broken++
+	}
+	return result, nil
+}
+
+// references returns the content items of a workspace with a link to kind and one of targets
+func references(database *db.DB, workspace models.Workspace, kind string, targets map[string]bool, skip uint) ([]Reference, error) {
+	contents, _, err := load(database, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Reference{}
+	for _, content := range contents {
+		if content.ID == skip {
+			continue
+		}
+		for _, link := range Extract(content, workspace) {
+			if link.Kind == kind && targets[link.Target] {
+				found = append(found, Reference{
+					ContentID: content.ID,
+					Title:     content.Title,
+					Field:     link.Field,
+					URL:       link.URL,
+				})
+			}
+		}
+	}
+	return found, nil
+}
+
+// ContentReferences returns the links other content items of its workspace
+// have to a content item. Links are matched by slug, so items sharing the
+// slug are only reported if none of them is published.
+func ContentReferences(database *db.DB, content models.Content) ([]Reference, error) {
+	var workspace models.Workspace
+	if err := database.First(&workspace, content.WorkspaceID).Error; err != nil {
+		return nil, err
+	}
+
+	var others int64
+	if err := database.Model(&models.Content{}).
+		Where("workspace_id = ? AND slug = ? AND status = ? AND id <> ?", content.WorkspaceID, content.Slug, "published", content.ID).
+		Count(&others).Error; err != nil {
+		return nil, err
+	}
+	if others > 0 {
+		return []Reference{}, nil
+	}
+
+	return references(database, workspace, KindContent, map[string]bool{content.Slug: true}, content.ID)
+}
+
+// MediaReferences returns the links content items of its workspace have to a
+// media item or one of its image variants
+func MediaReferences(database *db.DB, media models.Media) ([]Reference, error) {
+	var workspace models.Workspace
+	if err := database.First(&workspace, media.WorkspaceID).Error; err != nil {
+		return nil, err
+	}
+
+	var variantPaths []string
+	if err := database.Model(&models.MediaVariant{}).Where("media_id = ?", media.ID).Pluck("file_path", &variantPaths).Error; err != nil {
+		return nil, err
+	}
+	targets := map[string]bool{media.FilePath: true}
+	for _, path := range variantPaths {
+		targets[path] = true
+	}
+	return references(database, workspace, KindMedia, targets, 0)
+}
+
+// RunAll checks the links of every workspace
+func RunAll(database *db.DB) {
+	var workspaces []models.Workspace
+	if err := database.Find(&workspaces).Error; err != nil {
+		slog.Error("Failed to fetch workspaces for link check", "error", err)
+		return
+	}
+
+	for _, workspace := range workspaces {
+		result, err := Run(database, workspace)
+		if err != nil {
+			slog.Error("Failed to check links", "workspace_id", workspace.ID, "error", err)
+			continue
+		}
+		if result.Broken > 0 {
+			slog.Warn("Found broken links", "workspace_id", workspace.ID, "broken", result.Broken)
+		}
+	}
+}
+
+// RunChecker checks the links of every workspace at each interval until ctx is done
+func RunChecker(ctx context.Context, database *db.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			RunAll(database)
+		}
+	}
+}
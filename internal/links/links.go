@@ -0,0 +1,171 @@
+// internal/links/links.go
+package links
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Kinds of link targets
+const (
+	KindContent = "content"
+	KindMedia   = "media"
+)
+
+// Reasons a link is broken
+const (
+	ReasonMissing     = "missing"
+	ReasonUnpublished = "unpublished"
+)
+
+// uploadsPrefix is the path local media is served under
+const uploadsPrefix = "/uploads/"
+
+var (
+	// attributeLink matches href and src attributes of HTML
+	attributeLink = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*["']([^"']+)["']`)
+	// markdownLink matches the targets of Markdown links and images
+	markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)`)
+	// uploadPath matches media paths written out on their own
+	uploadPath = regexp.MustCompile(`(?:^|[\s"'(>])(/uploads/[^\s"'<>)]+)`)
+)
+
+// Link is an internal link found in a content item
+type Link struct {
+	// Field is "body" or the path of the field value holding the link, such
+	// as "fields.gallery.0"
+	Field string `json:"field"`
+	URL   string `json:"url"`
+	Kind  string `json:"kind"`
+	// Target is the slug of the linked content item or the storage path of
+	// the linked media
+	Target string `json:"-"`
+}
+
+// Extract returns the internal links of a content item's body and field
+// values. Root-relative links and absolute links to the host of the
+// workspace's SEO base URL are internal: those below /uploads/ point at
+// media, and the last segment of any other path is taken as the slug of a
+// content item. Links into the public API of another workspace are left out.
+func Extract(content models.Content, workspace models.Workspace) []Link {
+	found := []Link{}
+	scan(&found, "body", content.Body, workspace)
+
+	keys := make([]string, 0, len(content.Fields))
+	for key := range content.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		scanValue(&found, "fields."+key, content.Fields[key], workspace)
+	}
+	return found
+}
+
+// scanValue scans the strings of a field value, descending into lists and objects
+func scanValue(found *[]Link, field string, value interface{}, workspace models.Workspace) {
+	switch v := value.(type) {
+	case string:
+		scan(found, field, v, workspace)
+	case []interface{}:
+		for i, item := range v {
+			scanValue(found, fmt.Sprintf("%s.%d", field, i), item, workspace)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			scanValue(found, field+"."+key, v[key], workspace)
+		}
+	}
+}
+
+// scan adds the internal links of a text to found, once per URL and field
+func scan(found *[]Link, field, text string, workspace models.Workspace) {
+	if text == "" {
+		return
+	}
+
+	seen := map[string]bool{}
+	add := func(raw string) {
+		if seen[raw] {
+			return
+		}
+		seen[raw] = true
+
+		if link, ok := classify(raw, workspace); ok {
+			link.Field = field
+			*found = append(*found, link)
+		}
+	}
+
+	// A value that is nothing but a URL, as link fields hold
+	if value := strings.TrimSpace(text); !strings.ContainsAny(value, " \t\r\n<>\"'") {
+		add(value)
+	}
+	for _, pattern := range []*regexp.Regexp{attributeLink, markdownLink, uploadPath} {
+		for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+			add(strings.TrimSpace(match[1]))
+		}
+	}
+}
+
+// classify resolves a URL to the content item or media it points at, if it is internal
+func classify(raw string, workspace models.Workspace) (Link, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Link{}, false
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		base, err := url.Parse(workspace.SEO.BaseURL)
+		if err != nil || base.Host == "" || !strings.EqualFold(u.Host, base.Host) {
+			return Link{}, false
+		}
+		if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+			return Link{}, false
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		// Relative links and in-page anchors cannot be resolved without the page URL
+		return Link{}, false
+	}
+
+	path := u.Path
+	if strings.HasPrefix(path, uploadsPrefix) {
+		target := strings.TrimPrefix(path, uploadsPrefix)
+		if target == "" {
+			return Link{}, false
+		}
+		return Link{URL: raw, Kind: KindMedia, Target: target}, true
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if segments[0] == "" {
+		return Link{}, false
+	}
+	if segments[0] == "api" {
+		// Only the public content routes of this workspace point at content
+		if len(segments) < 4 || segments[1] != "content" || segments[2] != workspace.Slug || segments[3] == "tree" {
+			return Link{}, false
+		}
+		if segments[3] == "path" {
+			segments = segments[4:]
+		} else {
+			// /api/content/{workspace}/{slug} and the routes below it
+			segments = segments[3:4]
+		}
+		if len(segments) == 0 {
+			return Link{}, false
+		}
+	}
+
+	return Link{URL: raw, Kind: KindContent, Target: segments[len(segments)-1]}, true
+}
@@ -32,6 +32,15 @@ func SecurityHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// UploadHeaders locks down uploaded files opened directly, so that a file a
+// browser renders as a document cannot run scripts on this origin
+func UploadHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'none'; style-src 'self' 'unsafe-inline'; img-src 'self' data:;")
+		next.ServeHTTP(w, r)
+	})
+}
+
 // AuthMiddleware handles authentication
 func AuthMiddleware(authManager *auth.Manager) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -22,9 +22,13 @@ type User struct {
 	PasswordHash   string          `gorm:"not null" json:"-"`
 	FirstName      string          `json:"first_name"`
 	LastName       string          `json:"last_name"`
+	DisplayName    string          `json:"display_name"`
+	Avatar         string          `json:"avatar"`
+	Bio            string          `gorm:"type:text" json:"bio"`
 	RoleID         uint            `json:"role_id"`
 	Role           Role            `json:"role"`
 	Active         bool            `gorm:"default:true" json:"active"`
+	CalendarToken  string          `gorm:"index:idx_user_calendar_token,length:64" json:"-"`
 	RefreshTokens  []RefreshToken  `json:"-"`
 	UserWorkspaces []UserWorkspace `json:"-"`
 }
@@ -50,6 +54,17 @@ type Workspace struct {
 	Name          string          `gorm:"not null" json:"name"`
 	Slug          string          `gorm:"uniqueIndex:idx_workspace_slug,length:100;not null" json:"slug"`
 	Description   string          `json:"description"`
+	SEO           SEODefaults     `gorm:"type:text;serializer:json" json:"seo"`
+	// CacheControl overrides the Cache-Control policy of public responses
+	CacheControl  string          `json:"cache_control"`
+	// AllowedMediaTypes overrides uploads.allowed_types when not empty
+	AllowedMediaTypes []string    `gorm:"type:text;serializer:json" json:"allowed_media_types"`
+	// StripImageMetadata overrides uploads.strip_metadata when set
+	StripImageMetadata *bool      `json:"strip_image_metadata"`
+	// MediaQuotaSize and MediaQuotaFiles override uploads.quota_size and
+	// uploads.quota_files when set; 0 means unlimited
+	MediaQuotaSize  *int          `json:"media_quota_size"`
+	MediaQuotaFiles *int          `json:"media_quota_files"`
 	UserWorkspaces []UserWorkspace `json:"-"`
 	Contents      []Content       `json:"-"`
 	Media         []Media         `json:"-"`
@@ -100,6 +115,48 @@ type Content struct {
 	Author        User        `json:"author"`
 	PublishedAt   *time.Time  `json:"published_at"`
 	MetaData      string      `gorm:"type:text" json:"meta_data"`
+	Fields        map[string]interface{} `gorm:"type:text;serializer:json" json:"fields"`
+	SEO           SEO         `gorm:"type:text;serializer:json" json:"seo"`
+	ParentID      *uint       `gorm:"index" json:"parent_id"`
+	Position      int         `gorm:"default:0" json:"position"`
+}
+
+// SEO holds the search engine and social sharing metadata of a content item.
+// Empty values fall back to the workspace defaults.
+type SEO struct {
+	MetaTitle       string `json:"meta_title,omitempty"`
+	MetaDescription string `json:"meta_description,omitempty"`
+	CanonicalURL    string `json:"canonical_url,omitempty"`
+	Robots          string `json:"robots,omitempty"`
+	// ImageID references the media item shared on Open Graph and Twitter cards
+	ImageID *uint `json:"image_id,omitempty"`
+}
+
+// SEODefaults holds the SEO settings of a workspace that content items fall back to
+type SEODefaults struct {
+	SiteName string `json:"site_name,omitempty"`
+	// BaseURL is the public site URL canonical URLs are built from
+	BaseURL string `json:"base_url,omitempty"`
+	// TitleTemplate wraps page titles, with %s standing for the title
+	TitleTemplate   string `json:"title_template,omitempty"`
+	MetaDescription string `json:"meta_description,omitempty"`
+	Robots          string `json:"robots,omitempty"`
+	ImageID         *uint  `json:"image_id,omitempty"`
+	TwitterSite     string `json:"twitter_site,omitempty"`
+}
+
+// ContentDraft holds the working changes to a published content item that
+// have not gone live yet
+type ContentDraft struct {
+	BaseModel
+	ContentID uint                   `gorm:"uniqueIndex" json:"content_id"`
+	Title     string                 `json:"title"`
+	Slug      string                 `json:"slug"`
+	Body      string                 `gorm:"type:text" json:"body"`
+	MetaData  string                 `gorm:"type:text" json:"meta_data"`
+	Fields    map[string]interface{} `gorm:"type:text;serializer:json" json:"fields"`
+	SEO       SEO                    `gorm:"type:text;serializer:json" json:"seo"`
+	UpdatedBy uint                   `json:"updated_by"`
 }
 
 // Media represents media files in the system
@@ -112,8 +169,209 @@ type Media struct {
     FilePath    string    `gorm:"not null" json:"file_path"`
     MimeType    string    `json:"mime_type"`
     Size        int64     `json:"size"`
+    // Hash is the hex SHA-256 of the file; media of a workspace with the
+    // same hash share one stored file
+    Hash        string    `gorm:"size:64;index" json:"hash"`
+    // Width and Height are those of the image once turned upright
+    Width       int       `json:"width"`
+    Height      int       `json:"height"`
+    // Orientation is landscape, portrait or square
+    Orientation string    `json:"orientation"`
+    // DominantColor is the most common color of an image, as #rrggbb
+    DominantColor string  `json:"dominant_color"`
+    BlurHash    string    `json:"blur_hash"`
+    // Placeholder is a tiny JPEG of an image as a data URI
+    Placeholder string    `gorm:"type:text" json:"placeholder"`
+    FolderID    *uint     `gorm:"index" json:"folder_id"`
+    AltText     string    `json:"alt_text"`
+    Caption     string    `gorm:"type:text" json:"caption"`
+    Credit      string    `json:"credit"`
+    Copyright   string    `json:"copyright"`
+    // Tags are lowercase and sorted
+    Tags        []string  `gorm:"type:text;serializer:json" json:"tags"`
+    // Private media is only served through signed URLs that expire
+    Private     bool      `gorm:"index" json:"private"`
     UploadedBy  uint      `json:"uploaded_by"`
     User        User      `gorm:"foreignKey:UploadedBy" json:"user"`
+    Variants    []MediaVariant `gorm:"foreignKey:MediaID" json:"variants,omitempty"`
+}
+
+// MediaFolder groups media of a workspace. Folders nest; media without a
+// folder is at the root.
+type MediaFolder struct {
+	BaseModel
+	WorkspaceID uint   `gorm:"index;not null" json:"workspace_id"`
+	ParentID    *uint  `gorm:"index" json:"parent_id"`
+	Name        string `gorm:"not null" json:"name"`
+}
+
+// MediaVariant is a resized copy of an image generated on upload
+type MediaVariant struct {
+	BaseModel
+	MediaID  uint   `gorm:"index" json:"media_id"`
+	Name     string `json:"name"`
+	FilePath string `gorm:"not null;index" json:"file_path"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// MediaVersion is a previous file of a media item, kept when the file is
+// replaced so that it can be restored
+type MediaVersion struct {
+	BaseModel
+	MediaID  uint   `gorm:"index;not null" json:"media_id"`
+	FileName string `json:"file_name"`
+	FilePath string `gorm:"not null;index" json:"file_path"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Hash     string `gorm:"size:64" json:"hash"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	// ReplacedBy is the user who replaced the file with another
+	ReplacedBy uint `json:"replaced_by"`
+}
+
+// StorageUsage is the number and total size of the media files a user has
+// uploaded to a workspace, previous versions included, kept up to date as
+// media is uploaded, replaced and deleted. The row of user 0 holds the total
+// of the workspace.
+type StorageUsage struct {
+	BaseModel
+	WorkspaceID uint  `gorm:"not null;uniqueIndex:idx_storage_usage_key" json:"workspace_id"`
+	UserID      uint  `gorm:"not null;uniqueIndex:idx_storage_usage_key" json:"user_id"`
+	Bytes       int64 `gorm:"not null;default:0" json:"bytes"`
+	Files       int64 `gorm:"not null;default:0" json:"files"`
+}
+
+// Upload is a resumable upload in progress. It is kept after completing,
+// with the media it created, until it expires.
+type Upload struct {
+	BaseModel
+	WorkspaceID uint      `gorm:"index;not null" json:"workspace_id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Name        string    `json:"name"`
+	FileName    string    `json:"file_name"`
+	Private     bool      `json:"private"`
+	Length      int64     `gorm:"not null" json:"length"`
+	Received    int64     `gorm:"not null;default:0" json:"received"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	MediaID     *uint     `json:"media_id"`
+	// Completing is set while a request creates the media of the upload
+	Completing bool `gorm:"not null;default:false" json:"completing"`
+}
+
+// UploadChunk is a stored chunk of a resumable upload, starting at Start
+type UploadChunk struct {
+	BaseModel
+	UploadID uint   `gorm:"index;not null" json:"upload_id"`
+	Start    int64  `gorm:"not null" json:"start"`
+	Size     int64  `gorm:"not null" json:"size"`
+	FilePath string `json:"file_path"`
+}
+
+// RelatedContent holds a precomputed recommendation of one content item for another
+type RelatedContent struct {
+	BaseModel
+	WorkspaceID uint    `gorm:"index" json:"workspace_id"`
+	ContentID   uint    `gorm:"index" json:"content_id"`
+	RelatedID   uint    `json:"related_id"`
+	Score       float64 `json:"score"`
+}
+
+// MediaUsage records that a content item, or its pending draft, uses a media item
+type MediaUsage struct {
+	BaseModel
+	WorkspaceID uint `gorm:"index" json:"workspace_id"`
+	MediaID     uint `gorm:"index" json:"media_id"`
+	ContentID   uint `gorm:"index" json:"content_id"`
+	// Field is where the media is used, such as "body", "fields.hero",
+	// "seo.image_id" or "draft.body"
+	Field string `json:"field"`
+}
+
+// BrokenLink is an internal link of a content item that no longer resolves,
+// as found by the last link check of its workspace
+type BrokenLink struct {
+	BaseModel
+	WorkspaceID uint   `gorm:"index" json:"workspace_id"`
+	ContentID   uint   `gorm:"index" json:"content_id"`
+	Field       string `json:"field"`
+	URL         string `gorm:"type:text" json:"url"`
+	Kind        string `json:"kind"`
+	Reason      string `json:"reason"`
+}
+
+// LinkCheck records when the links of a workspace were last checked
+type LinkCheck struct {
+	BaseModel
+	WorkspaceID uint      `gorm:"uniqueIndex" json:"workspace_id"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Broken      int       `json:"broken"`
+}
+
+// ContentView holds the daily number of public views of a content item for one
+// referrer and user agent class
+type ContentView struct {
+	BaseModel
+	Day           string `gorm:"size:10;not null;uniqueIndex:idx_content_view_key" json:"day"`
+	ContentID     uint   `gorm:"not null;uniqueIndex:idx_content_view_key" json:"content_id"`
+	Referrer      string `gorm:"size:255;not null;default:'';uniqueIndex:idx_content_view_key" json:"referrer"`
+	Agent         string `gorm:"size:16;not null;uniqueIndex:idx_content_view_key" json:"agent"`
+	WorkspaceID   uint   `gorm:"index" json:"workspace_id"`
+	ContentTypeID uint   `gorm:"index" json:"content_type_id"`
+	Views         int64  `gorm:"not null;default:0" json:"views"`
+}
+
+// Release represents a bundle of content changes that go live together
+type Release struct {
+	BaseModel
+	WorkspaceID  uint          `gorm:"index" json:"workspace_id"`
+	Workspace    Workspace     `json:"-"`
+	Name         string        `gorm:"not null" json:"name"`
+	Description  string        `json:"description"`
+	Status       string        `gorm:"default:'draft';index" json:"status"`
+	ScheduledAt  *time.Time    `json:"scheduled_at"`
+	PublishedAt  *time.Time    `json:"published_at"`
+	RolledBackAt *time.Time    `json:"rolled_back_at"`
+	PreviewToken string        `gorm:"uniqueIndex:idx_release_preview_token,length:64" json:"preview_token"`
+	CreatedBy    uint          `json:"created_by"`
+	Error        string        `json:"error"`
+	Items        []ReleaseItem `json:"items"`
+}
+
+// ReleaseItem represents a pending change to a content item within a release
+type ReleaseItem struct {
+	BaseModel
+	ReleaseID uint             `gorm:"index" json:"release_id"`
+	ContentID uint             `gorm:"index" json:"content_id"`
+	Content   Content          `json:"-"`
+	Action    string           `gorm:"not null" json:"action"`
+	Changes   ContentChanges   `gorm:"type:text;serializer:json" json:"changes"`
+	Snapshot  *ContentSnapshot `gorm:"type:text;serializer:json" json:"-"`
+}
+
+// ContentChanges holds the field changes a release applies to a content item
+type ContentChanges struct {
+	Title    *string                `json:"title,omitempty"`
+	Slug     *string                `json:"slug,omitempty"`
+	Body     *string                `json:"body,omitempty"`
+	MetaData *string                `json:"meta_data,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+	SEO      *SEO                   `json:"seo,omitempty"`
+}
+
+// ContentSnapshot holds the state of a content item before a release changed it
+type ContentSnapshot struct {
+	Title       string                 `json:"title"`
+	Slug        string                 `json:"slug"`
+	Body        string                 `json:"body"`
+	Status      string                 `json:"status"`
+	MetaData    string                 `json:"meta_data"`
+	Fields      map[string]interface{} `json:"fields"`
+	SEO         SEO                    `json:"seo"`
+	PublishedAt *time.Time             `json:"published_at"`
 }
 
 // RefreshToken represents a refresh token for a user
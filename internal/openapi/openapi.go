@@ -0,0 +1,350 @@
+// internal/openapi/openapi.go
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/randilt/floe-cms/internal/analytics"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/handlers"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// Param describes a path or query parameter
+type Param struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// Operation describes a single API route
+type Operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Auth        bool
+	Params      []Param
+	Request     interface{}
+	Multipart   Schema
+	Status      int
+	Response    interface{}
+	RawResponse Schema
+	// Unwrapped marks responses that are not wrapped in the Response envelope
+	Unwrapped bool
+	MediaType string
+	// Empty marks responses without a body, described by their headers
+	Empty bool
+}
+
+// pathParam returns a required path parameter
+func pathParam(name, description string) Param {
+	return Param{Name: name, In: "path", Type: "string", Required: true, Description: description}
+}
+
+// headerParam returns a request header
+func headerParam(name string, required bool, description string) Param {
+	return Param{Name: name, In: "header", Type: "string", Required: required, Description: description}
+}
+
+// queryParam returns an optional query parameter
+func queryParam(name, typ, description string) Param {
+	return Param{Name: name, In: "query", Type: typ, Description: description}
+}
+
+// Handler serves the OpenAPI specification
+type Handler struct {
+	db      *db.DB
+	version string
+}
+
+// NewHandler creates a new OpenAPI handler
+func NewHandler(db *db.DB, version string) *Handler {
+	return &Handler{
+		db:      db,
+		version: version,
+	}
+}
+
+// ServeSpec handles serving the OpenAPI document. With ?workspace=<slug> the
+// document also contains generated schemas for the workspace's content types.
+func (h *Handler) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	var contentTypes []models.ContentType
+	if workspace := r.URL.Query().Get("workspace"); workspace != "" {
+		var workspaceObj models.Workspace
+		if err := h.db.Where("slug = ?", workspace).First(&workspaceObj).Error; err != nil {
+			utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+			return
+		}
+		if err := h.db.Where("workspace_id = ?", workspaceObj.ID).Find(&contentTypes).Error; err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch content types")
+			return
+		}
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, Build(h.version, contentTypes))
+}
+
+// Build assembles the OpenAPI document for all operations
+func Build(version string, contentTypes []models.ContentType) map[string]interface{} {
+	reg := &registry{schemas: map[string]Schema{}}
+
+	reg.schemas["Response"] = object(map[string]Schema{
+		"success": {"type": "boolean"},
+		"data":    {},
+		"error":   {"type": "string"},
+	}, "success")
+	reg.schemas["Error"] = object(map[string]Schema{
+		"success": {"type": "boolean", "enum": []bool{false}},
+		"error":   {"type": "string"},
+	}, "success", "error")
+	reg.schemas["Message"] = object(map[string]Schema{
+		"message": {"type": "string"},
+	})
+	for name, schema := range publicSchemas() {
+		reg.schemas[name] = schema
+	}
+	for _, model := range []interface{}{
+		models.Content{}, models.ContentType{}, models.Media{}, models.User{}, models.Workspace{}, models.Release{},
+		handlers.ContentVersions{},
+		analytics.ContentViews{}, analytics.DayViews{}, analytics.ContentTypeViews{}, analytics.ReferrerViews{},
+	} {
+		reg.schemaOf(model)
+	}
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range Operations() {
+		path := op.Path
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(op.Method)] = reg.operation(op)
+	}
+
+	for _, contentType := range contentTypes {
+		addContentTypeSchemas(reg, contentType)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Floe CMS API",
+			"version":     version,
+			"description": "Every response is wrapped in the Response envelope; the data property holds the documented payload.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": reg.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error response",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": ref("Error")},
+					},
+				},
+			},
+		},
+	}
+}
+
+// envelope wraps a payload schema in the Response envelope
+func envelope(data Schema) Schema {
+	return Schema{"allOf": []Schema{
+		ref("Response"),
+		object(map[string]Schema{"data": data}),
+	}}
+}
+
+// operation renders a single OpenAPI operation object
+func (reg *registry) operation(op Operation) map[string]interface{} {
+	out := map[string]interface{}{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": operationID(op),
+	}
+
+	params := []map[string]interface{}{}
+	for _, p := range op.Params {
+		params = append(params, map[string]interface{}{
+			"name":        p.Name,
+			"in":          p.In,
+			"required":    p.Required,
+			"description": p.Description,
+			"schema":      Schema{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": reg.schemaOf(op.Request)},
+			},
+		}
+	}
+	if op.Multipart != nil {
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{"schema": op.Multipart},
+			},
+		}
+	}
+
+	if op.Auth {
+		out["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	data := op.RawResponse
+	if data == nil {
+		data = reg.schemaOf(op.Response)
+	}
+
+	mediaType := op.MediaType
+	if mediaType == "" {
+		mediaType = "application/json"
+	}
+	if !op.Unwrapped {
+		data = envelope(data)
+	}
+
+	success := map[string]interface{}{
+		"description": http.StatusText(status),
+		"content": map[string]interface{}{
+			mediaType: map[string]interface{}{"schema": data},
+		},
+	}
+	if op.Empty {
+		delete(success, "content")
+	}
+
+	errorRef := map[string]interface{}{"$ref": "#/components/responses/Error"}
+	responses := map[string]interface{}{
+		strconv.Itoa(status): success,
+		"400":                errorRef,
+		"404":                errorRef,
+		"500":                errorRef,
+	}
+	if op.Auth {
+		responses["401"] = errorRef
+		responses["403"] = errorRef
+	}
+	out["responses"] = responses
+
+	return out
+}
+
+// operationID derives a stable identifier from the method and path
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, segment := range strings.Split(op.Path, "/") {
+		segment = strings.Trim(segment, "{}")
+		if segment == "" || segment == "api" {
+			continue
+		}
+		b.WriteString(pascalCase(segment))
+	}
+	return b.String()
+}
+
+// pascalCase converts a slug such as blog-post into BlogPost
+func pascalCase(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// fieldSchema maps a content type field definition to a schema
+func fieldSchema(field models.ContentField) Schema {
+	var s Schema
+	switch field.Type {
+	case "text", "textarea", "select", "string", "markdown", "richtext":
+		s = Schema{"type": "string"}
+	case "number":
+		s = Schema{"type": "number"}
+	case "integer":
+		s = Schema{"type": "integer"}
+	case "boolean":
+		s = Schema{"type": "boolean"}
+	case "date":
+		s = Schema{"type": "string", "format": "date"}
+	case "datetime":
+		s = Schema{"type": "string", "format": "date-time"}
+	case "media":
+		s = Schema{"type": "integer", "description": "Media ID"}
+	case "tags":
+		s = Schema{"oneOf": []Schema{arrayOf(Schema{"type": "string"}), {"type": "string", "description": "Comma separated terms"}}}
+	case "reference":
+		s = Schema{"oneOf": []Schema{{"type": "integer"}, arrayOf(Schema{"type": "integer"})}, "description": "Content ID"}
+	default:
+		s = Schema{}
+	}
+
+	if field.Description != "" {
+		s["description"] = field.Description
+	}
+	return s
+}
+
+// addContentTypeSchemas registers the field set and content schema of a content type
+func addContentTypeSchemas(reg *registry, contentType models.ContentType) {
+	name := pascalCase(contentType.Slug)
+	if name == "" {
+		return
+	}
+
+	properties := map[string]Schema{}
+	required := []string{}
+	for _, field := range contentType.Fields {
+		properties[field.Name] = fieldSchema(field)
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	fields := object(properties, required...)
+	if contentType.Description != "" {
+		fields["description"] = contentType.Description
+	}
+	reg.schemas[name+"Fields"] = fields
+
+	reg.schemaOf(models.Content{})
+	reg.schemas[name+"Content"] = Schema{"allOf": []Schema{
+		ref("Content"),
+		object(map[string]Schema{"fields": ref(name + "Fields")}),
+	}}
+}
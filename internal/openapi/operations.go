@@ -0,0 +1,418 @@
+// internal/openapi/operations.go
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/randilt/floe-cms/internal/handlers"
+	"github.com/randilt/floe-cms/internal/links"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/seo"
+	"github.com/randilt/floe-cms/internal/usage"
+)
+
+// listOf returns the paginated list payload used by the list endpoints
+func listOf(key string, items Schema) Schema {
+	return object(map[string]Schema{
+		key:      arrayOf(items),
+		"total":  {"type": "integer"},
+		"limit":  {"type": "integer"},
+		"offset": {"type": "integer"},
+	})
+}
+
+// reportOf returns the payload of the analytics reports
+func reportOf(item string) Schema {
+	return object(map[string]Schema{
+		"from":  {"type": "string", "format": "date"},
+		"to":    {"type": "string", "format": "date"},
+		"items": arrayOf(ref(item)),
+	})
+}
+
+// publicSchemas returns the hand written schemas of the public delivery representations
+func publicSchemas() map[string]Schema {
+	id := Schema{"type": "integer", "description": "Omitted when delivery.strip_internal_ids is enabled"}
+	timestamp := Schema{"type": "string", "format": "date-time"}
+	variant := object(map[string]Schema{
+		"name":      {"type": "string"},
+		"url":       {"type": "string"},
+		"mime_type": {"type": "string"},
+		"width":     {"type": "integer"},
+		"height":    {"type": "integer"},
+	})
+
+	return map[string]Schema{
+		"PublicAuthor": object(map[string]Schema{
+			"id":           id,
+			"display_name": {"type": "string"},
+			"first_name":   {"type": "string"},
+			"last_name":    {"type": "string"},
+			"avatar":       {"type": "string"},
+			"bio":          {"type": "string"},
+		}),
+		"PublicContentType": object(map[string]Schema{
+			"id":          id,
+			"name":        {"type": "string"},
+			"slug":        {"type": "string"},
+			"description": {"type": "string"},
+			"fields":      arrayOf(ref("ContentField")),
+		}),
+		"PublicContent": object(map[string]Schema{
+			"id":              id,
+			"workspace_id":    id,
+			"content_type_id": id,
+			"author_id":       id,
+			"parent_id":       nullable(id),
+			"title":           {"type": "string"},
+			"slug":            {"type": "string"},
+			"body":            {"type": "string"},
+			"status":          {"type": "string"},
+			"published_at":    nullable(timestamp),
+			"meta_data":       {"type": "string"},
+			"fields":          {"type": "object", "additionalProperties": Schema{}},
+			"position":        {"type": "integer"},
+			"created_at":      timestamp,
+			"updated_at":      timestamp,
+			"author":          ref("PublicAuthor"),
+			"content_type":    ref("PublicContentType"),
+		}),
+		"PublicMedia": object(map[string]Schema{
+			"id":             id,
+			"name":           {"type": "string"},
+			"file_name":      {"type": "string"},
+			"url":            {"type": "string"},
+			"mime_type":      {"type": "string"},
+			"size":           {"type": "integer"},
+			"width":          {"type": "integer"},
+			"height":         {"type": "integer"},
+			"orientation":    {"type": "string", "enum": []string{"landscape", "portrait", "square"}},
+			"dominant_color": {"type": "string"},
+			"blur_hash":      {"type": "string"},
+			"placeholder":    {"type": "string"},
+			"alt_text":       {"type": "string"},
+			"caption":        {"type": "string"},
+			"credit":         {"type": "string"},
+			"copyright":      {"type": "string"},
+			"variants":       arrayOf(variant),
+			"created_at":     timestamp,
+		}),
+		"PublicBreadcrumb": object(map[string]Schema{
+			"id":    id,
+			"title": {"type": "string"},
+			"slug":  {"type": "string"},
+			"path":  {"type": "string"},
+		}),
+		"PublicTreeNode": object(map[string]Schema{
+			"id":              id,
+			"parent_id":       nullable(id),
+			"content_type_id": id,
+			"title":           {"type": "string"},
+			"slug":            {"type": "string"},
+			"path":            {"type": "string"},
+			"position":        {"type": "integer"},
+			"children":        arrayOf(ref("PublicTreeNode")),
+		}),
+	}
+}
+
+// Operations returns every route served by the API router
+func Operations() []Operation {
+	workspaceSlug := pathParam("workspace", "Workspace slug")
+	workspaceID := pathParam("workspaceId", "Workspace ID")
+	id := pathParam("id", "Record ID")
+	versionID := pathParam("versionId", "Media version ID")
+	limit := queryParam("limit", "integer", "Page size (default 10)")
+	offset := queryParam("offset", "integer", "Number of items to skip")
+	fields := queryParam("fields", "string", "Comma separated attributes to return, fields.<key> picks a custom field value")
+	include := queryParam("include", "string", "Comma separated relations to expand: author, content_type. Admin endpoints expand both by default")
+	tusResumable := headerParam("Tus-Resumable", true, "Protocol version, 1.0.0")
+
+	contentList := listOf("contents", ref("Content"))
+	publicContentList := listOf("contents", ref("PublicContent"))
+	message := ref("Message")
+	previewToken := pathParam("token", "Release preview token")
+	reportParams := []Param{id,
+		queryParam("from", "string", "First day (YYYY-MM-DD, default 29 days ago)"),
+		queryParam("to", "string", "Last day (YYYY-MM-DD, default today)"),
+		queryParam("content_id", "integer", "Limit to one content item"),
+		queryParam("content_type_id", "integer", "Limit to one content type"),
+		queryParam("agent", "string", "desktop, mobile, tablet, bot, other or all (default excludes bots)"),
+	}
+	calendarFrom := queryParam("from", "string", "Start date (YYYY-MM-DD) or RFC 3339 time")
+	calendarTo := queryParam("to", "string", "End date (inclusive, YYYY-MM-DD) or RFC 3339 time")
+
+	return []Operation{
+		// System
+		{Method: http.MethodGet, Path: "/api/health", Tag: "System", Summary: "Health check",
+			RawResponse: Schema{"type": "string"}, Unwrapped: true, MediaType: "text/plain"},
+		{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "System", Summary: "OpenAPI specification",
+			Params:      []Param{queryParam("workspace", "string", "Workspace slug whose content type schemas are added")},
+			RawResponse: Schema{"type": "object"}, Unwrapped: true},
+
+		// Authentication
+		{Method: http.MethodPost, Path: "/api/auth/login", Tag: "Auth", Summary: "Log in", Request: handlers.LoginRequest{}, Response: handlers.LoginResponse{}},
+		{Method: http.MethodPost, Path: "/api/auth/refresh", Tag: "Auth", Summary: "Refresh an access token", Request: handlers.RefreshTokenRequest{}, Response: handlers.RefreshTokenResponse{}},
+		{Method: http.MethodPost, Path: "/api/auth/logout", Tag: "Auth", Summary: "Log out", Auth: true, Request: handlers.LogoutRequest{}, RawResponse: message},
+
+		// Public delivery
+		{Method: http.MethodGet, Path: "/api/content/{workspace}", Tag: "Delivery", Summary: "List published content",
+			Params:      []Param{workspaceSlug, limit, offset, queryParam("content_type_id", "integer", "Filter by content type"), fields, include},
+			RawResponse: publicContentList},
+		{Method: http.MethodGet, Path: "/api/content/{workspace}/tree", Tag: "Delivery", Summary: "Published page hierarchy",
+			Params: []Param{workspaceSlug}, RawResponse: arrayOf(ref("PublicTreeNode"))},
+		{Method: http.MethodGet, Path: "/api/content/{workspace}/path/{path}", Tag: "Delivery", Summary: "Resolve a nested page path",
+			Params: []Param{workspaceSlug, pathParam("path", "Slash separated page slugs, e.g. docs/install/linux"), fields, include},
+			RawResponse: object(map[string]Schema{
+				"content":     ref("PublicContent"),
+				"breadcrumbs": arrayOf(ref("PublicBreadcrumb")),
+			})},
+		{Method: http.MethodGet, Path: "/api/content/{workspace}/{slug}", Tag: "Delivery", Summary: "Get published content by slug",
+			Params: []Param{workspaceSlug, pathParam("slug", "Content slug"), fields, include}, RawResponse: ref("PublicContent")},
+		{Method: http.MethodGet, Path: "/api/content/{workspace}/{slug}/related", Tag: "Delivery", Summary: "Get published content related to a content item",
+			Params: []Param{workspaceSlug, pathParam("slug", "Content slug"), queryParam("limit", "integer", "Number of items to return (default 5, max 20)")},
+			RawResponse: object(map[string]Schema{"contents": arrayOf(ref("PublicContent"))})},
+		{Method: http.MethodGet, Path: "/api/content/{workspace}/{slug}/head", Tag: "SEO", Summary: "Get the head tags of published content",
+			Params: []Param{workspaceSlug, pathParam("slug", "Content slug")}, Response: handlers.HeadResponse{}},
+		{Method: http.MethodGet, Path: "/api/preview/releases/{token}", Tag: "Delivery", Summary: "Preview published content with a release applied",
+			Params: []Param{previewToken},
+			RawResponse: object(map[string]Schema{
+				"release":  {"type": "string"},
+				"contents": arrayOf(ref("PublicContent")),
+				"total":    {"type": "integer"},
+			})},
+		{Method: http.MethodGet, Path: "/api/preview/releases/{token}/{slug}", Tag: "Delivery", Summary: "Preview a content item with a release applied",
+			Params: []Param{previewToken, pathParam("slug", "Content slug")}, RawResponse: ref("PublicContent")},
+		{Method: http.MethodGet, Path: "/api/calendar/{token}.ics", Tag: "Calendar", Summary: "Editorial calendar feed of a user",
+			Params: []Param{pathParam("token", "Secret calendar token of the user"),
+				queryParam("workspace_id", "integer", "Limit the feed to one workspace"), calendarFrom, calendarTo},
+			RawResponse: Schema{"type": "string"}, Unwrapped: true, MediaType: "text/calendar"},
+		{Method: http.MethodGet, Path: "/uploads/{path}", Tag: "Delivery", Summary: "Download an uploaded file",
+			Params: []Param{pathParam("path", "File path returned by the media API"),
+				queryParam("expires", "integer", "Unix time a signed URL of private media expires at"),
+				queryParam("signature", "string", "Signature of a private media URL, as returned by the media API")},
+			RawResponse: Schema{"type": "string", "format": "binary"}, Unwrapped: true, MediaType: "application/octet-stream"},
+
+		// Content
+		{Method: http.MethodPost, Path: "/api/content", Tag: "Content", Summary: "Create content", Auth: true,
+			Request: handlers.CreateContentRequest{}, Status: http.StatusCreated, Response: models.Content{}},
+		{Method: http.MethodGet, Path: "/api/content", Tag: "Content", Summary: "List content", Auth: true,
+			Params: []Param{queryParam("workspace_id", "integer", "Filter by workspace"), queryParam("status", "string", "Filter by status"),
+				queryParam("content_type_id", "integer", "Filter by content type"), limit, offset, fields, include},
+			RawResponse: contentList},
+		{Method: http.MethodPost, Path: "/api/workspaces/{workspaceId}/content", Tag: "Content", Summary: "Create content", Auth: true,
+			Params: []Param{workspaceID}, Request: handlers.CreateContentRequest{}, Status: http.StatusCreated, Response: models.Content{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/content", Tag: "Content", Summary: "List content", Auth: true,
+			Params: []Param{workspaceID, queryParam("workspace_id", "integer", "Filter by workspace"), queryParam("status", "string", "Filter by status"),
+				queryParam("content_type_id", "integer", "Filter by content type"), limit, offset, fields, include},
+			RawResponse: contentList},
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/content/tree", Tag: "Content", Summary: "Page hierarchy", Auth: true,
+			Params: []Param{workspaceID, queryParam("status", "string", "Filter by status")}, Response: []*handlers.ContentTreeNode{}},
+		{Method: http.MethodPut, Path: "/api/workspaces/{workspaceId}/content/reorder", Tag: "Content", Summary: "Reorder sibling pages", Auth: true,
+			Params: []Param{workspaceID}, Request: handlers.ReorderContentRequest{}, RawResponse: message},
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/content/{id}", Tag: "Content", Summary: "Get content (also served at /api/content/{id})", Auth: true,
+			Params: []Param{workspaceID, id, fields, include}, Response: models.Content{}},
+		{Method: http.MethodPut, Path: "/api/workspaces/{workspaceId}/content/{id}", Tag: "Content", Summary: "Update content (also served at /api/content/{id})", Auth: true,
+			Params: []Param{workspaceID, id}, Request: handlers.UpdateContentRequest{}, Response: models.Content{}},
+		{Method: http.MethodDelete, Path: "/api/workspaces/{workspaceId}/content/{id}", Tag: "Content", Summary: "Delete content (also served at /api/content/{id})", Auth: true,
+			Params: []Param{workspaceID, id}, Response: handlers.DeleteResponse{}},
+		{Method: http.MethodPost, Path: "/api/workspaces/{workspaceId}/content/{id}/move", Tag: "Content", Summary: "Move content in the hierarchy", Auth: true,
+			Params: []Param{workspaceID, id}, Request: handlers.MoveContentRequest{}, Response: models.Content{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/content/{id}/draft", Tag: "Content", Summary: "Get the live and working versions", Auth: true,
+			Params: []Param{workspaceID, id}, Response: handlers.ContentVersions{}},
+		{Method: http.MethodPost, Path: "/api/workspaces/{workspaceId}/content/{id}/draft/publish", Tag: "Content", Summary: "Publish the working draft", Auth: true,
+			Params: []Param{workspaceID, id}, Response: models.Content{}},
+		{Method: http.MethodDelete, Path: "/api/workspaces/{workspaceId}/content/{id}/draft", Tag: "Content", Summary: "Discard the working draft", Auth: true,
+			Params: []Param{workspaceID, id}, RawResponse: message},
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/content/{id}/seo", Tag: "SEO", Summary: "Get resolved SEO metadata and warnings", Auth: true,
+			Params: []Param{workspaceID, id}, Response: handlers.SEOReport{}},
+
+		// Content types
+		{Method: http.MethodPost, Path: "/api/content-types", Tag: "Content types", Summary: "Create a content type", Auth: true,
+			Request: handlers.CreateContentTypeRequest{}, Status: http.StatusCreated, Response: models.ContentType{}},
+		{Method: http.MethodGet, Path: "/api/content-types", Tag: "Content types", Summary: "List content types", Auth: true,
+			Params: []Param{{Name: "workspace_id", In: "query", Type: "integer", Required: true, Description: "Workspace ID"}}, Response: []models.ContentType{}},
+		{Method: http.MethodGet, Path: "/api/content-types/{id}", Tag: "Content types", Summary: "Get a content type", Auth: true,
+			Params: []Param{id}, Response: models.ContentType{}},
+		{Method: http.MethodPut, Path: "/api/content-types/{id}", Tag: "Content types", Summary: "Update a content type", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateContentTypeRequest{}, Response: models.ContentType{}},
+		{Method: http.MethodDelete, Path: "/api/content-types/{id}", Tag: "Content types", Summary: "Delete a content type", Auth: true,
+			Params: []Param{id}, RawResponse: message},
+
+		// Media
+		{Method: http.MethodPost, Path: "/api/media", Tag: "Media", Summary: "Upload media", Auth: true,
+			Multipart: object(map[string]Schema{
+				"workspace_id": {"type": "integer"},
+				"name":         {"type": "string"},
+				"private":      {"type": "boolean"},
+				"file":         {"type": "string", "format": "binary"},
+			}, "workspace_id", "file"),
+			Status: http.StatusCreated, Response: models.Media{}},
+		{Method: http.MethodOptions, Path: "/api/media/uploads", Tag: "Media", Summary: "Discover resumable upload (tus 1.0) support", Auth: true,
+			Status: http.StatusNoContent, Empty: true},
+		{Method: http.MethodPost, Path: "/api/media/uploads", Tag: "Media", Summary: "Start a resumable upload; its URL is returned in Location", Auth: true,
+			Params: []Param{tusResumable,
+				headerParam("Upload-Length", true, "Size of the file in bytes"),
+				headerParam("Upload-Metadata", true, "Comma-separated keys and base64 values: workspace_id and filename, optionally name and private")},
+			Status: http.StatusCreated, Empty: true},
+		{Method: http.MethodHead, Path: "/api/media/uploads/{id}", Tag: "Media", Summary: "Get the Upload-Offset to resume an upload from", Auth: true,
+			Params: []Param{id, tusResumable}, Empty: true},
+		{Method: http.MethodPatch, Path: "/api/media/uploads/{id}", Tag: "Media", Summary: "Send a chunk of an upload; the last one returns Floe-Media-ID", Auth: true,
+			Params: []Param{id, tusResumable, headerParam("Upload-Offset", true, "Offset the chunk starts at, as returned by HEAD")},
+			Status: http.StatusNoContent, Empty: true},
+		{Method: http.MethodDelete, Path: "/api/media/uploads/{id}", Tag: "Media", Summary: "Cancel a resumable upload", Auth: true,
+			Params: []Param{id, tusResumable}, Status: http.StatusNoContent, Empty: true},
+		{Method: http.MethodGet, Path: "/api/media", Tag: "Media", Summary: "List media", Auth: true,
+			Params: []Param{{Name: "workspace_id", In: "query", Type: "integer", Required: true, Description: "Workspace ID"}, limit, offset,
+				queryParam("folder_id", "string", "Folder ID, or root for media outside folders"),
+				queryParam("subfolders", "boolean", "Include media of the folder's subfolders"),
+				queryParam("tag", "string", "Comma separated tags the media must all have"),
+				queryParam("type", "string", "MIME type family such as image or video, or a full MIME type"),
+				queryParam("visibility", "string", "public or private"),
+				queryParam("uploaded_by", "integer", "ID of the uploader"),
+				queryParam("q", "string", "Search name, file name, alt text and caption")},
+			RawResponse: listOf("media", ref("Media"))},
+		{Method: http.MethodGet, Path: "/api/media/tags", Tag: "Media", Summary: "Tags of a workspace's media, most used first", Auth: true,
+			Params: []Param{{Name: "workspace_id", In: "query", Type: "integer", Required: true, Description: "Workspace ID"}}, Response: []handlers.MediaTagCount{}},
+		{Method: http.MethodPost, Path: "/api/media/move", Tag: "Media", Summary: "Move media items into a folder, or to the root", Auth: true,
+			Request: handlers.MoveMediaRequest{}, Response: handlers.MoveMediaResponse{}},
+		{Method: http.MethodGet, Path: "/api/media/folders", Tag: "Media", Summary: "Folder tree of a workspace", Auth: true,
+			Params: []Param{{Name: "workspace_id", In: "query", Type: "integer", Required: true, Description: "Workspace ID"}}, Response: []handlers.MediaFolderNode{}},
+		{Method: http.MethodPost, Path: "/api/media/folders", Tag: "Media", Summary: "Create a media folder (editor or admin)", Auth: true,
+			Request: handlers.CreateMediaFolderRequest{}, Status: http.StatusCreated, Response: models.MediaFolder{}},
+		{Method: http.MethodPut, Path: "/api/media/folders/{id}", Tag: "Media", Summary: "Rename a media folder (editor or admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateMediaFolderRequest{}, Response: models.MediaFolder{}},
+		{Method: http.MethodPost, Path: "/api/media/folders/{id}/move", Tag: "Media", Summary: "Move a media folder under another one (editor or admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.MoveMediaFolderRequest{}, Response: models.MediaFolder{}},
+		{Method: http.MethodDelete, Path: "/api/media/folders/{id}", Tag: "Media", Summary: "Delete an empty media folder (editor or admin)", Auth: true,
+			Params: []Param{id}, RawResponse: message},
+		{Method: http.MethodGet, Path: "/api/media/{id}", Tag: "Media", Summary: "Get media", Auth: true,
+			Params: []Param{id}, Response: models.Media{}},
+		{Method: http.MethodPut, Path: "/api/media/{id}", Tag: "Media", Summary: "Update the details and tags of a media item", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateMediaRequest{}, Response: models.Media{}},
+		{Method: http.MethodDelete, Path: "/api/media/{id}", Tag: "Media", Summary: "Delete media; media in use needs force=true", Auth: true,
+			Params: []Param{id, queryParam("force", "boolean", "Delete even if content uses the media")}, Response: handlers.DeleteResponse{}},
+		{Method: http.MethodGet, Path: "/api/media/{id}/transform", Tag: "Media", Summary: "Sign an on-the-fly image transform URL", Auth: true,
+			Params: []Param{id,
+				queryParam("w", "integer", "Width in pixels"),
+				queryParam("h", "integer", "Height in pixels"),
+				queryParam("fit", "string", "contain (default), cover or fill"),
+				queryParam("fmt", "string", "jpeg, png or webp (default: the original format)")},
+			Response: handlers.TransformURLResponse{}},
+		{Method: http.MethodGet, Path: "/api/media/{id}/usages", Tag: "Media", Summary: "Content items using a media item", Auth: true,
+			Params: []Param{id}, Response: []usage.Usage{}},
+		{Method: http.MethodPost, Path: "/api/media/{id}/replace", Tag: "Media", Summary: "Repoint every usage at another media item (editor or admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.ReplaceMediaRequest{}, Response: handlers.ReplaceMediaResponse{}},
+		{Method: http.MethodPut, Path: "/api/media/{id}/file", Tag: "Media", Summary: "Replace the file of a media item, keeping the previous one as a version", Auth: true,
+			Params: []Param{id},
+			Multipart: object(map[string]Schema{
+				"file": {"type": "string", "format": "binary"},
+			}, "file"),
+			Response: models.Media{}},
+		{Method: http.MethodGet, Path: "/api/media/{id}/versions", Tag: "Media", Summary: "Previous files of a media item, newest first", Auth: true,
+			Params: []Param{id}, Response: []models.MediaVersion{}},
+		{Method: http.MethodPost, Path: "/api/media/{id}/versions/{versionId}/restore", Tag: "Media", Summary: "Make a previous file current again", Auth: true,
+			Params: []Param{id, versionID}, Response: models.Media{}},
+		{Method: http.MethodDelete, Path: "/api/media/{id}/versions/{versionId}", Tag: "Media", Summary: "Delete a previous file of a media item", Auth: true,
+			Params: []Param{id, versionID}, RawResponse: message},
+
+		// Editorial calendar
+		{Method: http.MethodGet, Path: "/api/workspaces/{workspaceId}/calendar", Tag: "Calendar", Summary: "Editorial calendar grouped by day", Auth: true,
+			Params:   []Param{workspaceID, calendarFrom, calendarTo, queryParam("tz", "string", "IANA timezone used to group days (default UTC)")},
+			Response: handlers.CalendarResponse{}},
+		{Method: http.MethodGet, Path: "/api/me/calendar", Tag: "Calendar", Summary: "Get the secret calendar feed URL", Auth: true,
+			Response: handlers.CalendarFeedResponse{}},
+		{Method: http.MethodPost, Path: "/api/me/calendar/rotate", Tag: "Calendar", Summary: "Replace the secret calendar feed URL", Auth: true,
+			Response: handlers.CalendarFeedResponse{}},
+
+		// Releases
+		{Method: http.MethodPost, Path: "/api/releases", Tag: "Releases", Summary: "Create a release", Auth: true,
+			Request: handlers.CreateReleaseRequest{}, Status: http.StatusCreated, Response: models.Release{}},
+		{Method: http.MethodGet, Path: "/api/releases", Tag: "Releases", Summary: "List releases", Auth: true,
+			Params:   []Param{{Name: "workspace_id", In: "query", Type: "integer", Required: true, Description: "Workspace ID"}, queryParam("status", "string", "Filter by status")},
+			Response: []models.Release{}},
+		{Method: http.MethodGet, Path: "/api/releases/{id}", Tag: "Releases", Summary: "Get a release", Auth: true,
+			Params: []Param{id}, Response: models.Release{}},
+		{Method: http.MethodPut, Path: "/api/releases/{id}", Tag: "Releases", Summary: "Update a release", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateReleaseRequest{}, Response: models.Release{}},
+		{Method: http.MethodDelete, Path: "/api/releases/{id}", Tag: "Releases", Summary: "Delete an unpublished release", Auth: true,
+			Params: []Param{id}, RawResponse: message},
+		{Method: http.MethodPost, Path: "/api/releases/{id}/items", Tag: "Releases", Summary: "Add or replace a content change", Auth: true,
+			Params: []Param{id}, Request: handlers.ReleaseItemRequest{}, Status: http.StatusCreated, Response: models.ReleaseItem{}},
+		{Method: http.MethodDelete, Path: "/api/releases/{id}/items/{itemId}", Tag: "Releases", Summary: "Remove a content change", Auth: true,
+			Params: []Param{id, pathParam("itemId", "Release item ID")}, RawResponse: message},
+		{Method: http.MethodPost, Path: "/api/releases/{id}/publish", Tag: "Releases", Summary: "Publish a release now", Auth: true,
+			Params: []Param{id}, Response: models.Release{}},
+		{Method: http.MethodPost, Path: "/api/releases/{id}/schedule", Tag: "Releases", Summary: "Schedule or unschedule a release", Auth: true,
+			Params: []Param{id}, Request: handlers.ScheduleReleaseRequest{}, Response: models.Release{}},
+		{Method: http.MethodPost, Path: "/api/releases/{id}/rollback", Tag: "Releases", Summary: "Roll back a published release", Auth: true,
+			Params: []Param{id}, Response: models.Release{}},
+		{Method: http.MethodGet, Path: "/api/releases/{id}/preview", Tag: "Releases", Summary: "Preview content with a release applied", Auth: true,
+			Params: []Param{id},
+			RawResponse: object(map[string]Schema{
+				"release":  ref("Release"),
+				"contents": arrayOf(ref("Content")),
+			})},
+
+		// Workspaces
+		{Method: http.MethodPost, Path: "/api/workspaces", Tag: "Workspaces", Summary: "Create a workspace (admin)", Auth: true,
+			Request: handlers.CreateWorkspaceRequest{}, Status: http.StatusCreated, Response: models.Workspace{}},
+		{Method: http.MethodGet, Path: "/api/workspaces", Tag: "Workspaces", Summary: "List workspaces (admin)", Auth: true,
+			Response: []models.Workspace{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/storage", Tag: "Workspaces", Summary: "Media storage usage and quotas of every workspace (admin)", Auth: true,
+			Response: []handlers.WorkspaceStorage{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}", Tag: "Workspaces", Summary: "Get a workspace (admin)", Auth: true,
+			Params: []Param{id}, Response: models.Workspace{}},
+		{Method: http.MethodPut, Path: "/api/workspaces/{id}", Tag: "Workspaces", Summary: "Update a workspace (admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateWorkspaceRequest{}, Response: models.Workspace{}},
+		{Method: http.MethodDelete, Path: "/api/workspaces/{id}", Tag: "Workspaces", Summary: "Delete a workspace (admin)", Auth: true,
+			Params: []Param{id}, RawResponse: message},
+		{Method: http.MethodPost, Path: "/api/workspaces/{id}/users", Tag: "Workspaces", Summary: "Add a user to a workspace (admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.AddUserToWorkspaceRequest{}, Status: http.StatusCreated, RawResponse: message},
+		{Method: http.MethodDelete, Path: "/api/workspaces/{id}/users/{userId}", Tag: "Workspaces", Summary: "Remove a user from a workspace (admin)", Auth: true,
+			Params: []Param{id, pathParam("userId", "User ID")}, RawResponse: message},
+		{Method: http.MethodPost, Path: "/api/workspaces/{id}/export", Tag: "Workspaces", Summary: "Start a static export (admin)", Auth: true,
+			Params: []Param{id}, Status: http.StatusAccepted, Response: handlers.ExportJob{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/export", Tag: "Workspaces", Summary: "Get the latest static export (admin)", Auth: true,
+			Params: []Param{id}, Response: handlers.ExportJob{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/storage", Tag: "Workspaces", Summary: "Media storage usage of a workspace and its uploaders (admin)", Auth: true,
+			Params: []Param{id}, Response: handlers.WorkspaceStorage{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/analytics/top", Tag: "Analytics", Summary: "Most viewed content (admin)", Auth: true,
+			Params: append(reportParams, queryParam("limit", "integer", "Number of items (default 10, max 100)")), RawResponse: reportOf("ContentViews")},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/analytics/trends", Tag: "Analytics", Summary: "Views per day (admin)", Auth: true,
+			Params: reportParams, RawResponse: reportOf("DayViews")},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/analytics/content-types", Tag: "Analytics", Summary: "Views per content type (admin)", Auth: true,
+			Params: reportParams, RawResponse: reportOf("ContentTypeViews")},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/analytics/referrers", Tag: "Analytics", Summary: "Top referring hosts (admin)", Auth: true,
+			Params: append(reportParams, queryParam("limit", "integer", "Number of items (default 10, max 100)")), RawResponse: reportOf("ReferrerViews")},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/seo/report", Tag: "SEO", Summary: "SEO warnings of every content item (admin)", Auth: true,
+			Params: []Param{id}, Response: []seo.Report{}},
+		{Method: http.MethodGet, Path: "/api/workspaces/{id}/links", Tag: "Links", Summary: "Broken links found by the last check (admin)", Auth: true,
+			Params: []Param{id}, Response: links.Result{}},
+		{Method: http.MethodPost, Path: "/api/workspaces/{id}/links/check", Tag: "Links", Summary: "Check the links of a workspace now (admin)", Auth: true,
+			Params: []Param{id}, Response: links.Result{}},
+
+		// Users
+		{Method: http.MethodPost, Path: "/api/users", Tag: "Users", Summary: "Create a user (admin)", Auth: true,
+			Request: handlers.CreateUserRequest{}, Status: http.StatusCreated, Response: models.User{}},
+		{Method: http.MethodGet, Path: "/api/users", Tag: "Users", Summary: "List users (admin)", Auth: true,
+			Params: []Param{queryParam("role_id", "integer", "Filter by role"), limit, offset}, RawResponse: listOf("users", ref("User"))},
+		{Method: http.MethodGet, Path: "/api/users/{id}", Tag: "Users", Summary: "Get a user (admin)", Auth: true,
+			Params: []Param{id}, Response: models.User{}},
+		{Method: http.MethodPut, Path: "/api/users/{id}", Tag: "Users", Summary: "Update a user (admin)", Auth: true,
+			Params: []Param{id}, Request: handlers.UpdateUserRequest{}, Response: models.User{}},
+		{Method: http.MethodDelete, Path: "/api/users/{id}", Tag: "Users", Summary: "Delete a user (admin)", Auth: true,
+			Params: []Param{id}, RawResponse: message},
+
+		// Current user
+		{Method: http.MethodGet, Path: "/api/me", Tag: "Profile", Summary: "Get the current user", Auth: true,
+			RawResponse: object(map[string]Schema{
+				"user":       ref("User"),
+				"workspaces": arrayOf(ref("Workspace")),
+			})},
+		{Method: http.MethodPut, Path: "/api/me", Tag: "Profile", Summary: "Update the current user", Auth: true,
+			Request: handlers.UpdateCurrentUserRequest{}, Response: models.User{}},
+		{Method: http.MethodPut, Path: "/api/me/password", Tag: "Profile", Summary: "Change the current user's password", Auth: true,
+			Request: handlers.ChangePasswordRequest{}, RawResponse: message},
+	}
+}
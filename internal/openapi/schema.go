@@ -0,0 +1,147 @@
+// internal/openapi/schema.go
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object
+type Schema map[string]interface{}
+
+// timeType is used to render time.Time as a date-time string
+var timeType = reflect.TypeOf(time.Time{})
+
+// ref returns a reference to a component schema
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// arrayOf returns an array schema of the given items
+func arrayOf(items Schema) Schema {
+	return Schema{"type": "array", "items": items}
+}
+
+// object returns an object schema with the given properties
+func object(properties map[string]Schema, required ...string) Schema {
+	s := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// nullable marks a schema as nullable
+func nullable(s Schema) Schema {
+	if _, isRef := s["$ref"]; isRef {
+		return Schema{"allOf": []Schema{s}, "nullable": true}
+	}
+	out := Schema{}
+	for k, v := range s {
+		out[k] = v
+	}
+	out["nullable"] = true
+	return out
+}
+
+// registry collects component schemas generated from Go types
+type registry struct {
+	schemas map[string]Schema
+}
+
+// schemaOf returns the schema for the type of v, registering named structs as components
+func (reg *registry) schemaOf(v interface{}) Schema {
+	return reg.schemaFor(reflect.TypeOf(v))
+}
+
+// schemaFor returns the schema for a Go type
+func (reg *registry) schemaFor(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return nullable(reg.schemaFor(t.Elem()))
+	}
+
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return Schema{"type": "integer", "format": "int32"}
+	case reflect.Int64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return arrayOf(reg.schemaFor(t.Elem()))
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": reg.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return reg.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := reg.schemas[name]; !ok {
+			// Register a placeholder first so recursive types terminate
+			reg.schemas[name] = Schema{}
+			reg.schemas[name] = reg.structSchema(t)
+		}
+		return ref(name)
+	}
+
+	return Schema{}
+}
+
+// structSchema builds an object schema from the JSON tags of a struct
+func (reg *registry) structSchema(t reflect.Type) Schema {
+	properties := map[string]Schema{}
+	reg.collectFields(t, properties)
+	return object(properties)
+}
+
+// collectFields adds the JSON-visible fields of t, flattening embedded structs
+func (reg *registry) collectFields(t reflect.Type, properties map[string]Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				reg.collectFields(embedded, properties)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = reg.schemaFor(field.Type)
+	}
+}
@@ -0,0 +1,252 @@
+// internal/quota/quota.go
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+const mib = 1 << 20
+
+// ErrExceeded is returned when media would take a workspace over its quota
+var ErrExceeded = errors.New("workspace storage quota exceeded")
+
+// Limits are the media quotas of a workspace; zero means unlimited
+type Limits struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// Of returns the media quotas of a workspace: its own where it sets them, and
+// the configured ones otherwise
+func Of(cfg config.UploadsConfig, workspace models.Workspace) Limits {
+	size, files := cfg.QuotaSize, cfg.QuotaFiles
+	if workspace.MediaQuotaSize != nil {
+		size = *workspace.MediaQuotaSize
+	}
+	if workspace.MediaQuotaFiles != nil {
+		files = *workspace.MediaQuotaFiles
+	}
+	return Limits{Bytes: int64(size) * mib, Files: int64(files)}
+}
+
+// Usage is the number and total size of media files
+type Usage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// total is the user of the usage rows holding the totals of workspaces,
+// which are checked against their quotas in a single update
+const total = 0
+
+// Workspace returns the recorded media usage of a workspace
+func Workspace(database *gorm.DB, workspaceID uint) (Usage, error) {
+	var usage Usage
+	err := database.Model(&models.StorageUsage{}).
+		Select("COALESCE(SUM(bytes), 0) AS bytes, COALESCE(SUM(files), 0) AS files").
+		Where("workspace_id = ? AND user_id = ?", workspaceID, total).Scan(&usage).Error
+	return usage, err
+}
+
+// Check returns an error wrapping ErrExceeded if adding files and bytes to the
+// media of a workspace would take it over its quotas. Shrinking media is
+// always allowed, so that a workspace over its quotas can be cleaned up.
+//
+// Check only reads the usage, to turn away files before they are stored;
+// Reserve is what enforces the quotas.
+func Check(database *db.DB, limits Limits, workspaceID uint, bytes, files int64) error {
+	if limits.Bytes == 0 && limits.Files == 0 {
+		return nil
+	}
+
+	used, err := Workspace(database.DB, workspaceID)
+	if err != nil {
+		return err
+	}
+	return exceeded(limits, used, bytes, files)
+}
+
+// exceeded returns an error wrapping ErrExceeded if adding files and bytes to
+// used would go over limits
+func exceeded(limits Limits, used Usage, bytes, files int64) error {
+	if limits.Bytes > 0 && bytes > 0 && used.Bytes+bytes > limits.Bytes {
+		return fmt.Errorf("%w: %.1f of %d MiB used, %.1f MiB more requested",
+			ErrExceeded, float64(used.Bytes)/mib, limits.Bytes/mib, float64(bytes)/mib)
+	}
+	if limits.Files > 0 && files > 0 && used.Files+files > limits.Files {
+		return fmt.Errorf("%w: %d of %d files used", ErrExceeded, used.Files, limits.Files)
+	}
+	return nil
+}
+
+// Reserve records media files added to a workspace by a user like Add, unless
+// they would take the workspace over its quotas, in which case it returns an
+// error wrapping ErrExceeded. The workspace total is checked and increased in
+// one conditional update, so that concurrent uploads cannot both pass; run it
+// in the transaction recording the media, so that a failure releases it.
+func Reserve(database *gorm.DB, limits Limits, workspaceID, userID uint, bytes, files int64) error {
+	if bytes == 0 && files == 0 {
+		return nil
+	}
+
+	// The total row is created empty first, so that the update has a row to check
+	row := models.StorageUsage{WorkspaceID: workspaceID, UserID: total}
+	if err := database.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return err
+	}
+
+	query := database.Model(&models.StorageUsage{}).Where("workspace_id = ? AND user_id = ?", workspaceID, total)
+	if limits.Bytes > 0 && bytes > 0 {
+		query = query.Where("bytes + ? <= ?", bytes, limits.Bytes)
+	}
+	if limits.Files > 0 && files > 0 {
+		query = query.Where("files + ? <= ?", files, limits.Files)
+	}
+	result := query.Updates(map[string]interface{}{
+		"bytes":      gorm.Expr("bytes + ?", bytes),
+		"files":      gorm.Expr("files + ?", files),
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		used, err := Workspace(database, workspaceID)
+		if err != nil {
+			return err
+		}
+		if err := exceeded(limits, used, bytes, files); err != nil {
+			return err
+		}
+		return ErrExceeded
+	}
+
+	return add(database, workspaceID, userID, bytes, files)
+}
+
+// Add records media files added to a workspace by a user, or removed from it
+// when negative
+func Add(database *gorm.DB, workspaceID, userID uint, bytes, files int64) error {
+	if bytes == 0 && files == 0 {
+		return nil
+	}
+	if err := add(database, workspaceID, total, bytes, files); err != nil {
+		return err
+	}
+	return add(database, workspaceID, userID, bytes, files)
+}
+
+// add adds to the usage row of a workspace and user
+func add(database *gorm.DB, workspaceID, userID uint, bytes, files int64) error {
+	usage := models.StorageUsage{WorkspaceID: workspaceID, UserID: userID, Bytes: bytes, Files: files}
+	return database.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "workspace_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"bytes":      gorm.Expr("storage_usages.bytes + ?", bytes),
+			"files":      gorm.Expr("storage_usages.files + ?", files),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&usage).Error
+}
+
+// Reconcile recomputes the recorded media usage of every workspace and
+// uploader from the media items and their versions, correcting any drift of
+// the running totals. It returns the recomputed usage of all workspaces
+// together.
+func Reconcile(database *db.DB) (Usage, error) {
+	var media, versions []models.StorageUsage
+	if err := database.Model(&models.Media{}).
+		Select("workspace_id, uploaded_by AS user_id, SUM(size) AS bytes, COUNT(*) AS files").
+		Group("workspace_id, uploaded_by").Scan(&media).Error; err != nil {
+		return Usage{}, err
+	}
+	// Previous versions count towards the size of their media, not the files
+	if err := database.Model(&models.MediaVersion{}).
+		Select("media.workspace_id, media.uploaded_by AS user_id, SUM(media_versions.size) AS bytes").
+		Joins("JOIN media ON media.id = media_versions.media_id AND media.deleted_at IS NULL").
+		Group("media.workspace_id, media.uploaded_by").Scan(&versions).Error; err != nil {
+		return Usage{}, err
+	}
+
+	type key struct{ workspaceID, userID uint }
+	usages := map[key]*models.StorageUsage{}
+	var all Usage
+	for _, row := range append(media, versions...) {
+		for _, k := range []key{{row.WorkspaceID, row.UserID}, {row.WorkspaceID, total}} {
+			usage, ok := usages[k]
+			if !ok {
+				usage = &models.StorageUsage{WorkspaceID: k.workspaceID, UserID: k.userID}
+				usages[k] = usage
+			}
+			usage.Bytes += row.Bytes
+			usage.Files += row.Files
+		}
+		all.Bytes += row.Bytes
+		all.Files += row.Files
+	}
+	rows := make([]models.StorageUsage, 0, len(usages))
+	for _, usage := range usages {
+		rows = append(rows, *usage)
+	}
+
+	err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&models.StorageUsage{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, 500).Error
+	})
+	return all, err
+}
+
+// Backfill records the media usage of installations that predate usage
+// accounting or workspace totals, which have media but no recorded totals
+func Backfill(database *db.DB) error {
+	var recorded, media int64
+	if err := database.Model(&models.StorageUsage{}).Where("user_id = ?", total).Count(&recorded).Error; err != nil {
+		return err
+	}
+	if recorded > 0 {
+		return nil
+	}
+	if err := database.Model(&models.Media{}).Count(&media).Error; err != nil || media == 0 {
+		return err
+	}
+	_, err := Reconcile(database)
+	return err
+}
+
+// Workspaces returns the recorded media usage of every workspace with media,
+// keyed by workspace ID
+func Workspaces(database *db.DB) (map[uint]Usage, error) {
+	var rows []models.StorageUsage
+	if err := database.Where("user_id = ?", total).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	usages := make(map[uint]Usage, len(rows))
+	for _, row := range rows {
+		usages[row.WorkspaceID] = Usage{Bytes: row.Bytes, Files: row.Files}
+	}
+	return usages, nil
+}
+
+// Uploaders returns the recorded media usage of the users who uploaded media
+// to a workspace, largest first
+func Uploaders(database *db.DB, workspaceID uint) ([]models.StorageUsage, error) {
+	var rows []models.StorageUsage
+	err := database.Where("workspace_id = ? AND user_id <> ? AND (bytes <> 0 OR files <> 0)", workspaceID, total).
+		Order("bytes desc, user_id asc").Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,187 @@
+// internal/quota/quota_test.go
+package quota
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// uploader is the user adding media in the tests
+const uploader = 7
+
+// usageOf returns the recorded usage of a workspace by a user
+func usageOf(t *testing.T, database *db.DB, workspaceID, userID uint) Usage {
+	t.Helper()
+	var usage Usage
+	if err := database.Model(&models.StorageUsage{}).Select("bytes, files").
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Scan(&usage).Error; err != nil {
+		t.Fatal(err)
+	}
+	return usage
+}
+
+func TestReserve(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		// used is the usage recorded before, and bytes and files what is reserved
+		used         Usage
+		bytes, files int64
+		exceeded     bool
+	}{
+		{name: "unlimited", used: Usage{Bytes: 1 << 40, Files: 1000}, bytes: 100, files: 1},
+		{name: "within", limits: Limits{Bytes: 1000, Files: 10}, used: Usage{Bytes: 800, Files: 8}, bytes: 200, files: 1},
+		{name: "first upload", limits: Limits{Bytes: 1000, Files: 10}, bytes: 1000, files: 1},
+		{name: "over the size", limits: Limits{Bytes: 1000}, used: Usage{Bytes: 900, Files: 1}, bytes: 101, files: 1, exceeded: true},
+		{name: "over the files", limits: Limits{Files: 2}, used: Usage{Bytes: 10, Files: 2}, bytes: 1, files: 1, exceeded: true},
+		{name: "first upload too large", limits: Limits{Bytes: 1000}, bytes: 1001, files: 1, exceeded: true},
+		// A version adds bytes but no file
+		{name: "version at the file limit", limits: Limits{Files: 2}, used: Usage{Bytes: 10, Files: 2}, bytes: 5},
+		// Shrinking is allowed, so that a workspace over its quota can be cleaned up
+		{name: "shrinking over the size", limits: Limits{Bytes: 100}, used: Usage{Bytes: 500, Files: 1}, bytes: -50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			if tt.used != (Usage{}) {
+				if err := Add(database.DB, 1, uploader, tt.used.Bytes, tt.used.Files); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+				return Reserve(tx, tt.limits, 1, uploader, tt.bytes, tt.files)
+			})
+			if got := errors.Is(err, ErrExceeded); got != tt.exceeded {
+				t.Fatalf("Reserve error = %v, want exceeded %v", err, tt.exceeded)
+			}
+			if err != nil && !tt.exceeded {
+				t.Fatalf("Reserve: %v", err)
+			}
+
+			want := tt.used
+			if !tt.exceeded {
+				want.Bytes += tt.bytes
+				want.Files += tt.files
+			}
+			if got, _ := Workspace(database.DB, 1); got != want {
+				t.Errorf("workspace usage = %+v, want %+v", got, want)
+			}
+			if got := usageOf(t, database, 1, uploader); got != want {
+				t.Errorf("uploader usage = %+v, want %+v", got, want)
+			}
+			// Other workspaces are not touched
+			if got, _ := Workspace(database.DB, 2); got != (Usage{}) {
+				t.Errorf("other workspace usage = %+v, want none", got)
+			}
+		})
+	}
+}
+
+// Concurrent uploads each see room for their file; only as many as fit are reserved
+func TestReserveConcurrent(t *testing.T) {
+	database := dbtest.New(t)
+	limits := Limits{Bytes: 1000, Files: 3}
+
+	const uploads = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+				return Reserve(tx, limits, 1, uploader, 100, 1)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var reserved int
+	for err := range errs {
+		switch {
+		case err == nil:
+			reserved++
+		case !errors.Is(err, ErrExceeded):
+			t.Errorf("Reserve: %v", err)
+		}
+	}
+	if reserved != 3 {
+		t.Errorf("%d uploads reserved, want 3", reserved)
+	}
+	if got, want := usageOf(t, database, 1, total), (Usage{Bytes: 300, Files: 3}); got != want {
+		t.Errorf("workspace usage = %+v, want %+v", got, want)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	database := dbtest.New(t)
+
+	media := []models.Media{
+		{WorkspaceID: 1, FilePath: "a.txt", Size: 100, UploadedBy: 1},
+		{WorkspaceID: 1, FilePath: "b.txt", Size: 50, UploadedBy: 2},
+		{WorkspaceID: 2, FilePath: "c.txt", Size: 10, UploadedBy: 1},
+		{WorkspaceID: 1, FilePath: "gone.txt", Size: 1000, UploadedBy: 1},
+	}
+	if err := database.Create(&media).Error; err != nil {
+		t.Fatal(err)
+	}
+	versions := []models.MediaVersion{
+		{MediaID: media[0].ID, FilePath: "a-old.txt", Size: 30},
+		{MediaID: media[3].ID, FilePath: "gone-old.txt", Size: 1000},
+	}
+	if err := database.Create(&versions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Delete(&media[3]).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Drifted totals are replaced
+	if err := Add(database.DB, 1, 1, 5000, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := Reconcile(database)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if want := (Usage{Bytes: 190, Files: 3}); all != want {
+		t.Errorf("Reconcile = %+v, want %+v", all, want)
+	}
+
+	tests := []struct {
+		workspaceID, userID uint
+		want                Usage
+	}{
+		{1, total, Usage{Bytes: 180, Files: 2}},
+		{1, 1, Usage{Bytes: 130, Files: 1}},
+		{1, 2, Usage{Bytes: 50, Files: 1}},
+		{2, total, Usage{Bytes: 10, Files: 1}},
+		{2, 1, Usage{Bytes: 10, Files: 1}},
+	}
+	for _, tt := range tests {
+		if got := usageOf(t, database, tt.workspaceID, tt.userID); got != tt.want {
+			t.Errorf("usage of workspace %d by user %d = %+v, want %+v", tt.workspaceID, tt.userID, got, tt.want)
+		}
+	}
+
+	// Usage already recorded is not backfilled again
+	if err := Add(database.DB, 1, 1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Backfill(database); err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	if got := usageOf(t, database, 1, total); got.Bytes != 181 {
+		t.Errorf("usage after Backfill = %+v, want it kept", got)
+	}
+}
@@ -0,0 +1,275 @@
+// internal/related/related.go
+package related
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Field types that feed the recommendations
+const (
+	// FieldTags holds taxonomy terms, as a list or a comma separated string
+	FieldTags = "tags"
+	// FieldSelect holds a single taxonomy term
+	FieldSelect = "select"
+	// FieldReference holds the IDs of other content items
+	FieldReference = "reference"
+)
+
+// Score weights of the individual signals
+const (
+	termWeight      = 3.0
+	referenceWeight = 2.0
+	textWeight      = 1.0
+	// MinScore is the lowest score that is still considered related
+	MinScore = 0.05
+)
+
+// titleBoost is how many times a title token counts compared to a body token
+const titleBoost = 3
+
+// Match is a content item related to another one
+type Match struct {
+	ContentID uint
+	Score     float64
+}
+
+// document holds the signals extracted from a content item
+type document struct {
+	id     uint
+	terms  map[string]bool
+	refs   map[uint]bool
+	tokens map[string]float64
+	vector map[string]float64
+}
+
+var markup = regexp.MustCompile(`<[^>]*>|!?\[([^\]]*)\]\([^)]*\)|[#*_>` + "`" + `~|]`)
+
+// stopwords are frequent English words that carry no topic
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`the and for are but not you all any can had her was one our out
+		has him his how its let may new now old see two way who did get got use also been from have
+		into just more most much must only over said same some such than that them then they this
+		very what when were will with would your about after again being below between both could
+		does doing down during each further here just other should their there these those through
+		under until where which while why yours`) {
+		stopwords[word] = true
+	}
+}
+
+// tokenize splits text into lower case words, dropping markup and stopwords
+func tokenize(text string) []string {
+	text = markup.ReplaceAllString(text, " $1 ")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := words[:0]
+	for _, word := range words {
+		if len([]rune(word)) < 3 || stopwords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// stringValues returns the terms held by a tags or select field value
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []interface{}:
+		out := []string{}
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// idValues returns the content IDs held by a reference field value
+func idValues(value interface{}) []uint {
+	switch v := value.(type) {
+	case float64:
+		if v > 0 {
+			return []uint{uint(v)}
+		}
+	case string:
+		if id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil && id > 0 {
+			return []uint{uint(id)}
+		}
+	case []interface{}:
+		out := []uint{}
+		for _, item := range v {
+			out = append(out, idValues(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// newDocument extracts the signals of a content item
+func newDocument(content models.Content, fields []models.ContentField) *document {
+	doc := &document{
+		id:     content.ID,
+		terms:  map[string]bool{},
+		refs:   map[uint]bool{},
+		tokens: map[string]float64{},
+	}
+
+	for _, field := range fields {
+		value, ok := content.Fields[field.Name]
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case FieldTags, FieldSelect:
+			for _, term := range stringValues(value) {
+				if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+					doc.terms[field.Name+":"+term] = true
+				}
+			}
+		case FieldReference:
+			for _, id := range idValues(value) {
+				doc.refs[id] = true
+			}
+		}
+	}
+
+	if content.ParentID != nil {
+		doc.refs[*content.ParentID] = true
+	}
+
+	for _, token := range tokenize(content.Title) {
+		doc.tokens[token] += titleBoost
+	}
+	for _, token := range tokenize(content.Body) {
+		doc.tokens[token]++
+	}
+
+	return doc
+}
+
+// jaccard returns the overlap of two term sets
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// cosine returns the similarity of two normalised vectors
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	sum := 0.0
+	for token, weight := range a {
+		sum += weight * b[token]
+	}
+	return sum
+}
+
+// Compute scores every pair of content items and returns up to limit matches
+// per item, best first. fields maps content type IDs to their field definitions.
+// Only the items listed in targets are scored against the rest; nil scores all.
+func Compute(contents []models.Content, fields map[uint][]models.ContentField, targets []uint, limit int) map[uint][]Match {
+	docs := make([]*document, 0, len(contents))
+	byID := make(map[uint]*document, len(contents))
+	for _, content := range contents {
+		doc := newDocument(content, fields[content.ContentTypeID])
+		docs = append(docs, doc)
+		byID[doc.id] = doc
+	}
+
+	// A parent refers to its children just as they refer to it
+	for _, content := range contents {
+		if content.ParentID != nil {
+			if parent, ok := byID[*content.ParentID]; ok {
+				parent.refs[content.ID] = true
+			}
+		}
+	}
+
+	// Weigh tokens by TF-IDF over the whole corpus
+	frequency := map[string]int{}
+	for _, doc := range docs {
+		for token := range doc.tokens {
+			frequency[token]++
+		}
+	}
+	for _, doc := range docs {
+		doc.vector = make(map[string]float64, len(doc.tokens))
+		norm := 0.0
+		for token, tf := range doc.tokens {
+			weight := (1 + math.Log(tf)) * math.Log(1+float64(len(docs))/float64(frequency[token]))
+			doc.vector[token] = weight
+			norm += weight * weight
+		}
+		if norm > 0 {
+			norm = math.Sqrt(norm)
+			for token := range doc.vector {
+				doc.vector[token] /= norm
+			}
+		}
+	}
+
+	selected := docs
+	if targets != nil {
+		selected = []*document{}
+		for _, id := range targets {
+			if doc, ok := byID[id]; ok {
+				selected = append(selected, doc)
+			}
+		}
+	}
+
+	results := make(map[uint][]Match, len(selected))
+	for _, doc := range selected {
+		matches := []Match{}
+		for _, other := range docs {
+			if other.id == doc.id {
+				continue
+			}
+
+			score := termWeight*jaccard(doc.terms, other.terms) + textWeight*cosine(doc.vector, other.vector)
+			if doc.refs[other.id] || other.refs[doc.id] {
+				score += referenceWeight
+			}
+			if score >= MinScore {
+				matches = append(matches, Match{ContentID: other.id, Score: math.Round(score*10000) / 10000})
+			}
+		}
+
+		sort.SliceStable(matches, func(i, j int) bool {
+			if matches[i].Score != matches[j].Score {
+				return matches[i].Score > matches[j].Score
+			}
+			return matches[i].ContentID < matches[j].ContentID
+		})
+		if len(matches) > limit {
+			matches = matches[:limit]
+		}
+		results[doc.id] = matches
+	}
+
+	return results
+}
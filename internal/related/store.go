@@ -0,0 +1,226 @@
+// internal/related/store.go
+package related
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// MaxStored is the number of related items precomputed per content item
+const MaxStored = 20
+
+// refreshDelay batches bursts of edits into a single refresh
+const refreshDelay = 2 * time.Second
+
+// corpus loads the published content of a workspace and its field definitions
+func corpus(database *db.DB, workspaceID uint) ([]models.Content, map[uint][]models.ContentField, error) {
+	var contents []models.Content
+	if err := database.Select("id", "workspace_id", "content_type_id", "title", "body", "fields", "parent_id").
+		Where("workspace_id = ? AND status = ?", workspaceID, "published").
+		Find(&contents).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var contentTypes []models.ContentType
+	if err := database.Where("workspace_id = ?", workspaceID).Find(&contentTypes).Error; err != nil {
+		return nil, nil, err
+	}
+	fields := make(map[uint][]models.ContentField, len(contentTypes))
+	for _, contentType := range contentTypes {
+		fields[contentType.ID] = contentType.Fields
+	}
+
+	return contents, fields, nil
+}
+
+// Refresh recomputes the related content of the given items of a workspace,
+// and of the items whose matches may change with them; nil recomputes every
+// published item of the workspace
+func Refresh(database *db.DB, workspaceID uint, contentIDs []uint) error {
+	contents, fields, err := corpus(database, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	if contentIDs == nil {
+		return store(database, workspaceID, nil, Compute(contents, fields, nil, MaxStored))
+	}
+
+	// Scores are symmetric, so only the items that list a changed item, or
+	// that it matches now, can gain, lose or reorder it among their matches
+	var listing []uint
+	if err := database.Model(&models.RelatedContent{}).
+		Where("workspace_id = ? AND related_id IN ?", workspaceID, contentIDs).
+		Distinct().Pluck("content_id", &listing).Error; err != nil {
+		return err
+	}
+
+	results := Compute(contents, fields, contentIDs, MaxStored)
+	changed := make(map[uint]bool, len(contentIDs))
+	for _, id := range contentIDs {
+		changed[id] = true
+	}
+	affected := map[uint]bool{}
+	for _, id := range listing {
+		affected[id] = true
+	}
+	for _, matches := range results {
+		for _, match := range matches {
+			affected[match.ContentID] = true
+		}
+	}
+	others := []uint{}
+	for id := range affected {
+		if !changed[id] {
+			others = append(others, id)
+		}
+	}
+	for id, matches := range Compute(contents, fields, others, MaxStored) {
+		results[id] = matches
+	}
+
+	// Changed items that are no longer published lose their matches
+	return store(database, workspaceID, append(others, contentIDs...), results)
+}
+
+// store replaces the stored matches of the given items of a workspace with
+// results; nil replaces those of every item
+func store(database *db.DB, workspaceID uint, contentIDs []uint, results map[uint][]Match) error {
+	rows := []models.RelatedContent{}
+	for contentID, matches := range results {
+		for _, match := range matches {
+			rows = append(rows, models.RelatedContent{
+				WorkspaceID: workspaceID,
+				ContentID:   contentID,
+				RelatedID:   match.ContentID,
+				Score:       match.Score,
+			})
+		}
+	}
+
+	return db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		stale := tx.Unscoped().Where("workspace_id = ?", workspaceID)
+		if contentIDs != nil {
+			stale = stale.Where("content_id IN ?", contentIDs)
+		}
+		if err := stale.Delete(&models.RelatedContent{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+}
+
+// RefreshAll recomputes the related content of every workspace
+func RefreshAll(database *db.DB) {
+	var workspaceIDs []uint
+	if err := database.Model(&models.Workspace{}).Pluck("id", &workspaceIDs).Error; err != nil {
+		slog.Error("Failed to fetch workspaces for related content", "error", err)
+		return
+	}
+
+	for _, workspaceID := range workspaceIDs {
+		if err := Refresh(database, workspaceID, nil); err != nil {
+			slog.Error("Failed to refresh related content", "workspace", workspaceID, "error", err)
+		}
+	}
+}
+
+// Lookup returns the precomputed related items of a content item. Items not
+// refreshed yet have none.
+func Lookup(database *db.DB, content models.Content, limit int) ([]Match, error) {
+	var rows []models.RelatedContent
+	if err := database.Where("content_id = ?", content.ID).Order("score desc, related_id asc").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(rows))
+	for _, row := range rows {
+		matches = append(matches, Match{ContentID: row.RelatedID, Score: row.Score})
+	}
+	return matches, nil
+}
+
+// Refresher recomputes related content in the background after edits
+type Refresher struct {
+	db        *db.DB
+	refreshed func(workspaceID uint)
+	mu        sync.Mutex
+	timers    map[uint]*time.Timer
+	// pending holds the items changed per workspace since its last refresh;
+	// a nil set refreshes the whole workspace
+	pending map[uint]map[uint]bool
+}
+
+// NewRefresher creates a new related content refresher. refreshed, if not nil,
+// is called after each workspace has been refreshed.
+func NewRefresher(database *db.DB, refreshed func(workspaceID uint)) *Refresher {
+	return &Refresher{
+		db:        database,
+		refreshed: refreshed,
+		timers:    make(map[uint]*time.Timer),
+		pending:   make(map[uint]map[uint]bool),
+	}
+}
+
+// Schedule queues a refresh of the given items of a workspace, or of the
+// whole workspace when none are given. Edits made in quick succession share
+// a single refresh. A nil refresher does nothing.
+func (rf *Refresher) Schedule(workspaceID uint, contentIDs ...uint) {
+	if rf == nil {
+		return
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	changed, queued := rf.pending[workspaceID]
+	switch {
+	case len(contentIDs) == 0:
+		changed = nil
+	case !queued:
+		changed = map[uint]bool{}
+	}
+	if changed != nil {
+		for _, id := range contentIDs {
+			changed[id] = true
+		}
+	}
+	rf.pending[workspaceID] = changed
+
+	if timer, ok := rf.timers[workspaceID]; ok {
+		timer.Reset(refreshDelay)
+		return
+	}
+
+	rf.timers[workspaceID] = time.AfterFunc(refreshDelay, func() {
+		rf.mu.Lock()
+		delete(rf.timers, workspaceID)
+		changed := rf.pending[workspaceID]
+		delete(rf.pending, workspaceID)
+		rf.mu.Unlock()
+
+		var contentIDs []uint
+		if changed != nil {
+			contentIDs = make([]uint, 0, len(changed))
+			for id := range changed {
+				contentIDs = append(contentIDs, id)
+			}
+		}
+		if err := Refresh(rf.db, workspaceID, contentIDs); err != nil {
+			slog.Error("Failed to refresh related content", "workspace", workspaceID, "error", err)
+			return
+		}
+		if rf.refreshed != nil {
+			rf.refreshed(workspaceID)
+		}
+	})
+}
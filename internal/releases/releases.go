@@ -0,0 +1,360 @@
+// internal/releases/releases.go
+package releases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Release statuses
+const (
+	StatusDraft      = "draft"
+	StatusScheduled  = "scheduled"
+	StatusPublishing = "publishing"
+	StatusPublished  = "published"
+	StatusRolledBack = "rolled_back"
+	StatusFailed     = "failed"
+)
+
+// Release item actions
+const (
+	ActionPublish   = "publish"
+	ActionUnpublish = "unpublish"
+	ActionUpdate    = "update"
+)
+
+// SchedulerInterval is how often scheduled releases are checked
+const SchedulerInterval = 30 * time.Second
+
+// publishTimeout is how long a release may be publishing before the
+// scheduler marks it failed, as the server publishing it must have stopped
+const publishTimeout = 10 * time.Minute
+
+var (
+	// ErrNotPending is returned when a release has already been published or rolled back
+	ErrNotPending = errors.New("release has already been published")
+	// ErrNotPublished is returned when rolling back a release that is not live
+	ErrNotPublished = errors.New("only published releases can be rolled back")
+	// ErrEmpty is returned when publishing a release without items
+	ErrEmpty = errors.New("release has no items")
+)
+
+// ChangedError is returned when rolling back a release whose content was
+// edited after it went live, as the rollback would discard those edits
+type ChangedError struct {
+	ContentIDs []uint
+}
+
+func (e *ChangedError) Error() string {
+	return fmt.Sprintf("content %v was edited after the release was published", e.ContentIDs)
+}
+
+// ValidAction reports whether action is a supported release item action
+func ValidAction(action string) bool {
+	return action == ActionPublish || action == ActionUnpublish || action == ActionUpdate
+}
+
+// IsPending reports whether a release can still be edited and published
+func IsPending(release models.Release) bool {
+	return release.Status == StatusDraft || release.Status == StatusScheduled || release.Status == StatusFailed
+}
+
+// Apply applies a release item to a content item in memory
+func Apply(content *models.Content, item models.ReleaseItem, now time.Time) {
+	changes := item.Changes
+	if changes.Title != nil {
+		content.Title = *changes.Title
+	}
+	if changes.Slug != nil {
+		content.Slug = *changes.Slug
+	}
+	if changes.Body != nil {
+		content.Body = *changes.Body
+	}
+	if changes.MetaData != nil {
+		content.MetaData = *changes.MetaData
+	}
+	if changes.Fields != nil {
+		content.Fields = changes.Fields
+	}
+	if changes.SEO != nil {
+		content.SEO = *changes.SEO
+	}
+
+	switch item.Action {
+	case ActionPublish:
+		if content.Status != "published" {
+			content.PublishedAt = &now
+		}
+		content.Status = "published"
+	case ActionUnpublish:
+		content.Status = "draft"
+	}
+}
+
+// snapshot captures the state of a content item so it can be restored later
+func snapshot(content models.Content) *models.ContentSnapshot {
+	return &models.ContentSnapshot{
+		Title:       content.Title,
+		Slug:        content.Slug,
+		Body:        content.Body,
+		Status:      content.Status,
+		MetaData:    content.MetaData,
+		Fields:      content.Fields,
+		SEO:         content.SEO,
+		PublishedAt: content.PublishedAt,
+	}
+}
+
+// Publish applies every item of a release inside a single transaction. The
+// release is claimed first by moving it to publishing, so that it is only
+// published once when several servers or requests try at the same time.
+func Publish(database *db.DB, releaseID uint) error {
+	var release models.Release
+	if err := database.Preload("Items").First(&release, releaseID).Error; err != nil {
+		return err
+	}
+	if !IsPending(release) {
+		return ErrNotPending
+	}
+	if len(release.Items) == 0 {
+		return ErrEmpty
+	}
+
+	// The status read is the one claimed, so that it can be restored
+	claim := database.Model(&models.Release{}).
+		Where("id = ? AND status = ?", release.ID, release.Status).
+		Update("status", StatusPublishing)
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return ErrNotPending
+	}
+
+	err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, item := range release.Items {
+			var content models.Content
+			if err := tx.Where("id = ? AND workspace_id = ?", item.ContentID, release.WorkspaceID).First(&content).Error; err != nil {
+				return fmt.Errorf("content %d not found", item.ContentID)
+			}
+
+			item.Snapshot = snapshot(content)
+			Apply(&content, item, now)
+
+			if err := tx.Save(&content).Error; err != nil {
+				return err
+			}
+			if err := tx.Save(&item).Error; err != nil {
+				return err
+			}
+		}
+
+		// Published after the content was saved, so that later edits are
+		// told apart by their update time
+		return tx.Model(&release).Updates(map[string]interface{}{
+			"status":       StatusPublished,
+			"published_at": time.Now(),
+			"error":        "",
+		}).Error
+	})
+	if err != nil {
+		if restoreErr := database.Model(&release).Update("status", release.Status).Error; restoreErr != nil {
+			slog.Error("Failed to restore status of release", "release", release.ID, "error", restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback restores every item of a published release inside a single
+// transaction. Unless force is set, it returns a ChangedError, restoring
+// nothing, when content of the release was edited after it went live.
+func Rollback(database *db.DB, releaseID uint, force bool) error {
+	now := time.Now()
+
+	return db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		var release models.Release
+		if err := tx.Preload("Items").First(&release, releaseID).Error; err != nil {
+			return err
+		}
+		if release.Status != StatusPublished {
+			return ErrNotPublished
+		}
+
+		var restore []models.Content
+		changed := &ChangedError{}
+		for _, item := range release.Items {
+			if item.Snapshot == nil {
+				continue
+			}
+
+			var content models.Content
+			if err := tx.First(&content, item.ContentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// Deleted since the release went live; nothing to restore
+					continue
+				}
+				return err
+			}
+			if !force && release.PublishedAt != nil && content.UpdatedAt.After(*release.PublishedAt) {
+				changed.ContentIDs = append(changed.ContentIDs, content.ID)
+				continue
+			}
+
+			content.Title = item.Snapshot.Title
+			content.Slug = item.Snapshot.Slug
+			content.Body = item.Snapshot.Body
+			content.Status = item.Snapshot.Status
+			content.MetaData = item.Snapshot.MetaData
+			content.Fields = item.Snapshot.Fields
+			content.SEO = item.Snapshot.SEO
+			content.PublishedAt = item.Snapshot.PublishedAt
+			restore = append(restore, content)
+		}
+		if len(changed.ContentIDs) > 0 {
+			return changed
+		}
+
+		for i := range restore {
+			if err := tx.Save(&restore[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&release).Where("status = ?", StatusPublished).Updates(map[string]interface{}{
+			"status":         StatusRolledBack,
+			"rolled_back_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotPublished
+		}
+		return nil
+	})
+}
+
+// PublishDue publishes every scheduled release whose time has come and calls
+// published for each one that went live. Releases another server claimed
+// first are left to it.
+func PublishDue(database *db.DB, published func(models.Release)) {
+	failStalled(database)
+
+	var due []models.Release
+	if err := database.Preload("Items").Where("status = ? AND scheduled_at <= ?", StatusScheduled, time.Now()).Find(&due).Error; err != nil {
+		slog.Error("Failed to fetch scheduled releases", "error", err)
+		return
+	}
+
+	for _, release := range due {
+		if err := Publish(database, release.ID); err != nil {
+			if errors.Is(err, ErrNotPending) {
+				continue
+			}
+			slog.Error("Failed to publish scheduled release", "release", release.ID, "error", err)
+			database.Model(&release).Where("status = ?", StatusScheduled).Updates(map[string]interface{}{
+				"status": StatusFailed,
+				"error":  err.Error(),
+			})
+			continue
+		}
+		slog.Info("Published scheduled release", "release", release.ID, "name", release.Name)
+		if published != nil {
+			published(release)
+		}
+	}
+}
+
+// failStalled marks releases failed that have been publishing for longer
+// than publishTimeout. Their transaction never committed, so nothing changed.
+func failStalled(database *db.DB) {
+	result := database.Model(&models.Release{}).
+		Where("status = ? AND updated_at < ?", StatusPublishing, time.Now().Add(-publishTimeout)).
+		Updates(map[string]interface{}{
+			"status": StatusFailed,
+			"error":  "publishing was interrupted",
+		})
+	if result.Error != nil {
+		slog.Error("Failed to mark stalled releases failed", "error", result.Error)
+	}
+}
+
+// RunScheduler publishes scheduled releases until ctx is cancelled
+func RunScheduler(ctx context.Context, database *db.DB, interval time.Duration, published func(models.Release)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			PublishDue(database, published)
+		}
+	}
+}
+
+// Preview returns the published content of the release's workspace as it will
+// look once the release goes live, ordered by publish date.
+func Preview(database *db.DB, release models.Release) ([]models.Content, error) {
+	var items []models.ReleaseItem
+	if err := database.Where("release_id = ?", release.ID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ContentID)
+	}
+
+	query := database.Preload("Author").Preload("ContentType").
+		Where("workspace_id = ?", release.WorkspaceID)
+	if len(ids) > 0 {
+		query = query.Where("status = ? OR id IN ?", "published", ids)
+	} else {
+		query = query.Where("status = ?", "published")
+	}
+
+	var contents []models.Content
+	if err := query.Find(&contents).Error; err != nil {
+		return nil, err
+	}
+
+	byContent := make(map[uint]models.ReleaseItem, len(items))
+	for _, item := range items {
+		byContent[item.ContentID] = item
+	}
+
+	now := time.Now()
+	preview := make([]models.Content, 0, len(contents))
+	for _, content := range contents {
+		if item, ok := byContent[content.ID]; ok {
+			Apply(&content, item, now)
+		}
+		if content.Status == "published" {
+			preview = append(preview, content)
+		}
+	}
+
+	sort.SliceStable(preview, func(i, j int) bool {
+		a, b := preview[i].PublishedAt, preview[j].PublishedAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.After(*b)
+	})
+
+	return preview, nil
+}
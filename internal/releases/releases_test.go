@@ -0,0 +1,208 @@
+// internal/releases/releases_test.go
+package releases
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// newRelease records a release of the given status publishing the content
+// item titled "Draft", which it renames to "Live"; missing adds an item for
+// content that does not exist
+func newRelease(t *testing.T, database *db.DB, status string, missing bool) (models.Release, models.Content) {
+	t.Helper()
+
+	content := models.Content{WorkspaceID: 1, ContentTypeID: 1, Title: "Draft", Slug: "draft", Status: "draft"}
+	if err := database.Create(&content).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled := time.Now().Add(-time.Minute)
+	release := models.Release{WorkspaceID: 1, Name: "Launch", Status: status, ScheduledAt: &scheduled, PreviewToken: fmt.Sprint("token-", content.ID)}
+	if err := database.Create(&release).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	title := "Live"
+	items := []models.ReleaseItem{{ReleaseID: release.ID, ContentID: content.ID, Action: ActionPublish, Changes: models.ContentChanges{Title: &title}}}
+	if missing {
+		items = append(items, models.ReleaseItem{ReleaseID: release.ID, ContentID: content.ID + 100, Action: ActionPublish})
+	}
+	if status == "" {
+		items = nil
+	}
+	for _, item := range items {
+		if err := database.Create(&item).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return release, content
+}
+
+// state returns the status of a release and the title and status of a content item
+func state(t *testing.T, database *db.DB, release models.Release, content models.Content) (string, string, string) {
+	t.Helper()
+	if err := database.First(&release, release.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.First(&content, content.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return release.Status, content.Title, content.Status
+}
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is the status of the release, or empty for a draft without items
+		status  string
+		missing bool
+		err     error
+		// released is the status of the release afterwards, and title the
+		// title of its content
+		released string
+		title    string
+	}{
+		{name: "draft", status: StatusDraft, released: StatusPublished, title: "Live"},
+		{name: "scheduled", status: StatusScheduled, released: StatusPublished, title: "Live"},
+		{name: "failed before", status: StatusFailed, released: StatusPublished, title: "Live"},
+		{name: "no items", status: "", err: ErrEmpty, released: StatusDraft, title: "Draft"},
+		{name: "published", status: StatusPublished, err: ErrNotPending, released: StatusPublished, title: "Draft"},
+		{name: "being published elsewhere", status: StatusPublishing, err: ErrNotPending, released: StatusPublishing, title: "Draft"},
+		{name: "rolled back", status: StatusRolledBack, err: ErrNotPending, released: StatusRolledBack, title: "Draft"},
+		// The claim is given back when the release fails, and nothing is applied
+		{name: "missing content", status: StatusScheduled, missing: true, released: StatusScheduled, title: "Draft"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			release, content := newRelease(t, database, tt.status, tt.missing)
+			if tt.status == "" {
+				database.Model(&release).Update("status", StatusDraft)
+			}
+
+			err := Publish(database, release.ID)
+			switch {
+			case tt.missing:
+				if err == nil {
+					t.Error("Publish succeeded, want an error")
+				}
+			case !errors.Is(err, tt.err):
+				t.Errorf("Publish error = %v, want %v", err, tt.err)
+			}
+
+			released, title, _ := state(t, database, release, content)
+			if released != tt.released || title != tt.title {
+				t.Errorf("release %s with content %q, want %s with %q", released, title, tt.released, tt.title)
+			}
+		})
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	database := dbtest.New(t)
+
+	due, dueContent := newRelease(t, database, StatusScheduled, false)
+	claimed, claimedContent := newRelease(t, database, StatusPublishing, false)
+	broken, brokenContent := newRelease(t, database, StatusScheduled, true)
+	stalled, stalledContent := newRelease(t, database, StatusPublishing, false)
+	later, laterContent := newRelease(t, database, StatusScheduled, false)
+	database.Model(&stalled).UpdateColumn("updated_at", time.Now().Add(-publishTimeout-time.Minute))
+	database.Model(&later).Update("scheduled_at", time.Now().Add(time.Hour))
+
+	var published []uint
+	PublishDue(database, func(release models.Release) { published = append(published, release.ID) })
+
+	if len(published) != 1 || published[0] != due.ID {
+		t.Errorf("published %v, want only %d", published, due.ID)
+	}
+
+	tests := []struct {
+		name     string
+		release  models.Release
+		content  models.Content
+		released string
+		title    string
+	}{
+		{name: "due", release: due, content: dueContent, released: StatusPublished, title: "Live"},
+		// Left to the server publishing it, rather than marked failed
+		{name: "claimed elsewhere", release: claimed, content: claimedContent, released: StatusPublishing, title: "Draft"},
+		{name: "broken", release: broken, content: brokenContent, released: StatusFailed, title: "Draft"},
+		{name: "stalled", release: stalled, content: stalledContent, released: StatusFailed, title: "Draft"},
+		{name: "not due", release: later, content: laterContent, released: StatusScheduled, title: "Draft"},
+	}
+	for _, tt := range tests {
+		released, title, _ := state(t, database, tt.release, tt.content)
+		if released != tt.released || title != tt.title {
+			t.Errorf("%s: release %s with content %q, want %s with %q", tt.name, released, title, tt.released, tt.title)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit edits the content after the release was published
+		edit  bool
+		force bool
+		// twice rolls the release back a second time
+		twice bool
+		err   error
+		// changed is whether a ChangedError is returned
+		changed  bool
+		released string
+		title    string
+		status   string
+	}{
+		{name: "unchanged", released: StatusRolledBack, title: "Draft", status: "draft"},
+		{name: "edited since", edit: true, changed: true, released: StatusPublished, title: "Edited", status: "published"},
+		{name: "edited since forced", edit: true, force: true, released: StatusRolledBack, title: "Draft", status: "draft"},
+		{name: "rolled back already", twice: true, err: ErrNotPublished, released: StatusRolledBack, title: "Draft", status: "draft"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			release, content := newRelease(t, database, StatusDraft, false)
+			if err := Publish(database, release.ID); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+			if tt.edit {
+				if err := database.Model(&content).Update("title", "Edited").Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := Rollback(database, release.ID, tt.force)
+			if tt.twice {
+				if err != nil {
+					t.Fatalf("first Rollback: %v", err)
+				}
+				err = Rollback(database, release.ID, tt.force)
+			}
+
+			var changed *ChangedError
+			switch {
+			case tt.changed:
+				if !errors.As(err, &changed) || len(changed.ContentIDs) != 1 || changed.ContentIDs[0] != content.ID {
+					t.Errorf("Rollback error = %v, want content %d changed", err, content.ID)
+				}
+			case !errors.Is(err, tt.err):
+				t.Errorf("Rollback error = %v, want %v", err, tt.err)
+			}
+
+			released, title, status := state(t, database, release, content)
+			if released != tt.released || title != tt.title || status != tt.status {
+				t.Errorf("release %s with content %q (%s), want %s with %q (%s)",
+					released, title, status, tt.released, tt.title, tt.status)
+			}
+		})
+	}
+}
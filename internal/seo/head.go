@@ -0,0 +1,72 @@
+// internal/seo/head.go
+package seo
+
+import (
+	"html"
+	"strings"
+)
+
+// Tag is an HTML element of the document head
+type Tag struct {
+	Name       string            `json:"tag"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+func meta(attribute, key, content string) Tag {
+	return Tag{Name: "meta", Attributes: map[string]string{attribute: key, "content": content}}
+}
+
+// Tags returns the meta and link elements describing a page, in render order.
+// Empty values are left out.
+func Tags(m Meta) []Tag {
+	tags := []Tag{}
+	add := func(tag Tag) {
+		if tag.Attributes["content"] != "" || tag.Attributes["href"] != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	add(meta("name", "description", m.Description))
+	add(meta("name", "robots", m.Robots))
+	add(Tag{Name: "link", Attributes: map[string]string{"rel": "canonical", "href": m.CanonicalURL}})
+
+	add(meta("property", "og:type", "article"))
+	add(meta("property", "og:title", m.Title))
+	add(meta("property", "og:description", m.Description))
+	add(meta("property", "og:url", m.CanonicalURL))
+	add(meta("property", "og:site_name", m.SiteName))
+	add(meta("property", "og:image", m.ImageURL))
+	add(meta("property", "article:published_time", m.PublishedAt))
+	add(meta("property", "article:modified_time", m.ModifiedAt))
+
+	card := "summary"
+	if m.ImageURL != "" {
+		card = "summary_large_image"
+	}
+	add(meta("name", "twitter:card", card))
+	add(meta("name", "twitter:site", m.TwitterSite))
+	add(meta("name", "twitter:title", m.Title))
+	add(meta("name", "twitter:description", m.Description))
+	add(meta("name", "twitter:image", m.ImageURL))
+
+	return tags
+}
+
+// attributeOrder fixes the order attributes are rendered in
+var attributeOrder = []string{"rel", "name", "property", "href", "content"}
+
+// Render returns the title element and tags as HTML ready to place in the head
+func Render(title string, tags []Tag) string {
+	var b strings.Builder
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	for _, tag := range tags {
+		b.WriteString("<" + tag.Name)
+		for _, name := range attributeOrder {
+			if value, ok := tag.Attributes[name]; ok {
+				b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+			}
+		}
+		b.WriteString(">\n")
+	}
+	return b.String()
+}
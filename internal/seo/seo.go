@@ -0,0 +1,230 @@
+// internal/seo/seo.go
+package seo
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Recommended lengths of the meta title and description
+const (
+	TitleMaxLength       = 60
+	DescriptionMinLength = 50
+	DescriptionMaxLength = 160
+)
+
+// robotsDirectives lists the directives accepted in a robots value
+var robotsDirectives = map[string]bool{
+	"all":          true,
+	"none":         true,
+	"index":        true,
+	"noindex":      true,
+	"follow":       true,
+	"nofollow":     true,
+	"noarchive":    true,
+	"nosnippet":    true,
+	"noimageindex": true,
+	"notranslate":  true,
+}
+
+// robotsParameters lists the directives that take a value, such as max-snippet:50
+var robotsParameters = map[string]bool{
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+	"unavailable_after": true,
+}
+
+// ErrInvalid is wrapped by every validation error so handlers can report it as a bad request
+var ErrInvalid = errors.New("invalid SEO settings")
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, fmt.Sprintf(format, args...))
+}
+
+// NormalizeRobots lower cases a robots value and joins its directives with ", "
+func NormalizeRobots(value string) (string, error) {
+	directives := []string{}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+		name, _, hasValue := strings.Cut(directive, ":")
+		if hasValue && robotsParameters[name] || !hasValue && robotsDirectives[name] {
+			directives = append(directives, directive)
+			continue
+		}
+		return "", invalid("unknown robots directive %q", directive)
+	}
+	return strings.Join(directives, ", "), nil
+}
+
+// checkURL requires an absolute http or https URL
+func checkURL(field, value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return invalid("%s must be an absolute http or https URL", field)
+	}
+	return nil
+}
+
+// checkImage requires the media item to be an image of the workspace
+func checkImage(database *db.DB, workspaceID uint, imageID *uint) error {
+	if imageID == nil {
+		return nil
+	}
+	var media models.Media
+	if err := database.Where("id = ? AND workspace_id = ?", *imageID, workspaceID).First(&media).Error; err != nil {
+		return invalid("image %d not found in this workspace", *imageID)
+	}
+	if !strings.HasPrefix(media.MimeType, "image/") {
+		return invalid("media %d is not an image", *imageID)
+	}
+	return nil
+}
+
+// Check trims and normalizes the SEO settings of a content item and rejects
+// values that cannot be rendered
+func Check(database *db.DB, workspaceID uint, s *models.SEO) error {
+	s.MetaTitle = strings.TrimSpace(s.MetaTitle)
+	s.MetaDescription = strings.TrimSpace(s.MetaDescription)
+	s.CanonicalURL = strings.TrimSpace(s.CanonicalURL)
+
+	if s.CanonicalURL != "" {
+		if err := checkURL("canonical_url", s.CanonicalURL); err != nil {
+			return err
+		}
+	}
+
+	robots, err := NormalizeRobots(s.Robots)
+	if err != nil {
+		return err
+	}
+	s.Robots = robots
+
+	return checkImage(database, workspaceID, s.ImageID)
+}
+
+// CheckDefaults trims and normalizes the SEO defaults of a workspace and rejects
+// values that cannot be rendered
+func CheckDefaults(database *db.DB, workspaceID uint, d *models.SEODefaults) error {
+	d.SiteName = strings.TrimSpace(d.SiteName)
+	d.BaseURL = strings.TrimRight(strings.TrimSpace(d.BaseURL), "/")
+	d.TitleTemplate = strings.TrimSpace(d.TitleTemplate)
+	d.MetaDescription = strings.TrimSpace(d.MetaDescription)
+	d.TwitterSite = strings.TrimSpace(d.TwitterSite)
+
+	if d.BaseURL != "" {
+		if err := checkURL("base_url", d.BaseURL); err != nil {
+			return err
+		}
+	}
+	if d.TitleTemplate != "" && strings.Count(d.TitleTemplate, "%s") != 1 {
+		return invalid("title_template must contain %%s exactly once")
+	}
+	if d.TwitterSite != "" && !strings.HasPrefix(d.TwitterSite, "@") {
+		d.TwitterSite = "@" + d.TwitterSite
+	}
+
+	robots, err := NormalizeRobots(d.Robots)
+	if err != nil {
+		return err
+	}
+	d.Robots = robots
+
+	return checkImage(database, workspaceID, d.ImageID)
+}
+
+// Meta holds the resolved SEO metadata of a content item
+type Meta struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	Robots       string `json:"robots,omitempty"`
+	ImageURL     string `json:"image_url,omitempty"`
+	SiteName     string `json:"site_name,omitempty"`
+	TwitterSite  string `json:"twitter_site,omitempty"`
+	PublishedAt  string `json:"published_at,omitempty"`
+	ModifiedAt   string `json:"modified_at,omitempty"`
+}
+
+var markup = regexp.MustCompile(`<[^>]*>|!?\[([^\]]*)\]\([^)]*\)|[#*_>` + "`" + `~|]`)
+
+// Excerpt returns the start of a body as plain text, cut at a word boundary
+func Excerpt(body string, length int) string {
+	text := strings.Join(strings.Fields(markup.ReplaceAllString(body, " $1 ")), " ")
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	cut := string(runes[:length-1])
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// PageTitle returns the meta title of a content item before the workspace template is applied
+func PageTitle(content models.Content) string {
+	if content.SEO.MetaTitle != "" {
+		return content.SEO.MetaTitle
+	}
+	return content.Title
+}
+
+// ImageID returns the media item shared for a content item, falling back to the workspace default
+func ImageID(content models.Content, workspace models.Workspace) *uint {
+	if content.SEO.ImageID != nil {
+		return content.SEO.ImageID
+	}
+	return workspace.SEO.ImageID
+}
+
+// Resolve combines the SEO settings of a content item with the workspace defaults.
+// imageURL is the public URL of the image returned by ImageID, if any.
+func Resolve(content models.Content, workspace models.Workspace, imageURL string) Meta {
+	defaults := workspace.SEO
+
+	meta := Meta{
+		Title:        PageTitle(content),
+		Description:  content.SEO.MetaDescription,
+		CanonicalURL: content.SEO.CanonicalURL,
+		Robots:       content.SEO.Robots,
+		ImageURL:     imageURL,
+		SiteName:     defaults.SiteName,
+		TwitterSite:  defaults.TwitterSite,
+		ModifiedAt:   content.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+
+	if defaults.TitleTemplate != "" {
+		meta.Title = strings.Replace(defaults.TitleTemplate, "%s", meta.Title, 1)
+	}
+	if meta.Description == "" {
+		meta.Description = Excerpt(content.Body, DescriptionMaxLength)
+	}
+	if meta.Description == "" {
+		meta.Description = defaults.MetaDescription
+	}
+	if meta.CanonicalURL == "" && defaults.BaseURL != "" {
+		meta.CanonicalURL = defaults.BaseURL + "/" + content.Slug
+	}
+	if meta.Robots == "" {
+		meta.Robots = defaults.Robots
+	}
+	if meta.SiteName == "" {
+		meta.SiteName = workspace.Name
+	}
+	if content.PublishedAt != nil {
+		meta.PublishedAt = content.PublishedAt.UTC().Format(time.RFC3339)
+	}
+
+	return meta
+}
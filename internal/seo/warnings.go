@@ -0,0 +1,137 @@
+// internal/seo/warnings.go
+package seo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// Warning codes reported by Validate
+const (
+	WarningTitleTooLong        = "title_too_long"
+	WarningDescriptionMissing  = "description_missing"
+	WarningDescriptionTooShort = "description_too_short"
+	WarningDescriptionTooLong  = "description_too_long"
+	WarningImageMissing        = "image_missing"
+	WarningDuplicateTitle      = "duplicate_title"
+	WarningCanonicalMissing    = "canonical_missing"
+)
+
+// Warning is an SEO problem that does not stop a content item from being saved
+type Warning struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// titleKey is the form in which meta titles are compared for duplicates
+func titleKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// titleIndex maps the meta titles of a workspace to the content items using them
+type titleIndex map[string][]uint
+
+// loadTitles indexes the meta titles of every content item of a workspace
+func loadTitles(database *db.DB, workspaceID uint) (titleIndex, error) {
+	var contents []models.Content
+	if err := database.Select("id", "title", "seo").Where("workspace_id = ?", workspaceID).Find(&contents).Error; err != nil {
+		return nil, err
+	}
+
+	index := titleIndex{}
+	for _, content := range contents {
+		key := titleKey(PageTitle(content))
+		index[key] = append(index[key], content.ID)
+	}
+	return index, nil
+}
+
+// check reports the warnings of a content item against an index of the workspace titles
+func (index titleIndex) check(content models.Content, workspace models.Workspace) []Warning {
+	warnings := []Warning{}
+	add := func(field, code, format string, args ...interface{}) {
+		warnings = append(warnings, Warning{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	m := Resolve(content, workspace, "")
+
+	if n := len([]rune(m.Title)); n > TitleMaxLength {
+		add("meta_title", WarningTitleTooLong, "Title is %d characters long; search engines show about %d", n, TitleMaxLength)
+	}
+
+	switch n := len([]rune(content.SEO.MetaDescription)); {
+	case n == 0:
+		add("meta_description", WarningDescriptionMissing, "No meta description is set; an excerpt of the body is used instead")
+	case n < DescriptionMinLength:
+		add("meta_description", WarningDescriptionTooShort, "Meta description is %d characters long; aim for at least %d", n, DescriptionMinLength)
+	case n > DescriptionMaxLength:
+		add("meta_description", WarningDescriptionTooLong, "Meta description is %d characters long; search engines show about %d", n, DescriptionMaxLength)
+	}
+
+	if ImageID(content, workspace) == nil {
+		add("image_id", WarningImageMissing, "No social sharing image is set for this item or its workspace")
+	}
+
+	if m.CanonicalURL == "" {
+		add("canonical_url", WarningCanonicalMissing, "No canonical URL is set and the workspace has no base URL")
+	}
+
+	others := []string{}
+	for _, id := range index[titleKey(PageTitle(content))] {
+		if id != content.ID {
+			others = append(others, fmt.Sprint(id))
+		}
+	}
+	if len(others) > 0 {
+		add("meta_title", WarningDuplicateTitle, "Title is also used by content %s", strings.Join(others, ", "))
+	}
+
+	return warnings
+}
+
+// Validate reports the SEO warnings of a content item
+func Validate(database *db.DB, content models.Content, workspace models.Workspace) ([]Warning, error) {
+	index, err := loadTitles(database, content.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	return index.check(content, workspace), nil
+}
+
+// Report lists the SEO warnings of one content item
+type Report struct {
+	ContentID uint      `json:"content_id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	Warnings  []Warning `json:"warnings"`
+}
+
+// Audit reports the SEO warnings of every content item of a workspace that has any
+func Audit(database *db.DB, workspace models.Workspace) ([]Report, error) {
+	index, err := loadTitles(database, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []models.Content
+	if err := database.Where("workspace_id = ?", workspace.ID).Order("id").Find(&contents).Error; err != nil {
+		return nil, err
+	}
+
+	reports := []Report{}
+	for _, content := range contents {
+		if warnings := index.check(content, workspace); len(warnings) > 0 {
+			reports = append(reports, Report{
+				ContentID: content.ID,
+				Title:     content.Title,
+				Status:    content.Status,
+				Warnings:  warnings,
+			})
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,275 @@
+// internal/storage/s3.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/randilt/floe-cms/internal/config"
+)
+
+// s3Timeout bounds the requests made to the object store outside a request context
+const s3Timeout = 10 * time.Second
+
+// S3Storage implements storage operations on an S3-compatible object store
+type S3Storage struct {
+	client        *minio.Client
+	bucket        string
+	prefix        string
+	publicURL     string
+	presignExpiry time.Duration
+	proxy         bool
+	signer        *Signer
+}
+
+// NewS3Storage connects to the bucket described by the configuration. Without
+// static credentials, the standard AWS and MinIO environment variables and the
+// instance role are tried in turn.
+func NewS3Storage(cfg config.S3Config, signer *Signer) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("storage.s3.bucket is required")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid storage.s3.endpoint: %q", cfg.Endpoint)
+	}
+
+	var creds *credentials.Credentials
+	if cfg.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.IAM{},
+		})
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       endpoint.Scheme != "http",
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach bucket %s: %v", cfg.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", cfg.Bucket)
+	}
+
+	return &S3Storage{
+		client:        client,
+		bucket:        cfg.Bucket,
+		prefix:        strings.Trim(cfg.Prefix, "/"),
+		publicURL:     strings.TrimRight(cfg.PublicURL, "/"),
+		presignExpiry: time.Duration(cfg.PresignExpiry) * time.Second,
+		proxy:         cfg.Proxy,
+		signer:        signer,
+	}, nil
+}
+
+// key returns the object key of a stored path
+func (s *S3Storage) key(path string) string {
+	if s.prefix == "" {
+		return path
+	}
+	return s.prefix + "/" + path
+}
+
+// Save uploads a file to the bucket
+func (s *S3Storage) Save(file multipart.File, header *multipart.FileHeader, userID uint) (string, string, error) {
+	path := newPath(header, userID)
+
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.key(path), file, header.Size, minio.PutObjectOptions{
+		ContentType: header.Header.Get("Content-Type"),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload file: %v", err)
+	}
+
+	return header.Filename, path, nil
+}
+
+// Put uploads a generated file to the bucket
+func (s *S3Storage) Put(path string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.key(path), r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %v", err)
+	}
+	return nil
+}
+
+// Open streams a file from the bucket
+func (s *S3Storage) Open(path string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, s.key(path), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch file: %v", err)
+	}
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch file: %v", err)
+	}
+	return object, nil
+}
+
+// Delete removes a file from the bucket
+func (s *S3Storage) Delete(path string) error {
+	if path == "" {
+		return errors.New("empty file path")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3Timeout)
+	defer cancel()
+	if err := s.client.RemoveObject(ctx, s.bucket, s.key(path), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete file: %v", err)
+	}
+	return nil
+}
+
+// DeleteDir removes every object below a key prefix of the bucket
+func (s *S3Storage) DeleteDir(dir string) error {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return errors.New("empty directory path")
+	}
+
+	ctx := context.Background()
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.key(dir) + "/", Recursive: true}) {
+			if object.Err != nil {
+				slog.Error("Failed to list files", "dir", dir, "error", object.Err)
+				return
+			}
+			objects <- object
+		}
+	}()
+
+	// Results are drained to the end so the listing is never left blocked
+	var err error
+	for result := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil && err == nil {
+			err = fmt.Errorf("failed to delete file %s: %v", result.ObjectName, result.Err)
+		}
+	}
+	return err
+}
+
+// CheckPrivate rejects private media when the bucket is linked at a public
+// URL, since private files would be readable there like any other
+func (s *S3Storage) CheckPrivate() error {
+	if s.publicURL != "" && !s.proxy {
+		return errors.New("private media cannot be stored while storage.s3.public_url links the bucket publicly; " +
+			"set storage.s3.proxy or leave public_url empty")
+	}
+	return nil
+}
+
+// GetURL returns the URL for a file: below /uploads/ when files are proxied,
+// at the public URL when one is configured, and presigned otherwise. Private
+// files are never linked at the public URL; their URLs expire with the
+// private URL expiry.
+func (s *S3Storage) GetURL(path string, private bool) string {
+	if s.proxy {
+		if private {
+			return s.signer.URL(path)
+		}
+		return "/uploads/" + path
+	}
+	if s.publicURL != "" && !private {
+		return s.publicURL + "/" + s.key(path)
+	}
+
+	expiry := s.presignExpiry
+	if private {
+		expiry = s.signer.Expiry()
+	}
+	u, err := s.client.PresignedGetObject(context.Background(), s.bucket, s.key(path), expiry, nil)
+	if err != nil {
+		slog.Error("Failed to presign file URL", "path", path, "error", err)
+		if private {
+			return s.signer.URL(path)
+		}
+		return "/uploads/" + path
+	}
+	return u.String()
+}
+
+// Signer returns the signer of private file URLs
+func (s *S3Storage) Signer() *Signer {
+	return s.signer
+}
+
+// Handler streams files from the bucket when they are proxied, and redirects
+// to their URL otherwise
+func (s *S3Storage) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == "" || strings.Contains(path, "..") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Requests for private files only get here with a valid signature
+		if !s.proxy {
+			http.Redirect(w, r, s.GetURL(path, Signed(r.URL.Query())), http.StatusFound)
+			return
+		}
+
+		object, err := s.client.GetObject(r.Context(), s.bucket, s.key(path), minio.GetObjectOptions{})
+		if err != nil {
+			http.Error(w, "Failed to fetch file", http.StatusBadGateway)
+			return
+		}
+		defer object.Close()
+
+		info, err := object.Stat()
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, "Failed to fetch file", http.StatusBadGateway)
+			return
+		}
+
+		if info.ContentType != "" {
+			w.Header().Set("Content-Type", info.ContentType)
+		}
+		if info.ETag != "" {
+			w.Header().Set("ETag", `"`+info.ETag+`"`)
+		}
+		http.ServeContent(w, r, path, info.LastModified, object)
+	})
+}
@@ -0,0 +1,307 @@
+// internal/storage/s3_test.go
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+
+	"github.com/randilt/floe-cms/internal/config"
+)
+
+const testBucket = "media"
+
+// newTestS3 returns S3 storage on a fresh in-process S3 stand-in, along with
+// the URL of the stand-in
+func newTestS3(t *testing.T, cfg config.S3Config) (*S3Storage, string) {
+	t.Helper()
+
+	backend := s3mem.New()
+	if err := backend.CreateBucket(testBucket); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	server := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(server.Close)
+
+	cfg.Endpoint = server.URL
+	cfg.Region = "us-east-1"
+	cfg.Bucket = testBucket
+	cfg.PathStyle = true
+	cfg.AccessKeyID = "test"
+	cfg.SecretAccessKey = "test-secret"
+	if cfg.PresignExpiry == 0 {
+		cfg.PresignExpiry = 3600
+	}
+
+	signer := NewSigner(config.StorageConfig{URLSecret: "secret", PrivateURLExpiry: 600}, "")
+	s, err := NewS3Storage(cfg, signer)
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return s, server.URL
+}
+
+// upload returns a file as it arrives in a multipart form
+func upload(t *testing.T, filename, contentType, body string) (multipart.File, *multipart.FileHeader) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreatePart(map[string][]string{
+		"Content-Disposition": {`form-data; name="file"; filename="` + filename + `"`},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte(body))
+	mw.Close()
+
+	form, err := multipart.NewReader(&buf, mw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+
+	header := form.File["file"][0]
+	file, err := header.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file, header
+}
+
+// read returns the contents of a stored file
+func read(t *testing.T, s *S3Storage, path string) string {
+	t.Helper()
+	r, err := s.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", path, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %q: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestS3SaveOpen(t *testing.T) {
+	s, _ := newTestS3(t, config.S3Config{Prefix: "/site/"})
+
+	file, header := upload(t, "photo.jpg", "image/jpeg", "jpeg data")
+	name, path, err := s.Save(file, header, 7)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if name != "photo.jpg" {
+		t.Errorf("Save name = %q, want photo.jpg", name)
+	}
+	if !strings.HasSuffix(path, ".jpg") || !strings.Contains(path, "/7_") {
+		t.Errorf("Save path = %q, want a .jpg path of user 7", path)
+	}
+	if got := read(t, s, path); got != "jpeg data" {
+		t.Errorf("saved file = %q, want jpeg data", got)
+	}
+
+	if err := s.Put("variants/1/thumb.webp", strings.NewReader("webp data"), 9, "image/webp"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := read(t, s, "variants/1/thumb.webp"); got != "webp data" {
+		t.Errorf("put file = %q, want webp data", got)
+	}
+
+	// Objects are stored below the prefix
+	if _, err := s.client.StatObject(context.Background(), testBucket, "site/variants/1/thumb.webp", minio.StatObjectOptions{}); err != nil {
+		t.Errorf("object not stored below the prefix: %v", err)
+	}
+
+	if _, err := s.Open("missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	s, _ := newTestS3(t, config.S3Config{})
+
+	for _, path := range []string{"a.jpg", "variants/1/small.webp", "variants/1/large.webp", "variants/10/small.webp"} {
+		if err := s.Put(path, strings.NewReader(path), int64(len(path)), "application/octet-stream"); err != nil {
+			t.Fatalf("Put(%q): %v", path, err)
+		}
+	}
+
+	if err := s.Delete("a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Open("a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(""); err == nil {
+		t.Error("Delete(\"\") succeeded, want an error")
+	}
+
+	if err := s.DeleteDir("/variants/1/"); err != nil {
+		t.Fatalf("DeleteDir: %v", err)
+	}
+	for _, path := range []string{"variants/1/small.webp", "variants/1/large.webp"} {
+		if _, err := s.Open(path); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) after DeleteDir error = %v, want ErrNotFound", path, err)
+		}
+	}
+	// A directory sharing a name prefix is not part of it
+	if got := read(t, s, "variants/10/small.webp"); got != "variants/10/small.webp" {
+		t.Errorf("sibling directory file = %q, want it kept", got)
+	}
+	if err := s.DeleteDir("/"); err == nil {
+		t.Error("DeleteDir(\"/\") succeeded, want an error")
+	}
+}
+
+func TestS3GetURL(t *testing.T) {
+	t.Run("public", func(t *testing.T) {
+		s, _ := newTestS3(t, config.S3Config{Prefix: "site", PublicURL: "https://cdn.example.com/"})
+
+		if got, want := s.GetURL("a.jpg", false), "https://cdn.example.com/site/a.jpg"; got != want {
+			t.Errorf("GetURL = %q, want %q", got, want)
+		}
+		// Private files are never linked at the public URL
+		if got := s.GetURL("a.jpg", true); strings.HasPrefix(got, "https://cdn.example.com/") {
+			t.Errorf("private GetURL = %q, want a presigned URL", got)
+		}
+	})
+
+	t.Run("private media", func(t *testing.T) {
+		tests := []struct {
+			name string
+			cfg  config.S3Config
+			ok   bool
+		}{
+			{name: "public URL", cfg: config.S3Config{PublicURL: "https://cdn.example.com"}},
+			{name: "public URL proxied", cfg: config.S3Config{PublicURL: "https://cdn.example.com", Proxy: true}, ok: true},
+			{name: "presigned", cfg: config.S3Config{}, ok: true},
+		}
+		for _, tt := range tests {
+			s, _ := newTestS3(t, tt.cfg)
+			if err := s.CheckPrivate(); (err == nil) != tt.ok {
+				t.Errorf("%s: CheckPrivate = %v, want ok %v", tt.name, err, tt.ok)
+			}
+		}
+	})
+
+	t.Run("presigned", func(t *testing.T) {
+		s, endpoint := newTestS3(t, config.S3Config{PresignExpiry: 120})
+		if err := s.Put("a.jpg", strings.NewReader("jpeg data"), 9, "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		for _, private := range []bool{false, true} {
+			raw := s.GetURL("a.jpg", private)
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("GetURL = %q: %v", raw, err)
+			}
+			if !strings.HasPrefix(raw, endpoint+"/"+testBucket+"/a.jpg?") || u.Query().Get("X-Amz-Signature") == "" {
+				t.Errorf("GetURL(private=%v) = %q, want a presigned URL", private, raw)
+			}
+			want := "120"
+			if private {
+				want = "600"
+			}
+			if got := u.Query().Get("X-Amz-Expires"); got != want {
+				t.Errorf("GetURL(private=%v) expires in %s, want %s", private, got, want)
+			}
+
+			resp, err := http.Get(raw)
+			if err != nil {
+				t.Fatalf("GET %s: %v", raw, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != "jpeg data" {
+				t.Errorf("GET presigned URL = %d %q, want the file", resp.StatusCode, body)
+			}
+		}
+	})
+
+	t.Run("proxied", func(t *testing.T) {
+		s, _ := newTestS3(t, config.S3Config{PublicURL: "https://cdn.example.com", Proxy: true})
+
+		if got := s.GetURL("2024/01/a.jpg", false); got != "/uploads/2024/01/a.jpg" {
+			t.Errorf("GetURL = %q, want /uploads/2024/01/a.jpg", got)
+		}
+		raw := s.GetURL("2024/01/a.jpg", true)
+		u, err := url.Parse(raw)
+		if err != nil || u.Path != "/uploads/2024/01/a.jpg" || !s.Signer().Verify("2024/01/a.jpg", u.Query()) {
+			t.Errorf("private GetURL = %q, want a signed /uploads/ URL", raw)
+		}
+	})
+}
+
+func TestS3Handler(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		s, _ := newTestS3(t, config.S3Config{Prefix: "site", Proxy: true})
+		if err := s.Put("a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		handler := s.Handler()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+			t.Errorf("GET /a.txt = %d %q, want the file", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/plain" {
+			t.Errorf("Content-Type = %q, want text/plain", got)
+		}
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Fatal("no ETag")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/a.txt", nil)
+		req.Header.Set("If-None-Match", etag)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("conditional GET = %d, want 304", rec.Code)
+		}
+
+		for _, path := range []string{"/missing.txt", "/../a.txt", "/"} {
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com"+path, nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d, want 404", path, rec.Code)
+			}
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		s, endpoint := newTestS3(t, config.S3Config{PublicURL: "https://cdn.example.com"})
+		handler := s.Handler()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://cdn.example.com/a.txt" {
+			t.Errorf("GET /a.txt = %d to %q, want a redirect to the public URL", rec.Code, rec.Header().Get("Location"))
+		}
+
+		// Signed requests are for private files, which get presigned URLs
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a.txt?signature=x&expires=1", nil))
+		if location := rec.Header().Get("Location"); rec.Code != http.StatusFound || !strings.HasPrefix(location, endpoint+"/") {
+			t.Errorf("signed GET /a.txt = %d to %q, want a redirect to a presigned URL", rec.Code, location)
+		}
+	})
+}
@@ -6,53 +6,101 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/randilt/floe-cms/internal/config"
 	"github.com/randilt/floe-cms/internal/utils"
 )
 
+// Storage backends selected by storage.type
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+// ErrNotFound is returned when opening a file that is not stored
+var ErrNotFound = errors.New("file not found")
+
 // Manager defines the interface for storage operations
 type Manager interface {
 	Save(file multipart.File, header *multipart.FileHeader, userID uint) (string, string, error)
+	// Put stores a generated file at the given path, replacing any file there
+	Put(path string, r io.Reader, size int64, contentType string) error
+	// Open returns the contents of a stored file, or ErrNotFound
+	Open(path string) (io.ReadCloser, error)
 	Delete(path string) error
-	GetURL(path string) string
+	// DeleteDir removes every file below a directory
+	DeleteDir(dir string) error
+	// GetURL returns the URL of a stored file. Private files get a signed URL
+	// that expires.
+	GetURL(path string, private bool) string
+	// Signer returns the signer of private file URLs
+	Signer() *Signer
+	// CheckPrivate returns an error if files of private media stored here
+	// could be read by anyone, in which case private media must not be stored
+	CheckPrivate() error
+	// Handler serves stored files by path, mounted below /uploads/
+	Handler() http.Handler
+}
+
+// New creates the storage manager selected by the configuration. Private
+// file URLs are signed with a secret derived from the JWT secret unless one
+// is configured.
+func New(cfg config.StorageConfig, jwtSecret string) (Manager, error) {
+	signer := NewSigner(cfg, jwtSecret)
+	switch cfg.Type {
+	case TypeLocal, "":
+		return NewLocalStorage(cfg.UploadsDir, signer), nil
+	case TypeS3:
+		return NewS3Storage(cfg.S3, signer)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Type)
+	}
+}
+
+// newPath returns a unique path for an uploaded file, in a subdirectory per day
+func newPath(header *multipart.FileHeader, userID uint) string {
+	ext := filepath.Ext(header.Filename)
+	filename := fmt.Sprintf("%d_%s%s", userID, utils.GenerateRandomString(16), ext)
+
+	now := time.Now()
+	return fmt.Sprintf("%d/%02d/%02d/%s", now.Year(), now.Month(), now.Day(), filename)
 }
 
 // LocalStorage implements storage operations on local filesystem
 type LocalStorage struct {
 	uploadsDir string
+	signer     *Signer
 }
 
 // NewLocalStorage creates a new local storage manager
-func NewLocalStorage(uploadsDir string) *LocalStorage {
+func NewLocalStorage(uploadsDir string, signer *Signer) *LocalStorage {
 	// Create uploads directory if it doesn't exist
 	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
 		panic(fmt.Errorf("failed to create uploads directory: %v", err))
 	}
 	return &LocalStorage{
 		uploadsDir: uploadsDir,
+		signer:     signer,
 	}
 }
 
 // Save saves a file to the local filesystem
 func (ls *LocalStorage) Save(file multipart.File, header *multipart.FileHeader, userID uint) (string, string, error) {
-	// Generate a unique filename
-	ext := filepath.Ext(header.Filename)
-	filename := fmt.Sprintf("%d_%s%s", userID, utils.GenerateRandomString(16), ext)
-
-	// Create a subdirectory based on current date
-	now := time.Now()
-	subdir := fmt.Sprintf("%d/%02d/%02d", now.Year(), now.Month(), now.Day())
-	fullDir := filepath.Join(ls.uploadsDir, subdir)
+	// Generate a unique path in a subdirectory based on current date
+	relativePath := newPath(header, userID)
+	fullDir := filepath.Join(ls.uploadsDir, filepath.Dir(relativePath))
 	
 	if err := os.MkdirAll(fullDir, 0755); err != nil {
 		return "", "", fmt.Errorf("failed to create directory: %v", err)
 	}
 
 	// Create the full file path
-	fullPath := filepath.Join(fullDir, filename)
+	fullPath := filepath.Join(ls.uploadsDir, relativePath)
 	
 	// Create the destination file
 	dst, err := os.Create(fullPath)
@@ -67,10 +115,37 @@ func (ls *LocalStorage) Save(file multipart.File, header *multipart.FileHeader,
 	}
 
 	// Return the relative path for storage in the database
-	relativePath := filepath.Join(subdir, filename)
 	return header.Filename, relativePath, nil
 }
 
+// Put writes a generated file to the local filesystem
+func (ls *LocalStorage) Put(path string, r io.Reader, size int64, contentType string) error {
+	fullPath := filepath.Join(ls.uploadsDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("failed to copy file: %v", err)
+	}
+	return nil
+}
+
+// Open opens a file of the local filesystem
+func (ls *LocalStorage) Open(path string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(ls.uploadsDir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
 // Delete deletes a file from the local filesystem
 func (ls *LocalStorage) Delete(path string) error {
 	if path == "" {
@@ -92,7 +167,36 @@ func (ls *LocalStorage) Delete(path string) error {
 	return nil
 }
 
-// GetURL returns the URL for a file
-func (ls *LocalStorage) GetURL(path string) string {
+// DeleteDir deletes a directory of the local filesystem
+func (ls *LocalStorage) DeleteDir(dir string) error {
+	if strings.Trim(dir, "/") == "" {
+		return errors.New("empty directory path")
+	}
+	if err := os.RemoveAll(filepath.Join(ls.uploadsDir, filepath.FromSlash(dir))); err != nil {
+		return fmt.Errorf("failed to delete directory: %v", err)
+	}
+	return nil
+}
+
+// GetURL returns the URL for a file, signed when it is private
+func (ls *LocalStorage) GetURL(path string, private bool) string {
+	if private {
+		return ls.signer.URL(path)
+	}
 	return "/uploads/" + path
-}
\ No newline at end of file
+}
+
+// Signer returns the signer of private file URLs
+func (ls *LocalStorage) Signer() *Signer {
+	return ls.signer
+}
+
+// CheckPrivate accepts private media, since local files are only served through /uploads/
+func (ls *LocalStorage) CheckPrivate() error {
+	return nil
+}
+
+// Handler serves files from the uploads directory
+func (ls *LocalStorage) Handler() http.Handler {
+	return http.FileServer(http.Dir(ls.uploadsDir))
+}
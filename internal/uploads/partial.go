@@ -0,0 +1,304 @@
+// internal/uploads/partial.go
+package uploads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/utils"
+)
+
+// ExpiryInterval is how often expired resumable uploads are removed
+const ExpiryInterval = 15 * time.Minute
+
+// PartialsDir is the storage directory holding the chunks of resumable
+// uploads until they complete. It is never served.
+const PartialsDir = "partials"
+
+// ErrClaimed is returned when another request wrote to, completed or deleted
+// an upload first
+var ErrClaimed = errors.New("upload was changed by another request")
+
+// Partials keeps the chunks of resumable uploads in storage until they
+// complete, so that every server sees the same uploads. Which request may
+// write to an upload is settled in the database.
+type Partials struct {
+	store  storage.Manager
+	expiry time.Duration
+}
+
+// NewPartials returns resumable uploads kept in storage, which expire after
+// the given number of hours without being written to
+func NewPartials(store storage.Manager, expiry int) *Partials {
+	return &Partials{store: store, expiry: time.Duration(expiry) * time.Hour}
+}
+
+// dir returns the storage directory of the chunks of an upload
+func dir(id uint) string {
+	return PartialsDir + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// Expires returns when an upload written to now expires
+func (p *Partials) Expires() time.Time {
+	return time.Now().Add(p.expiry)
+}
+
+// Chunk is the data of a request to a resumable upload, held in a temporary
+// file until it is stored
+type Chunk struct {
+	file *os.File
+	Size int64
+}
+
+// Receive reads a chunk. It returns what arrived even when reading fails, so
+// that the client can resume after it.
+func Receive(r io.Reader) (*Chunk, error) {
+	file, err := os.CreateTemp("", "floe-chunk-*")
+	if err != nil {
+		return nil, err
+	}
+	chunk := &Chunk{file: file}
+	chunk.Size, err = io.Copy(file, r)
+	return chunk, err
+}
+
+// Close deletes the temporary file of a chunk
+func (c *Chunk) Close() error {
+	c.file.Close()
+	return os.Remove(c.file.Name())
+}
+
+// Append stores a chunk at the offset an upload was received up to, and
+// claims that offset for it. It returns ErrClaimed, keeping nothing, when
+// another request moved the upload past the offset or deleted it.
+func (p *Partials) Append(database *db.DB, upload *models.Upload, chunk *Chunk) error {
+	if chunk.Size == 0 {
+		return nil
+	}
+	if _, err := chunk.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Chunk paths are unique, so that a request losing the claim never
+	// overwrites the chunk of the one winning it
+	filePath := fmt.Sprintf("%s/%020d-%s", dir(upload.ID), upload.Received, utils.GenerateRandomString(8))
+	if err := p.store.Put(filePath, chunk.file, chunk.Size, "application/octet-stream"); err != nil {
+		return err
+	}
+
+	received := upload.Received + chunk.Size
+	expires := p.Expires()
+	err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		result := tx.Model(&models.Upload{}).
+			Where("id = ? AND received = ?", upload.ID, upload.Received).
+			Updates(map[string]interface{}{"received": received, "expires_at": expires})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClaimed
+		}
+		return tx.Create(&models.UploadChunk{
+			UploadID: upload.ID,
+			Start:    upload.Received,
+			Size:     chunk.Size,
+			FilePath: filePath,
+		}).Error
+	})
+	if err != nil {
+		if deleteErr := p.store.Delete(filePath); deleteErr != nil {
+			slog.Warn("Failed to delete unclaimed chunk", "upload_id", upload.ID, "path", filePath, "error", deleteErr)
+		}
+		return err
+	}
+
+	upload.Received = received
+	upload.ExpiresAt = expires
+	return nil
+}
+
+// Complete claims a fully received upload for creating its media. It returns
+// ErrClaimed when the upload is being completed or was completed already.
+// A completion that fails is released with Release; one that never finishes
+// is removed when the upload expires.
+func (p *Partials) Complete(database *db.DB, upload *models.Upload) error {
+	expires := p.Expires()
+	result := database.Model(&models.Upload{}).
+		Where("id = ? AND received = length AND media_id IS NULL AND completing = ?", upload.ID, false).
+		Updates(map[string]interface{}{"completing": true, "expires_at": expires})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrClaimed
+	}
+	upload.ExpiresAt = expires
+	return nil
+}
+
+// Release gives up the completion of an upload, so that it can be retried
+func (p *Partials) Release(database *db.DB, upload models.Upload) error {
+	return database.Model(&models.Upload{}).Where("id = ?", upload.ID).Update("completing", false).Error
+}
+
+// Completed records the media created by an upload and deletes its chunks
+func (p *Partials) Completed(database *db.DB, upload *models.Upload, mediaID uint) error {
+	err := database.Model(&models.Upload{}).Where("id = ?", upload.ID).
+		Updates(map[string]interface{}{"media_id": mediaID, "completing": false}).Error
+	if err != nil {
+		return err
+	}
+	upload.MediaID = &mediaID
+	return p.Clear(database, *upload)
+}
+
+// assembled is a received upload put back together from its chunks in a
+// temporary file, which is deleted when it is closed
+type assembled struct {
+	*os.File
+}
+
+// Close closes and deletes the file
+func (a assembled) Close() error {
+	a.File.Close()
+	return os.Remove(a.Name())
+}
+
+// Open returns a fully received upload, assembled from its chunks
+func (p *Partials) Open(database *db.DB, upload models.Upload) (multipart.File, error) {
+	var chunks []models.UploadChunk
+	if err := database.Where("upload_id = ?", upload.ID).Order("start").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "floe-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	a := &assembled{File: file}
+
+	var offset int64
+	for _, chunk := range chunks {
+		if chunk.Start != offset {
+			a.Close()
+			return nil, fmt.Errorf("upload %d is missing data at %d", upload.ID, offset)
+		}
+		if err := p.copyChunk(a.File, chunk); err != nil {
+			a.Close()
+			return nil, err
+		}
+		offset += chunk.Size
+	}
+	if offset != upload.Length {
+		a.Close()
+		return nil, fmt.Errorf("upload %d has %d of %d bytes", upload.ID, offset, upload.Length)
+	}
+
+	if _, err := a.Seek(0, io.SeekStart); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// copyChunk appends a stored chunk to a file
+func (p *Partials) copyChunk(file *os.File, chunk models.UploadChunk) error {
+	r, err := p.store.Open(chunk.FilePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	n, err := io.Copy(file, r)
+	if err == nil && n != chunk.Size {
+		err = fmt.Errorf("chunk %s has %d of %d bytes", chunk.FilePath, n, chunk.Size)
+	}
+	return err
+}
+
+// Clear deletes the chunks of an upload, which is kept
+func (p *Partials) Clear(database *db.DB, upload models.Upload) error {
+	if err := database.Unscoped().Where("upload_id = ?", upload.ID).Delete(&models.UploadChunk{}).Error; err != nil {
+		return err
+	}
+	return p.store.DeleteDir(dir(upload.ID))
+}
+
+// Cancel deletes an upload along with its chunks. It returns ErrClaimed when
+// the upload is being completed or is gone.
+func (p *Partials) Cancel(database *db.DB, upload models.Upload) error {
+	return p.remove(database, upload, "completing = ?", false)
+}
+
+// Remove deletes an upload claimed for completion along with its chunks
+func (p *Partials) Remove(database *db.DB, upload models.Upload) error {
+	return p.remove(database, upload, "completing = ?", true)
+}
+
+// remove deletes an upload matching a condition along with its chunks, or
+// returns ErrClaimed
+func (p *Partials) remove(database *db.DB, upload models.Upload, query string, args ...interface{}) error {
+	err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("id = ?", upload.ID).Where(query, args...).Delete(&models.Upload{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClaimed
+		}
+		return tx.Unscoped().Where("upload_id = ?", upload.ID).Delete(&models.UploadChunk{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// A chunk stored after this is deleted by the request storing it, which
+	// can no longer claim it
+	return p.store.DeleteDir(dir(upload.ID))
+}
+
+// Expire deletes the uploads that expired, along with their chunks
+func Expire(database *db.DB, partials *Partials) {
+	var expired []models.Upload
+	if err := database.Where("expires_at < ?", time.Now()).Find(&expired).Error; err != nil {
+		slog.Error("Failed to fetch expired uploads", "error", err)
+		return
+	}
+
+	for _, upload := range expired {
+		// An upload written to meanwhile no longer expired
+		err := partials.remove(database, upload, "expires_at < ?", time.Now())
+		if err != nil && !errors.Is(err, ErrClaimed) {
+			slog.Error("Failed to delete expired upload", "upload_id", upload.ID, "error", err)
+		}
+	}
+}
+
+// RunExpiry removes expired uploads every interval until the context is done
+func RunExpiry(ctx context.Context, database *db.DB, partials *Partials, interval time.Duration) {
+	Expire(database, partials)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			Expire(database, partials)
+		}
+	}
+}
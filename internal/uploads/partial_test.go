@@ -0,0 +1,261 @@
+// internal/uploads/partial_test.go
+package uploads
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// newTestPartials returns resumable uploads kept in local storage of a
+// temporary directory, along with the directory
+func newTestPartials(t *testing.T) (*Partials, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store := storage.NewLocalStorage(dir, storage.NewSigner(config.StorageConfig{}, "secret"))
+	return NewPartials(store, 24), dir
+}
+
+// newUpload records a resumable upload of length bytes
+func newUpload(t *testing.T, database *db.DB, length int64, expires time.Time) models.Upload {
+	t.Helper()
+	upload := models.Upload{WorkspaceID: 1, UserID: 1, FileName: "notes.txt", Length: length, ExpiresAt: expires}
+	if err := database.Create(&upload).Error; err != nil {
+		t.Fatal(err)
+	}
+	return upload
+}
+
+// receive returns a chunk of data
+func receive(t *testing.T, data string) *Chunk {
+	t.Helper()
+	chunk, err := Receive(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chunk.Close() })
+	return chunk
+}
+
+// chunkFiles returns the names of the stored chunks of an upload in local
+// storage at root
+func chunkFiles(t *testing.T, root string, upload models.Upload) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir(upload.ID))))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestPartialsAppend(t *testing.T) {
+	type write struct {
+		// at is the offset the request was told, and data what it sends
+		at   int64
+		data string
+		err  error
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		// cancel cancels the upload before the writes
+		cancel bool
+		// received is how much was received afterwards, and chunks how many
+		// chunks were kept
+		received int64
+		chunks   int
+	}{
+		{
+			name:     "one chunk",
+			writes:   []write{{at: 0, data: "hello world"}},
+			received: 11, chunks: 1,
+		},
+		{
+			name:     "resumed",
+			writes:   []write{{at: 0, data: "hello "}, {at: 6, data: "world"}},
+			received: 11, chunks: 2,
+		},
+		{
+			name:     "empty chunk",
+			writes:   []write{{at: 0, data: "hello "}, {at: 6, data: ""}},
+			received: 6, chunks: 1,
+		},
+		{
+			// A request that read the upload before another wrote to it
+			name: "offset written by another request",
+			writes: []write{
+				{at: 0, data: "hello "},
+				{at: 0, data: "HELLO ", err: ErrClaimed},
+				{at: 6, data: "world"},
+			},
+			received: 11, chunks: 2,
+		},
+		{
+			name:   "cancelled",
+			cancel: true,
+			writes: []write{{at: 0, data: "hello ", err: ErrClaimed}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := dbtest.New(t)
+			partials, root := newTestPartials(t)
+			upload := newUpload(t, database, 11, partials.Expires())
+
+			if tt.cancel {
+				if err := partials.Cancel(database, upload); err != nil {
+					t.Fatalf("Cancel: %v", err)
+				}
+			}
+			for _, w := range tt.writes {
+				stale := upload
+				stale.Received = w.at
+				if err := partials.Append(database, &stale, receive(t, w.data)); !errors.Is(err, w.err) {
+					t.Fatalf("Append(%d, %q) error = %v, want %v", w.at, w.data, err, w.err)
+				}
+			}
+
+			var stored models.Upload
+			err := database.First(&stored, upload.ID).Error
+			if tt.cancel {
+				if err == nil {
+					t.Error("cancelled upload was kept")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if stored.Received != tt.received {
+				t.Errorf("received = %d, want %d", stored.Received, tt.received)
+			}
+
+			// Chunks losing their claim are not kept
+			var chunks int64
+			database.Model(&models.UploadChunk{}).Where("upload_id = ?", upload.ID).Count(&chunks)
+			if files := chunkFiles(t, root, upload); int(chunks) != tt.chunks || len(files) != tt.chunks {
+				t.Errorf("%d chunks recorded and %d stored, want %d", chunks, len(files), tt.chunks)
+			}
+		})
+	}
+}
+
+func TestPartialsComplete(t *testing.T) {
+	database := dbtest.New(t)
+	partials, root := newTestPartials(t)
+	upload := newUpload(t, database, 11, partials.Expires())
+
+	for _, data := range []string{"hello ", "world"} {
+		if err := partials.Append(database, &upload, receive(t, data)); err != nil {
+			t.Fatalf("Append(%q): %v", data, err)
+		}
+	}
+
+	// An upload is completed by one request at a time
+	if err := partials.Complete(database, &upload); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := partials.Complete(database, &upload); !errors.Is(err, ErrClaimed) {
+		t.Errorf("second Complete error = %v, want ErrClaimed", err)
+	}
+	if err := partials.Cancel(database, upload); !errors.Is(err, ErrClaimed) {
+		t.Errorf("Cancel while completing error = %v, want ErrClaimed", err)
+	}
+
+	file, err := partials.Open(database, upload)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(data) != "hello world" {
+		t.Errorf("assembled upload = %q (%v), want hello world", data, err)
+	}
+
+	// A released upload can be completed again
+	if err := partials.Release(database, upload); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := partials.Complete(database, &upload); err != nil {
+		t.Fatalf("Complete after Release: %v", err)
+	}
+
+	if err := partials.Completed(database, &upload, 7); err != nil {
+		t.Fatalf("Completed: %v", err)
+	}
+	if files := chunkFiles(t, root, upload); len(files) != 0 {
+		t.Errorf("chunks %v kept after completion", files)
+	}
+	if err := partials.Complete(database, &upload); !errors.Is(err, ErrClaimed) {
+		t.Errorf("Complete of a completed upload error = %v, want ErrClaimed", err)
+	}
+
+	// The upload of completed media can be deleted
+	if err := partials.Cancel(database, upload); err != nil {
+		t.Errorf("Cancel after completion: %v", err)
+	}
+}
+
+func TestPartialsOpenMissingChunk(t *testing.T) {
+	database := dbtest.New(t)
+	partials, _ := newTestPartials(t)
+	upload := newUpload(t, database, 11, partials.Expires())
+
+	if err := partials.Append(database, &upload, receive(t, "hello world")); err != nil {
+		t.Fatal(err)
+	}
+	var chunk models.UploadChunk
+	if err := database.Where("upload_id = ?", upload.ID).First(&chunk).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Model(&chunk).Update("start", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := partials.Open(database, upload); err == nil {
+		t.Error("Open of an upload missing data succeeded")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	database := dbtest.New(t)
+	partials, root := newTestPartials(t)
+
+	expired := newUpload(t, database, 11, time.Now().Add(-time.Minute))
+	current := newUpload(t, database, 11, partials.Expires())
+	for _, upload := range []*models.Upload{&expired, &current} {
+		if err := partials.Append(database, upload, receive(t, "hello ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Writing to an upload renews it, so this one is made to expire afterwards
+	if err := database.Model(&expired).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	Expire(database, partials)
+
+	var ids []uint
+	database.Model(&models.Upload{}).Pluck("id", &ids)
+	if len(ids) != 1 || ids[0] != current.ID {
+		t.Errorf("uploads %v kept, want only %d", ids, current.ID)
+	}
+	if files := chunkFiles(t, root, expired); len(files) != 0 {
+		t.Errorf("chunks %v of the expired upload kept", files)
+	}
+	if files := chunkFiles(t, root, current); len(files) != 1 {
+		t.Errorf("chunks %v of the current upload, want one", files)
+	}
+}
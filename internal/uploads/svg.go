@@ -0,0 +1,220 @@
+// internal/uploads/svg.go
+package uploads
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// svgBlockedElements are dropped from SVGs with everything inside them
+var svgBlockedElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+	"audio":         true,
+	"video":         true,
+}
+
+// svgBlockedStyles betray CSS that runs code or loads other documents
+var svgBlockedStyles = []string{"javascript:", "vbscript:", "expression(", "@import", "-moz-binding", "behavior:"}
+
+// svgSafeData are the data URLs SVG links may hold
+var svgSafeData = []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"}
+
+var (
+	// textEscaper escapes element text, leaving whitespace as written
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	// attrEscaper escapes attribute values written in double quotes
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// compact lowercases a value and drops the whitespace and control characters
+// browsers ignore inside URLs, such as "java\tscript:"
+func compact(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToLower(value))
+}
+
+// unsafeStyle reports whether CSS contains something that runs code
+func unsafeStyle(css string) bool {
+	css = compact(css)
+	for _, blocked := range svgBlockedStyles {
+		if strings.Contains(css, blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeAttr returns why an attribute is unsafe, or ""
+func unsafeAttr(attr xml.Attr) string {
+	name := strings.ToLower(attr.Name.Local)
+	value := compact(attr.Value)
+
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return "event handler " + attr.Name.Local
+	case strings.Contains(value, "javascript:") || strings.Contains(value, "vbscript:"):
+		return "script URL in " + attr.Name.Local
+	case name == "style" && unsafeStyle(attr.Value):
+		return "unsafe style"
+	case name == "href" && strings.HasPrefix(value, "data:"):
+		for _, safe := range svgSafeData {
+			if strings.HasPrefix(value, safe) {
+				return ""
+			}
+		}
+		return "data URL in " + attr.Name.Local
+	}
+	return ""
+}
+
+// qualified returns the name of an element or attribute as written
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// SanitizeSVG removes scripts, event handlers, script URLs, unsafe styles,
+// document type declarations and processing instructions from an SVG. It
+// returns the cleaned SVG and the first active content it removed, or "" if
+// there was none. Data that is not an SVG document is an error.
+func SanitizeSVG(data []byte) ([]byte, string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	var out bytes.Buffer
+	reason := ""
+	found := func(what string) {
+		if reason == "" {
+			reason = what
+		}
+	}
+
+	// skip counts the open elements inside a dropped element
+	skip := 0
+	// inStyle is set inside <style>, whose text is checked as a whole
+	inStyle := false
+	var style bytes.Buffer
+	root := ""
+	// open holds the elements not closed yet, which RawToken does not check
+	open := []xml.Name{}
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			local := strings.ToLower(t.Name.Local)
+			if root == "" {
+				if local != "svg" {
+					return nil, "", errors.New("root element is not svg")
+				}
+				root = local
+			} else if len(open) == 0 {
+				return nil, "", errors.New("more than one root element")
+			}
+			open = append(open, t.Name)
+			if skip > 0 {
+				skip++
+				continue
+			}
+			if svgBlockedElements[local] {
+				found(local + " element")
+				skip = 1
+				continue
+			}
+
+			out.WriteString("<" + qualified(t.Name))
+			for _, attr := range t.Attr {
+				if why := unsafeAttr(attr); why != "" {
+					found(why)
+					continue
+				}
+				out.WriteString(" " + qualified(attr.Name) + `="`)
+				out.WriteString(attrEscaper.Replace(attr.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+			if local == "style" {
+				inStyle = true
+				style.Reset()
+			}
+
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, "", fmt.Errorf("unexpected end element </%s>", qualified(t.Name))
+			}
+			open = open[:len(open)-1]
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if inStyle && strings.EqualFold(t.Name.Local, "style") {
+				inStyle = false
+				if unsafeStyle(style.String()) {
+					found("unsafe style")
+				} else {
+					xml.EscapeText(&out, style.Bytes())
+				}
+			}
+			out.WriteString("</" + qualified(t.Name) + ">")
+
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if inStyle {
+				style.Write(t)
+				continue
+			}
+			out.WriteString(textEscaper.Replace(string(t)))
+
+		case xml.ProcInst:
+			// Only the XML declaration is kept; xml-stylesheet could load code
+			if t.Target == "xml" && root == "" {
+				out.WriteString("<?xml " + string(t.Inst) + "?>")
+			} else if strings.EqualFold(t.Target, "xml-stylesheet") {
+				found("xml-stylesheet instruction")
+			}
+
+		case xml.Directive:
+			// Document type declarations are dropped; those defining entities
+			// are reported, as entities can smuggle markup in
+			if bytes.Contains(bytes.ToUpper(t), []byte("<!ENTITY")) {
+				found("entity declaration")
+			}
+
+		case xml.Comment:
+			// Comments are dropped, as they could hide conditional markup
+		}
+	}
+
+	if root == "" {
+		return nil, "", errors.New("no svg element")
+	}
+	if len(open) > 0 {
+		return nil, "", fmt.Errorf("element <%s> is not closed", qualified(open[len(open)-1]))
+	}
+	return out.Bytes(), reason, nil
+}
@@ -0,0 +1,134 @@
+// internal/uploads/svg_test.go
+package uploads
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name   string
+		svg    string
+		reason string
+		// want and unwanted are fragments the sanitized SVG must and must not contain
+		want     []string
+		unwanted []string
+		err      bool
+	}{
+		{
+			name: "clean",
+			svg:  `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect width="10" height="10" fill="red"/><text>a &amp; b &lt; c</text></svg>`,
+			want: []string{`<?xml version="1.0"?>`, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">`, `<rect width="10" height="10" fill="red"></rect>`, `<text>a &amp; b &lt; c</text>`},
+		},
+		{
+			name:     "script element",
+			svg:      `<svg><script>alert(1)</script><circle r="1"/></svg>`,
+			reason:   "script element",
+			want:     []string{`<circle r="1"></circle>`},
+			unwanted: []string{"script", "alert"},
+		},
+		{
+			name:     "nested blocked elements",
+			svg:      `<svg><foreignObject><div><iframe src="x"></iframe></div></foreignObject><g/></svg>`,
+			reason:   "foreignobject element",
+			want:     []string{"<g></g>"},
+			unwanted: []string{"div", "iframe", "foreignObject"},
+		},
+		{
+			name:     "event handler",
+			svg:      `<svg onload="alert(1)"><rect ONCLICK="x()" width="1"/></svg>`,
+			reason:   "event handler onload",
+			want:     []string{"<svg>", `<rect width="1">`},
+			unwanted: []string{"alert", "x()"},
+		},
+		{
+			name:     "script URL",
+			svg:      `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="java&#9;script:alert(1)"><text>x</text></a></svg>`,
+			reason:   "script URL in href",
+			unwanted: []string{"alert"},
+		},
+		{
+			name:     "data URL",
+			svg:      `<svg><image href="data:image/png;base64,AAAA"/><a href="data:text/html;base64,PHNjcmlwdD4="/></svg>`,
+			reason:   "data URL in href",
+			want:     []string{`<image href="data:image/png;base64,AAAA">`},
+			unwanted: []string{"data:text/html"},
+		},
+		{
+			name:     "unsafe style element",
+			svg:      `<svg><style>@import url(evil.css); rect { fill: red }</style></svg>`,
+			reason:   "unsafe style",
+			want:     []string{"<style></style>"},
+			unwanted: []string{"@import"},
+		},
+		{
+			name: "safe style element",
+			svg:  `<svg><style>rect > g { fill: red }</style></svg>`,
+			want: []string{"<style>rect &gt; g { fill: red }</style>"},
+		},
+		{
+			name:     "unsafe style attribute",
+			svg:      `<svg><rect style="width: expression(alert(1))"/></svg>`,
+			reason:   "unsafe style",
+			unwanted: []string{"expression"},
+		},
+		{
+			name:     "stylesheet instruction",
+			svg:      `<?xml-stylesheet href="evil.xsl"?><svg/>`,
+			reason:   "xml-stylesheet instruction",
+			unwanted: []string{"evil"},
+		},
+		{
+			name:     "entity declaration",
+			svg:      `<!DOCTYPE svg [<!ENTITY x "y">]><svg/>`,
+			reason:   "entity declaration",
+			unwanted: []string{"ENTITY", "DOCTYPE"},
+		},
+		{
+			name:     "comments",
+			svg:      "<!--" + strings.Repeat("padding ", 500) + "--><svg><!-- <script> --></svg>",
+			want:     []string{"<svg></svg>"},
+			unwanted: []string{"padding", "script"},
+		},
+		{name: "not svg", svg: `<html><body/></html>`, err: true},
+		{name: "no element", svg: `just text`, err: true},
+		{name: "mismatched end", svg: `<svg><g></svg>`, err: true},
+		{name: "unclosed", svg: `<svg><g>`, err: true},
+		{name: "second root", svg: `<svg/><p>text</p>`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clean, reason, err := SanitizeSVG([]byte(tt.svg))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("SanitizeSVG = %q, want an error", clean)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SanitizeSVG: %v", err)
+			}
+			if reason != tt.reason {
+				t.Errorf("reason = %q, want %q", reason, tt.reason)
+			}
+			for _, fragment := range tt.want {
+				if !strings.Contains(string(clean), fragment) {
+					t.Errorf("sanitized SVG %q lacks %q", clean, fragment)
+				}
+			}
+			for _, fragment := range tt.unwanted {
+				if strings.Contains(string(clean), fragment) {
+					t.Errorf("sanitized SVG %q still contains %q", clean, fragment)
+				}
+			}
+
+			// Sanitizing is stable
+			again, reason, err := SanitizeSVG(clean)
+			if err != nil || reason != "" || string(again) != string(clean) {
+				t.Errorf("sanitizing again = %q, %q, %v, want it unchanged", again, reason, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+// internal/uploads/uploads.go
+package uploads
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // Image decoders, to check raster uploads
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	_ "golang.org/x/image/webp"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
+)
+
+// mib is the unit of configured size limits
+const mib = 1 << 20
+
+// sniffSize is how much of a file is read to detect its type
+const sniffSize = 3072
+
+// blockedTypes are never accepted, whatever the allowlist says
+var blockedTypes = map[string]string{
+	"application/vnd.microsoft.portable-executable": "executable",
+	"application/x-elf":                             "executable",
+	"application/x-executable":                      "executable",
+	"application/x-sharedlib":                       "executable",
+	"application/x-mach-binary":                     "executable",
+	"application/x-ms-shortcut":                     "executable",
+	"application/java-archive":                      "executable",
+	"application/x-java-applet":                     "executable",
+	"application/vnd.android.package-archive":       "executable",
+	"application/wasm":                              "executable",
+	"application/vnd.ms-htmlhelp":                   "executable",
+	"text/html":                                     "HTML",
+	"application/xhtml+xml":                         "HTML",
+	"text/javascript":                               "script",
+	"text/x-php":                                    "script",
+	"text/x-python":                                 "script",
+	"text/x-perl":                                   "script",
+	"text/x-ruby":                                   "script",
+	"text/x-lua":                                    "script",
+	"text/x-tcl":                                    "script",
+	"text/x-shellscript":                            "script",
+}
+
+// blockedExtensions are never accepted, so that no server or browser treats
+// a stored file as something to run
+var blockedExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".com": true, ".bat": true, ".cmd": true, ".msi": true, ".scr": true,
+	".sh": true, ".ps1": true, ".vbs": true, ".hta": true, ".jar": true, ".apk": true,
+	".php": true, ".phtml": true, ".phar": true, ".asp": true, ".aspx": true, ".jsp": true, ".cgi": true,
+	".js": true, ".mjs": true, ".html": true, ".htm": true, ".xhtml": true, ".shtml": true,
+}
+
+// rasterTypes are the image types that must decode as what they claim to be
+var rasterTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// polyglotMarkers betray scripts or markup hidden in image data
+var polyglotMarkers = [][]byte{
+	[]byte("<script"), []byte("<?php"), []byte("<html"), []byte("<!doctype html"), []byte("<iframe"), []byte("<svg"),
+}
+
+// Error is an upload that failed a check, with the status to answer it with
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// reject returns an Error with a formatted message
+func reject(status int, format string, args ...interface{}) *Error {
+	return &Error{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// Checked is an upload that passed the checks
+type Checked struct {
+	// File and Header are what to store: the content may have been
+	// sanitized, and the Content-Type is the detected one
+	File     multipart.File
+	Header   *multipart.FileHeader
+	MimeType string
+	// Hash is the hex SHA-256 of the content to store
+	Hash string
+	// Sanitized is set when active content was removed from an SVG
+	Sanitized bool
+}
+
+// memoryFile is file content held in memory
+type memoryFile struct {
+	*bytes.Reader
+}
+
+// Close does nothing
+func (memoryFile) Close() error { return nil }
+
+// Checker checks uploaded files against the configured allowlist and limits
+type Checker struct {
+	cfg config.UploadsConfig
+}
+
+// NewChecker creates an upload checker
+func NewChecker(cfg config.UploadsConfig) *Checker {
+	return &Checker{cfg: cfg}
+}
+
+// MaxFileSize returns the size of the largest file any type allows
+func (c *Checker) MaxFileSize() int64 {
+	largest := c.cfg.MaxSize
+	for _, limit := range c.cfg.Limits {
+		largest = max(largest, limit.MaxSize)
+	}
+	return int64(largest) * mib
+}
+
+// MaxRequestSize returns the largest upload request accepted, leaving room
+// for the other form fields
+func (c *Checker) MaxRequestSize() int64 {
+	return c.MaxFileSize() + mib
+}
+
+// NormalizeAllowlist validates and lowercases an allowlist of MIME types,
+// wildcards and file extensions
+func NormalizeAllowlist(entries []string) ([]string, error) {
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case strings.HasPrefix(entry, "."):
+			if len(entry) == 1 || strings.ContainsAny(entry[1:], "./ ") {
+				return nil, fmt.Errorf("invalid file extension: %q", entry)
+			}
+		default:
+			parts := strings.Split(entry, "/")
+			if len(parts) != 2 || parts[0] == "" || parts[0] == "*" || parts[1] == "" {
+				return nil, fmt.Errorf("invalid MIME type: %q", entry)
+			}
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// matches reports whether a MIME type matches a type or wildcard pattern
+func matches(pattern, mimeType string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mimeType
+}
+
+// allowed reports whether a type or extension is on an allowlist
+func allowed(allowlist []string, mimeType, ext string) bool {
+	for _, entry := range allowlist {
+		if strings.HasPrefix(entry, ".") {
+			if entry == ext {
+				return true
+			}
+		} else if matches(entry, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxSize returns the size limit in bytes of a MIME type. Exact types win
+// over wildcards.
+func (c *Checker) maxSize(mimeType string) int64 {
+	size := c.cfg.MaxSize
+	exact := false
+	for _, limit := range c.cfg.Limits {
+		pattern := strings.ToLower(limit.Type)
+		if pattern == mimeType {
+			size, exact = limit.MaxSize, true
+		} else if !exact && matches(pattern, mimeType) {
+			size = limit.MaxSize
+		}
+	}
+	return int64(size) * mib
+}
+
+// related reports whether two MIME types are the same or one is a kind of
+// the other, such as application/json and text/plain, or image/svg+xml and
+// text/xml
+func related(a, b string) bool {
+	if markup(a) && markup(b) {
+		return true
+	}
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		for m := mimetype.Lookup(pair[0]); m != nil; m = m.Parent() {
+			if m.Is(pair[1]) {
+				return true
+			}
+		}
+	}
+	return strings.HasPrefix(a, "text/") && strings.HasPrefix(b, "text/")
+}
+
+// stripMetadata reports whether metadata is removed from the images
+// uploaded to a workspace
+func (c *Checker) stripMetadata(workspace models.Workspace) bool {
+	if workspace.StripImageMetadata != nil {
+		return *workspace.StripImageMetadata
+	}
+	return c.cfg.StripMetadata
+}
+
+// Quota returns the media quotas of a workspace
+func (c *Checker) Quota(workspace models.Workspace) quota.Limits {
+	return quota.Of(c.cfg, workspace)
+}
+
+// markup reports whether browsers render a MIME type as XML, and so run the
+// scripts it holds, as they do for SVG
+func markup(mimeType string) bool {
+	if mimeType == "image/svg+xml" || strings.HasSuffix(mimeType, "+xml") {
+		return true
+	}
+	for m := mimetype.Lookup(mimeType); m != nil; m = m.Parent() {
+		if m.Is("text/xml") {
+			return true
+		}
+	}
+	return false
+}
+
+// admit checks a file type against an allowlist and its size limit
+func (c *Checker) admit(allowlist []string, mimeType, ext string, size int64) error {
+	if !allowed(allowlist, mimeType, ext) {
+		return reject(http.StatusUnsupportedMediaType, "File type %s is not allowed in this workspace", mimeType)
+	}
+	if limit := c.maxSize(mimeType); size > limit {
+		return reject(http.StatusRequestEntityTooLarge, "File is too large: %s uploads are limited to %d MiB", mimeType, limit/mib)
+	}
+	return nil
+}
+
+// Check detects the type of an uploaded file from its content and checks it
+// against the workspace's allowlist, or the configured one when it has none,
+// and the size limit of its type. Raster images must decode and carry no
+// embedded markup, and have their metadata removed unless the workspace keeps
+// it. Files named or detected as SVG or XML are sanitized or rejected when
+// they are SVGs containing scripts, however late the svg element comes.
+func (c *Checker) Check(file multipart.File, header *multipart.FileHeader, workspace models.Workspace) (*Checked, error) {
+	allowlist := workspace.AllowedMediaTypes
+	if len(allowlist) == 0 {
+		allowlist = c.cfg.AllowedTypes
+	}
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	claimed := ""
+	if ext != "" {
+		claimed, _, _ = mime.ParseMediaType(mime.TypeByExtension(ext))
+	}
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if n == 0 {
+		return nil, reject(http.StatusBadRequest, "File is empty")
+	}
+	detected, _, _ := mime.ParseMediaType(mimetype.Detect(head[:n]).String())
+
+	if kind, ok := blockedTypes[detected]; ok {
+		return nil, reject(http.StatusUnsupportedMediaType, "Uploading %s files is not allowed (detected %s)", kind, detected)
+	}
+	if blockedExtensions[ext] {
+		return nil, reject(http.StatusUnsupportedMediaType, "Uploading %s files is not allowed", ext)
+	}
+	if claimed != "" && !related(claimed, detected) {
+		return nil, reject(http.StatusUnsupportedMediaType, "File extension %s does not match its content (detected %s)", ext, detected)
+	}
+	// The svg element may come after more than the sniffed head, so markup
+	// is admitted once the whole document shows whether it is an SVG
+	svg := markup(detected) || markup(claimed)
+	if !svg {
+		if err := c.admit(allowlist, detected, ext, header.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	checked := &Checked{File: file, Header: header, MimeType: detected}
+
+	if rasterTypes[detected] || svg {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %v", err)
+		}
+
+		if rasterTypes[detected] {
+			if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+				return nil, reject(http.StatusUnsupportedMediaType, "File is not a valid %s image", detected)
+			}
+			if marker := polyglot(data); marker != "" {
+				return nil, reject(http.StatusUnsupportedMediaType, "Image contains embedded content (%s) and was rejected", marker)
+			}
+			if c.stripMetadata(workspace) {
+				if data, err = images.StripMetadata(data); err != nil {
+					return nil, reject(http.StatusUnsupportedMediaType, "File is not a valid %s image", detected)
+				}
+			}
+		} else {
+			// Other XML and text are stored as they are
+			clean, reason, invalid := SanitizeSVG(data)
+			if invalid != nil && (detected == "image/svg+xml" || claimed == "image/svg+xml") {
+				return nil, reject(http.StatusUnsupportedMediaType, "File is not a valid SVG image: %v", invalid)
+			}
+			if invalid == nil {
+				detected = "image/svg+xml"
+			}
+			if err := c.admit(allowlist, detected, ext, header.Size); err != nil {
+				return nil, err
+			}
+			if invalid == nil && reason != "" {
+				if !c.cfg.SanitizeSVG {
+					return nil, reject(http.StatusUnsupportedMediaType, "SVG images with active content are not allowed (found %s)", reason)
+				}
+				data, checked.Sanitized = clean, true
+			}
+		}
+		sum := sha256.Sum256(data)
+		checked.MimeType = detected
+		checked.Hash = hex.EncodeToString(sum[:])
+		checked.File = memoryFile{bytes.NewReader(data)}
+		checked.Header = withContent(header, int64(len(data)), detected)
+		return checked, nil
+	}
+
+	// Other files are hashed as they are streamed, not held in memory
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	checked.Hash = hex.EncodeToString(hash.Sum(nil))
+	checked.Header = withContent(header, size, detected)
+	return checked, nil
+}
+
+// storedName returns a file name whose extension is served as the detected
+// type of its content, so that no server sniffs it as something else. Names
+// keep their extension when it is served as that type, or as a related type
+// when neither renders as markup.
+func storedName(filename, mimeType string) string {
+	ext := filepath.Ext(filename)
+	claimed := ""
+	if ext != "" {
+		claimed, _, _ = mime.ParseMediaType(mime.TypeByExtension(ext))
+	}
+	if claimed == mimeType ||
+		(claimed != "" && related(claimed, mimeType) && !markup(claimed) && !markup(mimeType)) {
+		return filename
+	}
+
+	detected := mimetype.Lookup(mimeType)
+	if detected == nil {
+		return strings.TrimSuffix(filename, ext)
+	}
+	return strings.TrimSuffix(filename, ext) + detected.Extension()
+}
+
+// withContent returns a copy of a file header with a size and Content-Type,
+// named with the extension of that type
+func withContent(header *multipart.FileHeader, size int64, mimeType string) *multipart.FileHeader {
+	out := *header
+	out.Filename = storedName(header.Filename, mimeType)
+	out.Size = size
+	out.Header = textproto.MIMEHeader{}
+	for key, values := range header.Header {
+		out.Header[key] = values
+	}
+	out.Header.Set("Content-Type", mimeType)
+	return &out
+}
+
+// polyglot returns the marker of markup or an archive hidden in image data, if any
+func polyglot(data []byte) string {
+	lower := bytes.ToLower(data)
+	for _, marker := range polyglotMarkers {
+		if bytes.Contains(lower, marker) {
+			return string(marker)
+		}
+	}
+	// A ZIP archive, such as a JAR, needs both local file headers and an end record
+	if bytes.Contains(data, []byte("PK\x03\x04")) && bytes.Contains(data, []byte("PK\x05\x06")) {
+		return "ZIP archive"
+	}
+	return ""
+}
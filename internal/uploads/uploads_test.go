@@ -0,0 +1,206 @@
+// internal/uploads/uploads_test.go
+package uploads
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// testConfig mirrors the default upload settings
+var testConfig = config.UploadsConfig{
+	AllowedTypes: []string{
+		"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/svg+xml",
+		"application/pdf", "text/plain", "text/csv",
+		"video/mp4", "video/webm", "audio/mpeg", "audio/ogg", "audio/wav",
+	},
+	MaxSize: 32,
+	Limits: []config.UploadLimitConfig{
+		{Type: "image/*", MaxSize: 20},
+	},
+	SanitizeSVG: true,
+}
+
+// Extensions missing from Go's builtin table are registered, so that the
+// tests do not depend on the system's MIME types
+func init() {
+	mime.AddExtensionType(".txt", "text/plain; charset=utf-8")
+	mime.AddExtensionType(".csv", "text/csv; charset=utf-8")
+}
+
+// pngImage returns a small valid PNG
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// padding is an XML comment longer than the sniffed head of a file
+var padding = "<!--" + strings.Repeat("x", sniffSize) + "-->"
+
+const scriptedSVG = `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><rect width="1"/></svg>`
+
+func TestCheck(t *testing.T) {
+	pngData := pngImage(t)
+
+	tests := []struct {
+		name       string
+		filename   string
+		data       []byte
+		size       int64
+		allowlist  []string
+		noSanitize bool
+		// status is the status of the rejection, or 0 if the file is accepted
+		status    int
+		mimeType  string
+		stored    string
+		sanitized bool
+	}{
+		{name: "png", filename: "photo.png", data: pngData, mimeType: "image/png", stored: "photo.png"},
+		{name: "text", filename: "notes.txt", data: []byte("just some notes\n"), mimeType: "text/plain", stored: "notes.txt"},
+		{name: "no extension", filename: "notes", data: []byte("just some notes\n"), mimeType: "text/plain", stored: "notes.txt"},
+		{name: "empty", filename: "empty.txt", data: []byte{}, status: http.StatusBadRequest},
+		{name: "executable", filename: "tool.bin", data: append([]byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00"), make([]byte, 64)...), status: http.StatusUnsupportedMediaType},
+		{name: "blocked extension", filename: "run.sh", data: []byte("just text\n"), status: http.StatusUnsupportedMediaType},
+		{name: "extension mismatch", filename: "photo.pdf", data: pngData, status: http.StatusUnsupportedMediaType},
+		{name: "not allowed", filename: "notes.txt", data: []byte("text\n"), allowlist: []string{"image/*"}, status: http.StatusUnsupportedMediaType},
+		{name: "allowed by extension", filename: "notes.txt", data: []byte("text\n"), allowlist: []string{".txt"}, mimeType: "text/plain", stored: "notes.txt"},
+		{name: "too large", filename: "photo.png", data: pngData, size: 21 << 20, status: http.StatusRequestEntityTooLarge},
+		{name: "broken image", filename: "photo.png", data: []byte("\x89PNG\r\n\x1a\nnot really a png"), status: http.StatusUnsupportedMediaType},
+		{name: "polyglot image", filename: "photo.png", data: append(append([]byte{}, pngData...), "<script>alert(1)</script>"...), status: http.StatusUnsupportedMediaType},
+		{name: "svg", filename: "logo.svg", data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"><rect width="1"/></svg>`), mimeType: "image/svg+xml", stored: "logo.svg"},
+		{name: "scripted svg", filename: "logo.svg", data: []byte(scriptedSVG), mimeType: "image/svg+xml", stored: "logo.svg", sanitized: true},
+		{name: "scripted svg rejected", filename: "logo.svg", data: []byte(scriptedSVG), noSanitize: true, status: http.StatusUnsupportedMediaType},
+		{name: "invalid svg", filename: "logo.svg", data: []byte("not an image at all\n"), status: http.StatusUnsupportedMediaType},
+		{
+			// The sniffed head only holds the comment, which looks like text
+			name: "svg past the sniffed head", filename: "x.svg", data: []byte(padding + scriptedSVG),
+			mimeType: "image/svg+xml", stored: "x.svg", sanitized: true,
+		},
+		{
+			name: "xml svg past the sniffed head", filename: "x.svg", data: []byte(`<?xml version="1.0"?>` + padding + scriptedSVG),
+			mimeType: "image/svg+xml", stored: "x.svg", sanitized: true,
+		},
+		{
+			name: "svg past the sniffed head unsanitized", filename: "x.svg", data: []byte(padding + scriptedSVG),
+			noSanitize: true, status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "svg past the sniffed head not allowed", filename: "x.svg", data: []byte(padding + scriptedSVG),
+			allowlist: []string{"text/plain"}, status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "svg past the sniffed head named as xml", filename: "x.xml", data: []byte(padding + scriptedSVG),
+			mimeType: "image/svg+xml", stored: "x.svg", sanitized: true,
+		},
+		{
+			// Stored and served as text, which browsers do not run
+			name: "svg past the sniffed head named as text", filename: "x.txt", data: []byte(padding + scriptedSVG),
+			mimeType: "text/plain", stored: "x.txt",
+		},
+		{
+			name: "xml", filename: "data.xml", data: []byte(`<?xml version="1.0"?><items><item/></items>`),
+			allowlist: []string{".xml"}, mimeType: "text/xml", stored: "data.xml",
+		},
+		{
+			name: "text named as xml", filename: "data.xml", data: []byte(padding + "<items/>"),
+			mimeType: "text/plain", stored: "data.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig
+			cfg.SanitizeSVG = !tt.noSanitize
+			checker := NewChecker(cfg)
+
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			header := &multipart.FileHeader{Filename: tt.filename, Size: size}
+			workspace := models.Workspace{AllowedMediaTypes: tt.allowlist}
+
+			checked, err := checker.Check(memoryFile{bytes.NewReader(tt.data)}, header, workspace)
+			if tt.status != 0 {
+				var rejected *Error
+				if !errors.As(err, &rejected) || rejected.Status != tt.status {
+					t.Fatalf("Check error = %v, want a %d rejection", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+
+			if checked.MimeType != tt.mimeType {
+				t.Errorf("MimeType = %q, want %q", checked.MimeType, tt.mimeType)
+			}
+			if got := checked.Header.Header.Get("Content-Type"); got != tt.mimeType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.mimeType)
+			}
+			if checked.Header.Filename != tt.stored {
+				t.Errorf("Filename = %q, want %q", checked.Header.Filename, tt.stored)
+			}
+			if checked.Sanitized != tt.sanitized {
+				t.Errorf("Sanitized = %v, want %v", checked.Sanitized, tt.sanitized)
+			}
+
+			stored, err := io.ReadAll(checked.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(stored)) != checked.Header.Size {
+				t.Errorf("Size = %d, want the %d bytes stored", checked.Header.Size, len(stored))
+			}
+			sum := sha256.Sum256(stored)
+			if checked.Hash != hex.EncodeToString(sum[:]) {
+				t.Error("Hash is not that of the stored content")
+			}
+			if tt.mimeType == "image/svg+xml" && bytes.Contains(stored, []byte("<script")) {
+				t.Errorf("stored SVG %q contains a script", stored)
+			}
+		})
+	}
+}
+
+func TestStoredName(t *testing.T) {
+	tests := []struct {
+		filename, mimeType, want string
+	}{
+		{"photo.jpg", "image/jpeg", "photo.jpg"},
+		{"photo.JPEG", "image/jpeg", "photo.JPEG"},
+		{"logo.svg", "image/svg+xml", "logo.svg"},
+		{"logo.svg", "text/plain", "logo.txt"},
+		{"feed.xml", "text/plain", "feed.txt"},
+		{"image.png", "image/svg+xml", "image.svg"},
+		{"README", "text/plain", "README.txt"},
+		{"data.csv", "text/plain", "data.csv"},
+		{"drawing", "image/svg+xml", "drawing.svg"},
+		{"notes.log", "text/xml", "notes.xml"},
+	}
+
+	for _, tt := range tests {
+		if got := storedName(tt.filename, tt.mimeType); got != tt.want {
+			t.Errorf("storedName(%q, %q) = %q, want %q", tt.filename, tt.mimeType, got, tt.want)
+		}
+	}
+}
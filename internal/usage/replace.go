@@ -0,0 +1,198 @@
+// internal/usage/replace.go
+package usage
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// uploadsPrefix is the path local media is served under
+const uploadsPrefix = "/uploads/"
+
+// ErrOtherWorkspace is returned when replacing media with media of another workspace
+var ErrOtherWorkspace = errors.New("replacement media must belong to the same workspace")
+
+// replacer repoints the references to one media item at another
+type replacer struct {
+	from, to    models.Media
+	urls        *strings.Replacer
+	mediaFields map[string]bool
+}
+
+// id replaces the media ID held by a media field value
+func (r replacer) id(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if uint(v) == r.from.ID {
+			return float64(r.to.ID)
+		}
+	case string:
+		if id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil && uint(id) == r.from.ID {
+			return strconv.FormatUint(uint64(r.to.ID), 10)
+		}
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.id(item)
+		}
+		return out
+	}
+	return value
+}
+
+// text replaces the media URL in a string or in the strings of a field value
+func (r replacer) text(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.urls.Replace(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.text(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = r.text(item)
+		}
+		return out
+	}
+	return value
+}
+
+// apply replaces the media in the body, field values and SEO metadata of a version
+func (r replacer) apply(body *string, fields map[string]interface{}, seo *models.SEO) {
+	*body = r.urls.Replace(*body)
+	for name, value := range fields {
+		if r.mediaFields[name] {
+			fields[name] = r.id(value)
+		} else {
+			fields[name] = r.text(value)
+		}
+	}
+	if seo.ImageID != nil && *seo.ImageID == r.from.ID {
+		id := r.to.ID
+		seo.ImageID = &id
+	}
+}
+
+// urlReplacer rewrites the URLs of a media file and its variants to those of
+// another. URLs of image variants become those of the same variant of the
+// replacement, or of the replacement itself if it has no variant of that name.
+func urlReplacer(from models.Media, fromVariants []models.MediaVariant, to models.Media, toVariants []models.MediaVariant) *strings.Replacer {
+	// Variants are matched by name and type first, then by name alone
+	targets := map[string]string{}
+	for _, v := range toVariants {
+		targets[v.Name+" "+v.MimeType] = v.FilePath
+		if _, ok := targets[v.Name]; !ok {
+			targets[v.Name] = v.FilePath
+		}
+	}
+	pairs := []string{uploadsPrefix + from.FilePath, uploadsPrefix + to.FilePath}
+	for _, v := range fromVariants {
+		target, ok := targets[v.Name+" "+v.MimeType]
+		if !ok {
+			if target, ok = targets[v.Name]; !ok {
+				target = to.FilePath
+			}
+		}
+		pairs = append(pairs, uploadsPrefix+v.FilePath, uploadsPrefix+target)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// Replace repoints every recorded usage of a media item, in content items and
+// their pending drafts, at another media item of the same workspace. It
+// returns the content items it changed.
+func Replace(database *db.DB, from, to models.Media) ([]models.Content, error) {
+	if from.WorkspaceID != to.WorkspaceID {
+		return nil, ErrOtherWorkspace
+	}
+
+	var fromVariants, toVariants []models.MediaVariant
+	if err := database.Where("media_id = ?", from.ID).Find(&fromVariants).Error; err != nil {
+		return nil, err
+	}
+	if err := database.Where("media_id = ?", to.ID).Find(&toVariants).Error; err != nil {
+		return nil, err
+	}
+	return repoint(database, from, to, urlReplacer(from, fromVariants, to, toVariants))
+}
+
+// Rewrite repoints the URLs of the previous file and variants of a media item,
+// in the content items and pending drafts using it, at its current file and
+// variants. It returns the content items it changed.
+func Rewrite(database *db.DB, previous models.Media, previousVariants []models.MediaVariant, current models.Media) ([]models.Content, error) {
+	return repoint(database, previous, current, urlReplacer(previous, previousVariants, current, current.Variants))
+}
+
+// repoint applies a replacement of one media item by another to the content
+// items using the first, and returns the content items it changed
+func repoint(database *db.DB, from, to models.Media, urls *strings.Replacer) ([]models.Content, error) {
+	var ids []uint
+	if err := database.Model(&models.MediaUsage{}).Where("media_id = ?", from.ID).Distinct().Pluck("content_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []models.Content{}, nil
+	}
+
+	var contents []models.Content
+	if err := database.Where("id IN ?", ids).Find(&contents).Error; err != nil {
+		return nil, err
+	}
+
+	replacers := map[uint]replacer{}
+	for _, content := range contents {
+		if _, ok := replacers[content.ContentTypeID]; ok {
+			continue
+		}
+		mediaFields, err := mediaFieldsOf(database, content.ContentTypeID)
+		if err != nil {
+			return nil, err
+		}
+		replacers[content.ContentTypeID] = replacer{
+			from:        from,
+			to:          to,
+			urls:        urls,
+			mediaFields: mediaFields,
+		}
+	}
+
+	err := db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		for i := range contents {
+			content := &contents[i]
+			r := replacers[content.ContentTypeID]
+
+			r.apply(&content.Body, content.Fields, &content.SEO)
+			if err := tx.Save(content).Error; err != nil {
+				return err
+			}
+
+			var draft models.ContentDraft
+			if err := tx.Where("content_id = ?", content.ID).Limit(1).Find(&draft).Error; err != nil {
+				return err
+			}
+			if draft.ID != 0 {
+				r.apply(&draft.Body, draft.Fields, &draft.SEO)
+				if err := tx.Save(&draft).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Reindex(database, ids...)
+	return contents, nil
+}
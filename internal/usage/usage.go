@@ -0,0 +1,317 @@
+// internal/usage/usage.go
+package usage
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/links"
+	"github.com/randilt/floe-cms/internal/models"
+)
+
+// FieldMedia is the content type field type holding media IDs
+const FieldMedia = "media"
+
+// draftPrefix marks usages by the pending draft of a content item
+const draftPrefix = "draft."
+
+// Usage is a place a content item uses a media item
+type Usage struct {
+	ContentID uint   `json:"content_id"`
+	Title     string `json:"title"`
+	Status    string `json:"status"`
+	Field     string `json:"field"`
+}
+
+// version is the part of a content item or its draft that can use media
+type version struct {
+	body   string
+	fields map[string]interface{}
+	seo    models.SEO
+}
+
+// mediaIDs returns the media IDs held by a media field value
+func mediaIDs(value interface{}) []uint {
+	switch v := value.(type) {
+	case float64:
+		if v > 0 {
+			return []uint{uint(v)}
+		}
+	case string:
+		if id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64); err == nil && id > 0 {
+			return []uint{uint(id)}
+		}
+	case []interface{}:
+		out := []uint{}
+		for _, item := range v {
+			out = append(out, mediaIDs(item)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// find returns the media a version uses, by ID or storage path, keyed by field
+func (v version) find(mediaFields map[string]bool, workspace models.Workspace) (map[string][]uint, map[string][]string) {
+	ids := map[string][]uint{}
+	paths := map[string][]string{}
+
+	for name, value := range v.fields {
+		if mediaFields[name] {
+			ids["fields."+name] = append(ids["fields."+name], mediaIDs(value)...)
+		}
+	}
+	if v.seo.ImageID != nil {
+		ids["seo.image_id"] = append(ids["seo.image_id"], *v.seo.ImageID)
+	}
+
+	content := models.Content{Body: v.body, Fields: v.fields}
+	for _, link := range links.Extract(content, workspace) {
+		if link.Kind == links.KindMedia {
+			paths[link.Field] = append(paths[link.Field], link.Target)
+		}
+	}
+	return ids, paths
+}
+
+// mediaFieldsOf returns the names of the media fields of a content type
+func mediaFieldsOf(database *db.DB, contentTypeID uint) (map[string]bool, error) {
+	var contentType models.ContentType
+	if err := database.Limit(1).Find(&contentType, contentTypeID).Error; err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, field := range contentType.Fields {
+		if field.Type == FieldMedia {
+			names[field.Name] = true
+		}
+	}
+	return names, nil
+}
+
+// Index records the media a content item and its pending draft use, replacing
+// what was recorded before. Only media of the item's workspace is recorded.
+func Index(database *db.DB, contentID uint) error {
+	var content models.Content
+	if err := database.Limit(1).Find(&content, contentID).Error; err != nil {
+		return err
+	}
+	if content.ID == 0 {
+		return Remove(database, contentID)
+	}
+
+	var workspace models.Workspace
+	if err := database.First(&workspace, content.WorkspaceID).Error; err != nil {
+		return err
+	}
+	mediaFields, err := mediaFieldsOf(database, content.ContentTypeID)
+	if err != nil {
+		return err
+	}
+
+	versions := map[string]version{"": {body: content.Body, fields: content.Fields, seo: content.SEO}}
+	var draft models.ContentDraft
+	if err := database.Where("content_id = ?", content.ID).Limit(1).Find(&draft).Error; err != nil {
+		return err
+	}
+	if draft.ID != 0 {
+		versions[draftPrefix] = version{body: draft.Body, fields: draft.Fields, seo: draft.SEO}
+	}
+
+	// Collect every reference before resolving them in two queries
+	idRefs := map[string][]uint{}
+	pathRefs := map[string][]string{}
+	allIDs := []uint{}
+	allPaths := []string{}
+	for prefix, v := range versions {
+		ids, paths := v.find(mediaFields, workspace)
+		for field, list := range ids {
+			idRefs[prefix+field] = list
+			allIDs = append(allIDs, list...)
+		}
+		for field, list := range paths {
+			pathRefs[prefix+field] = list
+			allPaths = append(allPaths, list...)
+		}
+	}
+
+	var media []models.Media
+	if len(allIDs) > 0 || len(allPaths) > 0 {
+		query := database.Select("id", "file_path").Where("workspace_id = ?", content.WorkspaceID)
+		switch {
+		case len(allIDs) > 0 && len(allPaths) > 0:
+			query = query.Where("id IN ? OR file_path IN ?", allIDs, allPaths)
+		case len(allIDs) > 0:
+			query = query.Where("id IN ?", allIDs)
+		default:
+			query = query.Where("file_path IN ?", allPaths)
+		}
+		if err := query.Find(&media).Error; err != nil {
+			return err
+		}
+	}
+	known := map[uint]bool{}
+	// A file shared by duplicate media is a use of each of them
+	byPath := map[string][]uint{}
+	for _, m := range media {
+		known[m.ID] = true
+		byPath[m.FilePath] = append(byPath[m.FilePath], m.ID)
+	}
+
+	// Image variants count as uses of their media
+	if len(allPaths) > 0 {
+		var variants []models.MediaVariant
+		if err := database.Select("media_id", "file_path").
+			Where("file_path IN ? AND media_id IN (?)", allPaths,
+				database.Model(&models.Media{}).Select("id").Where("workspace_id = ?", content.WorkspaceID)).
+			Find(&variants).Error; err != nil {
+			return err
+		}
+		for _, v := range variants {
+			known[v.MediaID] = true
+			byPath[v.FilePath] = append(byPath[v.FilePath], v.MediaID)
+		}
+	}
+
+	seen := map[string]bool{}
+	rows := []models.MediaUsage{}
+	add := func(mediaID uint, field string) {
+		key := fmt.Sprintf("%d:%s", mediaID, field)
+		if !known[mediaID] || seen[key] {
+			return
+		}
+		seen[key] = true
+		rows = append(rows, models.MediaUsage{
+			WorkspaceID: content.WorkspaceID,
+			MediaID:     mediaID,
+			ContentID:   content.ID,
+			Field:       field,
+		})
+	}
+	for field, list := range idRefs {
+		for _, id := range list {
+			add(id, field)
+		}
+	}
+	for field, list := range pathRefs {
+		for _, path := range list {
+			for _, id := range byPath[path] {
+				add(id, field)
+			}
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].MediaID != rows[j].MediaID {
+			return rows[i].MediaID < rows[j].MediaID
+		}
+		return rows[i].Field < rows[j].Field
+	})
+
+	return db.ExecuteWithTransaction(database, func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("content_id = ?", content.ID).Delete(&models.MediaUsage{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// Remove forgets the media a deleted content item used
+func Remove(database *db.DB, contentID uint) error {
+	return database.Unscoped().Where("content_id = ?", contentID).Delete(&models.MediaUsage{}).Error
+}
+
+// RemoveMedia forgets every usage of a deleted media item
+func RemoveMedia(database *db.DB, mediaID uint) error {
+	return database.Unscoped().Where("media_id = ?", mediaID).Delete(&models.MediaUsage{}).Error
+}
+
+// Reindex indexes content items, logging failures instead of returning them
+func Reindex(database *db.DB, contentIDs ...uint) {
+	for _, id := range contentIDs {
+		if err := Index(database, id); err != nil {
+			slog.Error("Failed to index media usage", "content_id", id, "error", err)
+		}
+	}
+}
+
+// ReindexRelease indexes the content items a release changed. The release
+// items must be loaded.
+func ReindexRelease(database *db.DB, release models.Release) {
+	for _, item := range release.Items {
+		Reindex(database, item.ContentID)
+	}
+}
+
+// RebuildAll indexes every content item
+func RebuildAll(database *db.DB) {
+	var ids []uint
+	if err := database.Model(&models.Content{}).Pluck("id", &ids).Error; err != nil {
+		slog.Error("Failed to fetch content for media usage", "error", err)
+		return
+	}
+
+	// Usages of content deleted while the index was not maintained
+	if err := database.Unscoped().Where("content_id NOT IN (?)", database.Model(&models.Content{}).Select("id")).
+		Delete(&models.MediaUsage{}).Error; err != nil {
+		slog.Error("Failed to prune media usage", "error", err)
+	}
+
+	Reindex(database, ids...)
+}
+
+// Usages returns where a media item is used
+func Usages(database *db.DB, mediaID uint) ([]Usage, error) {
+	var rows []models.MediaUsage
+	if err := database.Where("media_id = ?", mediaID).Order("content_id asc, field asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	ids := []uint{}
+	for _, row := range rows {
+		ids = append(ids, row.ContentID)
+	}
+	var contents []models.Content
+	if len(ids) > 0 {
+		if err := database.Select("id", "title", "status").Where("id IN ?", ids).Find(&contents).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]models.Content, len(contents))
+	for _, content := range contents {
+		byID[content.ID] = content
+	}
+
+	usages := []Usage{}
+	for _, row := range rows {
+		content, ok := byID[row.ContentID]
+		if !ok {
+			continue
+		}
+		usages = append(usages, Usage{
+			ContentID: content.ID,
+			Title:     content.Title,
+			Status:    content.Status,
+			Field:     row.Field,
+		})
+	}
+	return usages, nil
+}
+
+// ContentCount returns the number of distinct content items among usages
+func ContentCount(usages []Usage) int {
+	items := map[uint]bool{}
+	for _, u := range usages {
+		items[u.ContentID] = true
+	}
+	return len(items)
+}
@@ -0,0 +1,90 @@
+// internal/visibility/visibility.go
+package visibility
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/uploads"
+)
+
+// Private reports whether a stored file belongs to private media only: as
+// its file, one of its variants, one of its transforms or a previous version. A file shared by
+// public and private media is public, and so are files of no media.
+func Private(database *db.DB, filePath string) (bool, error) {
+	source := filePath
+	if rest, ok := strings.CutPrefix(filePath, images.TransformsDir+"/"); ok {
+		source = path.Dir(rest)
+	}
+
+	var flags []bool
+	err := database.Model(&models.Media{}).
+		Where("file_path = ? OR id IN (?) OR id IN (?)", source,
+			database.Model(&models.MediaVariant{}).Select("media_id").Where("file_path = ?", filePath),
+			database.Model(&models.MediaVersion{}).Select("media_id").Where("file_path = ?", source)).
+		Pluck("private", &flags).Error
+	if err != nil {
+		return false, err
+	}
+
+	for _, private := range flags {
+		if !private {
+			return false, nil
+		}
+	}
+	return len(flags) > 0, nil
+}
+
+// Guard serves the files of private media only to requests signed by the
+// storage manager, and passes the others on. It is mounted with the
+// /uploads/ prefix stripped. Paths that are not in their clean form are not
+// served, since the file server behind would clean them and serve a file the
+// guard never checked, and neither are the chunks of resumable uploads.
+func Guard(database *db.DB, store storage.Manager) func(http.Handler) http.Handler {
+	signer := store.Signer()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			filePath := strings.TrimPrefix(r.URL.Path, "/")
+			if filePath == "" || path.Clean("/"+filePath) != "/"+filePath ||
+				strings.HasPrefix(filePath, uploads.PartialsDir+"/") {
+				http.NotFound(w, r)
+				return
+			}
+			query := r.URL.Query()
+
+			if signer.Verify(filePath, query) {
+				// Signed responses must not outlive their signature in shared caches
+				maxAge := int(storage.Remaining(query).Seconds())
+				w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+				w.Header().Set("X-Robots-Tag", "noindex")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			private, err := Private(database, filePath)
+			if err != nil {
+				slog.Error("Failed to check file visibility", "path", filePath, "error", err)
+				http.Error(w, "Failed to fetch file", http.StatusInternalServerError)
+				return
+			}
+			if !private {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Private files are not acknowledged to exist without a signature
+			if storage.Signed(query) {
+				http.Error(w, "Signed URL is invalid or has expired", http.StatusForbidden)
+				return
+			}
+			http.NotFound(w, r)
+		})
+	}
+}
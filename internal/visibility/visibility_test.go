@@ -0,0 +1,78 @@
+// internal/visibility/visibility_test.go
+package visibility
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/randilt/floe-cms/internal/config"
+	"github.com/randilt/floe-cms/internal/db/dbtest"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/storage"
+)
+
+// writeFile stores a file containing its path in local storage at dir
+func writeFile(t *testing.T, dir, filePath string) {
+	t.Helper()
+	full := filepath.Join(dir, filepath.FromSlash(filePath))
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(filePath), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	database := dbtest.New(t)
+	dir := t.TempDir()
+	store := storage.NewLocalStorage(dir, storage.NewSigner(config.StorageConfig{URLSecret: "secret", PrivateURLExpiry: 600}, ""))
+
+	files := map[string]bool{"2024/01/01/secret.pdf": true, "2024/01/01/public.pdf": false}
+	for filePath, private := range files {
+		writeFile(t, dir, filePath)
+		media := models.Media{WorkspaceID: 1, Name: filePath, FileName: filepath.Base(filePath), FilePath: filePath, Private: private}
+		if err := database.Create(&media).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Chunks of resumable uploads belong to no media
+	writeFile(t, dir, "partials/1/00000000000000000000-abcdefgh")
+
+	handler := http.StripPrefix("/uploads/", Guard(database, store)(store.Handler()))
+	signed := "?" + store.Signer().Sign("2024/01/01/secret.pdf").Encode()
+	signedChunk := "?" + store.Signer().Sign("partials/1/00000000000000000000-abcdefgh").Encode()
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{name: "public", target: "/uploads/2024/01/01/public.pdf", status: http.StatusOK},
+		{name: "private", target: "/uploads/2024/01/01/secret.pdf", status: http.StatusNotFound},
+		{name: "private signed", target: "/uploads/2024/01/01/secret.pdf" + signed, status: http.StatusOK},
+		{name: "private badly signed", target: "/uploads/2024/01/01/secret.pdf?expires=1&signature=x", status: http.StatusForbidden},
+		{name: "double slash", target: "/uploads/2024//01/01/secret.pdf", status: http.StatusNotFound},
+		{name: "dot segment", target: "/uploads/./2024/01/01/secret.pdf", status: http.StatusNotFound},
+		{name: "encoded dot segment", target: "/uploads/%2e/2024/01/01/secret.pdf", status: http.StatusNotFound},
+		{name: "encoded parent segment", target: "/uploads/2024/%2e%2e/2024/01/01/secret.pdf", status: http.StatusNotFound},
+		{name: "trailing slash", target: "/uploads/2024/01/01/secret.pdf/", status: http.StatusNotFound},
+		{name: "unclean signed", target: "/uploads/2024//01/01/secret.pdf" + signed, status: http.StatusNotFound},
+		{name: "unclean public", target: "/uploads/2024//01/01/public.pdf", status: http.StatusNotFound},
+		{name: "upload chunk", target: "/uploads/partials/1/00000000000000000000-abcdefgh", status: http.StatusNotFound},
+		{name: "upload chunk signed", target: "/uploads/partials/1/00000000000000000000-abcdefgh" + signedChunk, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, tt.status)
+			}
+		})
+	}
+}
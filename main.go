@@ -14,17 +14,40 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/randilt/floe-cms/internal/analytics"
 	"github.com/randilt/floe-cms/internal/api"
 	"github.com/randilt/floe-cms/internal/auth"
+	"github.com/randilt/floe-cms/internal/cache"
 	"github.com/randilt/floe-cms/internal/config"
 	"github.com/randilt/floe-cms/internal/db"
+	"github.com/randilt/floe-cms/internal/dedup"
+	"github.com/randilt/floe-cms/internal/delivery"
+	"github.com/randilt/floe-cms/internal/export"
+	"github.com/randilt/floe-cms/internal/images"
+	"github.com/randilt/floe-cms/internal/links"
+	"github.com/randilt/floe-cms/internal/models"
+	"github.com/randilt/floe-cms/internal/quota"
+	"github.com/randilt/floe-cms/internal/related"
+	"github.com/randilt/floe-cms/internal/releases"
 	"github.com/randilt/floe-cms/internal/storage"
+	"github.com/randilt/floe-cms/internal/uploads"
+	"github.com/randilt/floe-cms/internal/usage"
 )
 
 //go:embed web/admin/dist
 var AdminUIAssets embed.FS
 
 func main() {
+	// Subcommands are dispatched before the server flags are parsed
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reconcile-usage" {
+		runReconcileUsage(os.Args[2:])
+		return
+	}
+
 	var configPath string
 	var port int
 	var resetAdmin bool
@@ -70,7 +93,27 @@ func main() {
 	}
 
 	// Initialize storage
-	storageManager := storage.NewLocalStorage(cfg.Storage.UploadsDir)
+	storageManager, err := storage.New(cfg.Storage, cfg.Auth.JWTSecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Private media stored before must stay private with this storage
+	var private int64
+	if err := database.Model(&models.Media{}).Where("private = ?", true).Count(&private).Error; err != nil {
+		log.Fatalf("Failed to check for private media: %v", err)
+	}
+	if private > 0 {
+		if err := storageManager.CheckPrivate(); err != nil {
+			log.Fatalf("Found %d private media items: %v", private, err)
+		}
+	}
+
+	// Generate image variants and transforms into storage
+	imageProcessor := images.NewProcessor(database, storageManager, cfg.Images, cfg.Auth.JWTSecret)
+
+	// Keep resumable uploads in storage until they complete
+	partials := uploads.NewPartials(storageManager, cfg.Uploads.PartialExpiry)
 
 	// Initialize authentication
 	authManager := auth.NewManager(database, cfg.Auth)
@@ -89,8 +132,59 @@ func main() {
 		log.Fatalf("Failed to ensure admin exists: %v", err)
 	}
 
+	// Cache responses of the public delivery API
+	responseCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+	defer responseCache.Close()
+
+	// Keep related content recommendations up to date in the background
+	relatedRefresher := related.NewRefresher(database, func(workspaceID uint) {
+		responseCache.Invalidate(cache.Related(workspaceID))
+	})
+	go related.RefreshAll(database)
+
+	// Bring the media usage index up to date with content saved before it existed
+	go usage.RebuildAll(database)
+
+	// Generate the variants of images uploaded before they existed
+	go imageProcessor.Backfill()
+
+	// Record the storage usage of media uploaded before it was accounted for,
+	// before uploads are checked against quotas
+	if err := quota.Backfill(database); err != nil {
+		slog.Error("Failed to record storage usage", "error", err)
+	}
+
+	// Hash the files of media uploaded before duplicates were detected
+	go dedup.BackfillHashes(database, storageManager)
+
+	// Publish scheduled releases in the background
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go releases.RunScheduler(schedulerCtx, database, releases.SchedulerInterval, func(release models.Release) {
+		relatedRefresher.Schedule(release.WorkspaceID)
+		responseCache.Invalidate(cache.ReleaseTags(release)...)
+		usage.ReindexRelease(database, release)
+	})
+
+	// Check content for broken internal links in the background
+	if cfg.Links.CheckInterval > 0 {
+		go links.RunChecker(schedulerCtx, database, time.Duration(cfg.Links.CheckInterval)*time.Minute)
+	}
+
+	// Remove abandoned resumable uploads in the background
+	go uploads.RunExpiry(schedulerCtx, database, partials, uploads.ExpiryInterval)
+
+	// Record views of public content in the background
+	var views *analytics.Recorder
+	if cfg.Analytics.Enabled {
+		views = analytics.NewRecorder(database, cfg.Analytics)
+	}
+
 	// Initialize API router
-	router := api.NewRouter(authManager, database, storageManager, AdminUIAssets, cfg)
+	router := api.NewRouter(authManager, database, storageManager, imageProcessor, partials, views, relatedRefresher, responseCache, AdminUIAssets, cfg)
 
 	// Configure HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -117,6 +211,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopScheduler()
 
 	// Create a deadline for server shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.GracefulShutdown)*time.Second)
@@ -127,5 +222,98 @@ func main() {
 		logger.Error("Server forced to shutdown", "error", err)
 	}
 
+	// Write out the views still buffered
+	views.Close()
+
 	logger.Info("Server exited properly")
-}
\ No newline at end of file
+}
+
+// runExport renders the published content of a workspace into static JSON files
+func runExport(args []string) {
+	var configPath string
+	var workspace string
+	var outDir string
+	var dbURL string
+
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
+	flags.StringVar(&workspace, "workspace", "default", "Slug of the workspace to export")
+	flags.StringVar(&outDir, "out", "", "Output directory (defaults to export.output_dir/<workspace>)")
+	flags.StringVar(&dbURL, "db-url", "", "Override database URL defined in configuration")
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if dbURL != "" {
+		cfg.Database.URL = dbURL
+	}
+
+	// Initialize database connection
+	database, err := db.Initialize(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	// Run migrations
+	if err := db.MigrateDatabase(database); err != nil {
+		log.Fatalf("Failed to run database migrations: %v", err)
+	}
+
+	storageManager, err := storage.New(cfg.Storage, cfg.Auth.JWTSecret)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	exporter := export.NewExporter(database, delivery.NewSerializer(cfg.Delivery, storageManager), cfg.Export)
+
+	result, err := exporter.Export(workspace, outDir)
+	if err != nil {
+		log.Fatalf("Failed to export workspace: %v", err)
+	}
+
+	fmt.Printf("Exported %d items (%d files) to %s in %s\n", result.Items, result.Files, result.Directory, result.Duration)
+}
+
+// runReconcileUsage recomputes the storage usage of every workspace and
+// uploader from the media items and their versions, correcting drift of the
+// running totals
+func runReconcileUsage(args []string) {
+	var configPath string
+	var dbURL string
+
+	flags := flag.NewFlagSet("reconcile-usage", flag.ExitOnError)
+	flags.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
+	flags.StringVar(&dbURL, "db-url", "", "Override database URL defined in configuration")
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if dbURL != "" {
+		cfg.Database.URL = dbURL
+	}
+
+	// Initialize database connection
+	database, err := db.Initialize(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	// Run migrations
+	if err := db.MigrateDatabase(database); err != nil {
+		log.Fatalf("Failed to run database migrations: %v", err)
+	}
+
+	total, err := quota.Reconcile(database)
+	if err != nil {
+		log.Fatalf("Failed to reconcile storage usage: %v", err)
+	}
+
+	fmt.Printf("Recomputed storage usage: %d files, %d bytes\n", total.Files, total.Bytes)
+}
@@ -311,6 +311,8 @@ export default function ContentTypeEditor() {
                           <option value="boolean">Boolean</option>
                           <option value="date">Date</option>
                           <option value="select">Select</option>
+                          <option value="tags">Tags</option>
+                          <option value="reference">Reference</option>
                         </select>
                       </div>
                       <div>
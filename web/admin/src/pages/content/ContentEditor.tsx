@@ -17,6 +17,8 @@ export default function ContentEditor() {
   const [error, setError] = useState("");
   const [success, setSuccess] = useState("");
   const [contentTypes, setContentTypes] = useState<any[]>([]);
+  // Whether the content has a live version, whose edits are kept in its draft
+  const [isLive, setIsLive] = useState(false);
 
   const [content, setContent] = useState({
     title: "",
@@ -65,12 +67,16 @@ export default function ContentEditor() {
 
     try {
       const response = await axios.get(
-        `/api/workspaces/${currentWorkspace.id}/content/${contentId}`
+        `/api/workspaces/${currentWorkspace.id}/content/${contentId}/draft`
       );
       if (response.data.success) {
+        // Edit the working draft, or the live version when it has none
+        const item = response.data.data.draft || response.data.data.live;
+        setIsLive(!!response.data.data.live);
+
         // If content belongs to a different workspace than the current one,
         // make sure we handle it properly
-        const workspaceId = response.data.data.workspace_id;
+        const workspaceId = item.workspace_id;
 
         // Check if this content belongs to one of the user's workspaces
         const workspaceExists = workspaces.some((ws) => ws.id === workspaceId);
@@ -99,13 +105,13 @@ export default function ContentEditor() {
         }
 
         setContent({
-          title: response.data.data.title || "",
-          slug: response.data.data.slug || "",
-          body: response.data.data.body || "",
-          status: response.data.data.status || "draft",
-          content_type_id: response.data.data.content_type_id || "",
-          workspace_id: response.data.data.workspace_id,
-          meta_data: response.data.data.meta_data || "",
+          title: item.title || "",
+          slug: item.slug || "",
+          body: item.body || "",
+          status: item.status || "draft",
+          content_type_id: item.content_type_id || "",
+          workspace_id: item.workspace_id,
+          meta_data: item.meta_data || "",
         });
       } else {
         setError(response.data.error || "Failed to load content");
@@ -149,9 +155,13 @@ export default function ContentEditor() {
     setSuccess("");
     setIsSaving(true);
 
+    // Edits of live content are kept in its draft, which publishing makes
+    // live; only unpublishing changes the live version directly
+    const toDraft = isLive && content.status === "published";
     const contentToSave = {
       ...content,
       status: publish ? "published" : content.status,
+      draft: toDraft,
     };
 
     try {
@@ -163,6 +173,11 @@ export default function ContentEditor() {
           `/api/workspaces/${currentWorkspace.id}/content/${id}`,
           contentToSave
         );
+        if (response.data.success && toDraft && publish) {
+          response = await axios.post(
+            `/api/workspaces/${currentWorkspace.id}/content/${id}/draft/publish`
+          );
+        }
       } else {
         // This is creating a new post
         response = await axios.post(